@@ -4,12 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	secretmanagerpb "cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/service"
 )
 
 const (
@@ -17,6 +23,11 @@ const (
 	notionClientSecret = "NOTION_CLIENT_SECRET"
 )
 
+const defaultAuthSuccessURL = "https://notionplusid.app/welcome"
+
+// defaultWebhookPollInterval is the pause between the worker passes once the webhooks are set up.
+const defaultWebhookPollInterval = time.Minute
+
 // NotionExtMode defines in which mode the credentials would be provided.
 type NotionExtMode string
 
@@ -50,6 +61,42 @@ func (m *NotionExtMode) Validate() error {
 	return fmt.Errorf("unknown notion ext mode: %s", *m)
 }
 
+// StorageDriver defines which storage backend is used to persist the state.
+type StorageDriver string
+
+var (
+	// Datastore of the Google Cloud Platform.
+	StorageDriverDatastore StorageDriver = "datastore"
+
+	// PostgreSQL database available by the STORAGE_POSTGRES_DSN connection string.
+	StorageDriverPostgres StorageDriver = "postgres"
+
+	// Embedded database stored in the local STORAGE_BOLT_PATH file.
+	StorageDriverBolt StorageDriver = "bolt"
+)
+
+var defaultStorageDriver = StorageDriverDatastore
+
+var validStorageDrivers = []StorageDriver{
+	StorageDriverDatastore,
+	StorageDriverPostgres,
+	StorageDriverBolt,
+}
+
+// Validate the StorageDriver.
+func (d *StorageDriver) Validate() error {
+	if d == nil {
+		return errors.New("storage driver is required")
+	}
+	for _, vd := range validStorageDrivers {
+		if vd == *d {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown storage driver: %s", *d)
+}
+
 // Env with all the environment vars.
 type Env struct {
 	HTTP struct {
@@ -62,6 +109,53 @@ type Env struct {
 		LocationID  string
 	}
 
+	Log struct {
+		Format logging.Format
+		Level  slog.Level
+	}
+
+	Storage struct {
+		Driver      StorageDriver
+		PostgresDSN string
+		BoltPath    string
+	}
+
+	Token struct {
+		// file with the base64 encoded key used to encrypt the workspace tokens at rest.
+		KeyFile string
+		// files with the previous keys, which are still accepted until the tokens are re-encrypted.
+		OldKeyFiles []string
+	}
+
+	Auth struct {
+		// secret used to sign the OAuth state.
+		StateSecret string
+		// where the browser is redirected after the successful authorisation.
+		SuccessURL string
+		// where the browser is redirected after the failed authorisation, the error is responded instead if empty.
+		FailureURL string
+	}
+
+	Worker struct {
+		// workspaces processed concurrently.
+		Workspaces int
+		// tables of the same workspace filled concurrently.
+		Tables int
+		// pages patched concurrently with the same workspace token.
+		PageWorkers int
+		// pause between the passes over the workspaces.
+		PollInterval time.Duration
+		// how often the whole tables are queried, the fills in between query only the recently edited pages.
+		FullScanInterval time.Duration
+		// schedule of the workspace passes.
+		Schedule autocounter.Schedule
+	}
+
+	Admin struct {
+		// token that authorises the admin API requests, the admin API is disabled if empty.
+		Token string
+	}
+
 	Segment struct {
 		WriteKey string
 	}
@@ -72,8 +166,17 @@ type Env struct {
 		RedirectURI  string
 		ExtMode      NotionExtMode
 
+		// base URL of the Notion API, the default one is used if empty.
+		APIURL string
+
 		// amount of workspaces processed in one go.
 		ProcWss int64
+
+		// whether the Notion API rate limits are shared by the instances through the storage.
+		SharedRateLimit bool
+
+		// verification token the webhook events are signed with.
+		WebhookSecret string
 	}
 }
 
@@ -88,9 +191,52 @@ func NewEnv(ctx context.Context) (Env, error) {
 
 	e.Segment.WriteKey = os.Getenv("SEGMENT_WRITE_KEY")
 
+	e.Log.Format = logging.Format(os.Getenv("LOG_FORMAT"))
+	if e.Log.Format == "" {
+		e.Log.Format = logging.FormatText
+	}
+	if err := e.Log.Format.Validate(); err != nil {
+		return Env{}, err
+	}
+
+	e.Log.Level = slog.LevelInfo
+	if lvl := os.Getenv("LOG_LEVEL"); lvl != "" {
+		l, err := logging.ParseLevel(lvl)
+		if err != nil {
+			return Env{}, err
+		}
+		e.Log.Level = l
+	}
+
 	e.GCloud.ProjectID = os.Getenv("GCLOUD_PROJECT_ID")
 	e.GCloud.LocationID = os.Getenv("GCLOUD_LOCATION_ID")
 
+	e.Storage.Driver = StorageDriver(os.Getenv("STORAGE_DRIVER"))
+	if e.Storage.Driver == "" {
+		e.Storage.Driver = defaultStorageDriver
+	}
+	if err := e.Storage.Driver.Validate(); err != nil {
+		return Env{}, err
+	}
+
+	e.Storage.PostgresDSN = os.Getenv("STORAGE_POSTGRES_DSN")
+	if e.Storage.Driver == StorageDriverPostgres && e.Storage.PostgresDSN == "" {
+		return Env{}, errors.New("STORAGE_POSTGRES_DSN is required for the postgres storage driver")
+	}
+
+	e.Storage.BoltPath = os.Getenv("STORAGE_BOLT_PATH")
+	if e.Storage.BoltPath == "" {
+		e.Storage.BoltPath = "plusid.db"
+	}
+
+	e.Token.KeyFile = os.Getenv("TOKEN_KEY_FILE")
+	if old := os.Getenv("TOKEN_OLD_KEY_FILES"); old != "" {
+		e.Token.OldKeyFiles = strings.Split(old, ",")
+	}
+	if e.Token.KeyFile == "" && len(e.Token.OldKeyFiles) != 0 {
+		return Env{}, errors.New("TOKEN_KEY_FILE is required for TOKEN_OLD_KEY_FILES")
+	}
+
 	e.Notion.ExtMode = NotionExtMode(os.Getenv("NOTION_EXT_MODE"))
 	if e.Notion.ExtMode == "" {
 		e.Notion.ExtMode = defaultNotionExtMode
@@ -101,9 +247,79 @@ func NewEnv(ctx context.Context) (Env, error) {
 
 	e.Notion.RedirectURI = os.Getenv("NOTION_REDIRECT_URI")
 
+	e.Auth.StateSecret = os.Getenv("OAUTH_STATE_SECRET")
+	if e.Notion.ExtMode == NotionExtModePublic && e.Auth.StateSecret == "" {
+		return Env{}, errors.New("OAUTH_STATE_SECRET is required for the public notion ext mode")
+	}
+	e.Auth.SuccessURL = os.Getenv("AUTH_SUCCESS_URL")
+	if e.Auth.SuccessURL == "" {
+		e.Auth.SuccessURL = defaultAuthSuccessURL
+	}
+	e.Auth.FailureURL = os.Getenv("AUTH_FAILURE_URL")
+	e.Admin.Token = os.Getenv("ADMIN_API_TOKEN")
+	e.Notion.APIURL = os.Getenv("NOTION_API_URL")
+	e.Notion.WebhookSecret = os.Getenv("NOTION_WEBHOOK_SECRET")
+
+	var err error
+	if e.Worker.Workspaces, err = intEnv("WORKER_WORKSPACES", service.DefaultConcurrency.Workspaces); err != nil {
+		return Env{}, err
+	}
+	if e.Worker.Tables, err = intEnv("WORKER_TABLES", service.DefaultConcurrency.Tables); err != nil {
+		return Env{}, err
+	}
+	if e.Worker.PageWorkers, err = intEnv("WORKER_PAGE_WORKERS", service.DefaultConcurrency.PageWorkers); err != nil {
+		return Env{}, err
+	}
+
+	// the webhooks take care of the fresh pages, so the polling is only the safety net.
+	var pollInterval time.Duration
+	if e.Notion.WebhookSecret != "" {
+		pollInterval = defaultWebhookPollInterval
+	}
+	if e.Worker.PollInterval, err = durationEnv("WORKER_POLL_INTERVAL", pollInterval); err != nil {
+		return Env{}, err
+	}
+	if e.Worker.FullScanInterval, err = durationEnv("WORKER_FULL_SCAN_INTERVAL", service.DefaultFullScanInterval); err != nil {
+		return Env{}, err
+	}
+
+	sch := autocounter.DefaultSchedule
+	if sch.Busy, err = durationEnv("WORKER_BUSY_INTERVAL", sch.Busy); err != nil {
+		return Env{}, err
+	}
+	if sch.Idle, err = durationEnv("WORKER_IDLE_INTERVAL", sch.Idle); err != nil {
+		return Env{}, err
+	}
+	if sch.MaxIdle, err = durationEnv("WORKER_MAX_IDLE_INTERVAL", sch.MaxIdle); err != nil {
+		return Env{}, err
+	}
+	if sch.QuarantineAfter, err = intEnv("WORKER_QUARANTINE_AFTER", sch.QuarantineAfter); err != nil {
+		return Env{}, err
+	}
+	if sch.Quarantine, err = durationEnv("WORKER_QUARANTINE_INTERVAL", sch.Quarantine); err != nil {
+		return Env{}, err
+	}
+	if err := sch.Validate(); err != nil {
+		return Env{}, fmt.Errorf("worker schedule: %w", err)
+	}
+	e.Worker.Schedule = sch
+
+	// the shared rate limit costs a storage transaction per Notion API request,
+	// so it's enabled only for the deployments that are known to run multiple instances.
+	if v := os.Getenv("NOTION_SHARED_RATE_LIMIT"); v != "" {
+		if e.Notion.SharedRateLimit, err = strconv.ParseBool(v); err != nil {
+			return Env{}, fmt.Errorf("NOTION_SHARED_RATE_LIMIT: expected boolean: provided - %s", v)
+		}
+	}
+	// the bolt database is locked by a single instance, so there's nothing to share.
+	if e.Notion.SharedRateLimit && e.Storage.Driver == StorageDriverBolt {
+		slog.Warn("NOTION_SHARED_RATE_LIMIT is ignored: the bolt storage is used by a single instance")
+		e.Notion.SharedRateLimit = false
+	}
+
 	procWssCount, err := strconv.ParseInt(os.Getenv("NOTION_PROC_WSS_COUNT"), 10, 64)
 	if err != nil {
-		log.Printf("Invalid value at NOTION_PROC_WSS_COUNT. Using default of 100")
+		slog.Warn("invalid value at NOTION_PROC_WSS_COUNT: using default of 100")
 		e.Notion.ProcWss = 100
 	} else {
 		e.Notion.ProcWss = procWssCount
@@ -137,3 +353,33 @@ func NewEnv(ctx context.Context) (Env, error) {
 
 	return e, nil
 }
+
+// durationEnv returns the non-negative duration value of the environment variable or the default one if it isn't set.
+func durationEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: expected non-negative duration: provided - %s", name, v)
+	}
+
+	return d, nil
+}
+
+// intEnv returns the positive integer value of the environment variable or the default one if it isn't set.
+func intEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s: expected positive integer: provided - %s", name, v)
+	}
+
+	return n, nil
+}
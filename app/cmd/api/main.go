@@ -4,27 +4,41 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	gohttp "net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	// the time zones of the tables don't depend on the system tzdata.
+	_ "time/tzdata"
 
 	autocounter "github.com/notionplusid/core/app"
 	"github.com/notionplusid/core/app/handler/http"
+	"github.com/notionplusid/core/app/internal/envelope"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/internal/oauthstate"
 	"github.com/notionplusid/core/app/provider/notion"
 	"github.com/notionplusid/core/app/service"
+	"github.com/notionplusid/core/app/storage"
+	"github.com/notionplusid/core/app/storage/bolt"
 	"github.com/notionplusid/core/app/storage/datastore"
+	"github.com/notionplusid/core/app/storage/encrypted"
 	"github.com/notionplusid/core/app/storage/inmemcache"
+	"github.com/notionplusid/core/app/storage/postgres"
 )
 
 const (
-	shutdownTO = 10 * time.Second
+	shutdownTO   = 10 * time.Second
+	authStateTTL = 10 * time.Minute
+	// fillQueueSize is the amount of tables waiting for the fill requested by the webhooks.
+	fillQueueSize = 1000
+	// idleWorkerPause is the min pause of the worker once no workspace is due to be processed.
+	idleWorkerPause = 5 * time.Second
 )
 
 func main() {
-	log.Print("Autocounter API starting")
+	slog.Info("autocounter API starting")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -32,47 +46,93 @@ func main() {
 
 	env, err := NewEnv(ctx)
 	if err != nil {
-		log.Fatalf("Env: %s", err)
-		return
+		fatal("couldn't load env", err)
 	}
-	log.Print("Env: OK")
+	slog.SetDefault(logging.New(os.Stderr, env.Log.Format, env.Log.Level))
+	slog.Info("env: ok")
 
-	ds, err := datastore.New(ctx, env.GCloud.ProjectID)
+	s, err := newStorage(ctx, env)
 	if err != nil {
-		log.Fatalf("Datastore: %s", err)
-		return
+		fatal("couldn't initialise storage", err)
 	}
-	log.Print("Datastore: OK")
+	slog.Info("storage: ok", slog.String("driver", string(env.Storage.Driver)))
 
-	inmem, err := inmemcache.New(ds)
+	notionOpts := []notion.Option{notion.WithAPIURL(env.Notion.APIURL)}
+	if env.Token.KeyFile != "" {
+		enc, e, err := newEncrypted(ctx, s, env)
+		if err != nil {
+			fatal("couldn't initialise token encryption", err)
+		}
+		s = enc
+		notionOpts = append(notionOpts, notion.WithTokenDecrypter(e))
+	} else {
+		// the encrypted tokens would be sent as is and the workspaces would get unregistered as unauthorized.
+		has, err := encrypted.HasEncrypted(ctx, s)
+		switch {
+		case err != nil:
+			fatal("couldn't check stored tokens", err)
+		case has:
+			fatal("couldn't initialise token encryption", errors.New("TOKEN_KEY_FILE is required: stored tokens are encrypted"))
+		}
+		slog.Warn("token encryption: disabled: TOKEN_KEY_FILE is not set")
+	}
+
+	if env.Notion.SharedRateLimit {
+		notionOpts = append(notionOpts, notion.WithLimiters(notion.NewSharedLimiters(s)))
+	}
+	slog.Info("notion rate limit: ok", slog.Bool("shared", env.Notion.SharedRateLimit))
+	// the clients are shared by the services, so every workspace has a single client and a single rate limiter.
+	clients := notion.NewClients(notionOpts...)
+
+	inmem, err := inmemcache.New(s)
 	if err != nil {
-		log.Fatalf("In-mem cache: %s", err)
-		return
+		fatal("couldn't initialise in-mem cache", err)
 	}
-	log.Print("In-mem cache: OK")
+	slog.Info("in-mem cache: ok")
 	if err := inmem.Sync(ctx); err != nil {
-		log.Fatalf("In-mem cache: couldn't sync: %s", err)
-		return
+		fatal("couldn't sync in-mem cache", err)
 	}
-	log.Print("In-mem cache: synced")
+	slog.Info("in-mem cache: synced")
 
 	tenant, err := service.NewTenant(inmem, notion.ExtConfig{
 		ClientID:     env.Notion.ClientID,
 		ClientSecret: env.Notion.ClientSecret,
 		RedirectURI:  env.Notion.RedirectURI,
-	})
+		APIURL:       env.Notion.APIURL,
+	}, clients)
 	if err != nil {
-		log.Fatalf("Tenant Service: %s", err)
-		return
+		fatal("couldn't initialise tenant service", err)
 	}
-	log.Print("Tenant Service: OK")
+	slog.Info("tenant service: ok")
 
-	table, err := service.NewTable(inmem)
+	table, err := service.NewTable(inmem, clients)
 	if err != nil {
-		log.Fatalf("Table Service: %s", err)
-		return
+		fatal("couldn't initialise table service", err)
 	}
-	log.Print("Table Service: OK")
+	slog.Info("table service: ok")
+
+	conc := service.Concurrency{
+		Workspaces:  env.Worker.Workspaces,
+		Tables:      env.Worker.Tables,
+		PageWorkers: env.Worker.PageWorkers,
+	}
+	if err := tenant.SetConcurrency(conc); err != nil {
+		fatal("couldn't configure tenant service", err)
+	}
+	if err := table.SetConcurrency(conc); err != nil {
+		fatal("couldn't configure table service", err)
+	}
+	if err := table.SetFullScanInterval(env.Worker.FullScanInterval); err != nil {
+		fatal("couldn't configure table service", err)
+	}
+	if err := tenant.SetSchedule(env.Worker.Schedule); err != nil {
+		fatal("couldn't configure tenant service", err)
+	}
+	slog.Info("worker concurrency: ok",
+		slog.Int("workspaces", conc.Workspaces),
+		slog.Int("tables", conc.Tables),
+		slog.Int("page_workers", conc.PageWorkers),
+	)
 
 	// in case of the internal Notion extension - precreate the workspace.
 	if env.Notion.ExtMode == NotionExtModeInternal {
@@ -81,61 +141,124 @@ func main() {
 			env.Notion.ClientSecret,
 		)
 		if err != nil {
-			log.Fatalf("Notion Ext Mode: %s", err)
-			return
+			fatal("couldn't compose the internal workspace", err)
 		}
 		_, err = tenant.RegisterWorkspace(ctx, ws)
 		if err != nil {
-			log.Fatalf("Notion Ext Mode: Internal initial register: %s", err)
-			return
+			fatal("couldn't register the internal workspace", err)
 		}
 	}
 
-	go func(ctx context.Context, procWssCount int64) {
-		log.Printf("Worker: started")
+	go func(ctx context.Context, procWssCount int64, pollInterval time.Duration) {
+		slog.Info("worker: started", slog.Duration("poll_interval", pollInterval))
 		for {
+			wait := pollInterval
 			err := tenant.ProcOldestUpdated(ctx, procWssCount, table.ProcWs)
 			switch {
+			case err == autocounter.ErrNoResults:
+				wait = max(wait, idleWorkerPause)
 			case errors.Is(err, context.DeadlineExceeded):
 			case errors.Is(err, context.Canceled):
 			case err != nil:
-				log.Printf("Worker: couldn't process tables: %s", err)
+				slog.Error("worker: couldn't process tables", logging.Err(err))
 			}
 
 			select {
 			case <-ctx.Done():
 				return
-			default:
+			case <-time.After(wait):
 			}
 		}
-	}(ctx, env.Notion.ProcWss)
+	}(ctx, env.Notion.ProcWss, env.Worker.PollInterval)
+
+	fills, err := service.NewFillQueue(table, fillQueueSize)
+	if err != nil {
+		fatal("couldn't initialise fill queue", err)
+	}
+	go fills.Run(ctx, conc.Workspaces) // nolint: errcheck
+	slog.Info("fill queue: ok", slog.Bool("webhooks", env.Notion.WebhookSecret != ""))
+
+	var state *oauthstate.Signer
+	if env.Notion.ExtMode == NotionExtModePublic {
+		state, err = oauthstate.New([]byte(env.Auth.StateSecret), authStateTTL)
+		if err != nil {
+			fatal("couldn't initialise oauth state", err)
+		}
+	}
 
 	h, err := http.New(ctx, http.Dep{
-		Tenant:     tenant,
-		Table:      table,
-		IsInternal: env.Notion.ExtMode == NotionExtModeInternal,
+		Tenant:         tenant,
+		Table:          table,
+		IsInternal:     env.Notion.ExtMode == NotionExtModeInternal,
+		State:          state,
+		AuthSuccessURL: env.Auth.SuccessURL,
+		AuthFailureURL: env.Auth.FailureURL,
+		AdminToken:     env.Admin.Token,
+		Fills:          fills,
+		WebhookSecret:  env.Notion.WebhookSecret,
 	})
 	if err != nil {
-		log.Fatalf("HTTP Handler: %s", err)
-		return
+		fatal("couldn't initialise http handler", err)
 	}
-	log.Print("HTTP Handler: OK")
+	slog.Info("http handler: ok", slog.Bool("admin_api", env.Admin.Token != ""))
 
 	host := fmt.Sprintf(":%s", env.HTTP.Port)
 
-	log.Printf("Server: listening and serving on host %s", host)
+	slog.Info("server: listening and serving", slog.String("host", host))
 	if err := ListenAndServe(ctx, host, h); err != nil {
-		log.Printf("Server exited with an error: %s", err)
+		slog.Error("server: exited with an error", logging.Err(err))
+	}
+
+	slog.Info("bye")
+}
+
+// fatal logs the error and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
+}
+
+func newStorage(ctx context.Context, env Env) (storage.Storage, error) {
+	switch env.Storage.Driver {
+	case StorageDriverPostgres:
+		return postgres.New(ctx, env.Storage.PostgresDSN)
+	case StorageDriverBolt:
+		return bolt.New(env.Storage.BoltPath)
+	default:
+		return datastore.New(ctx, env.GCloud.ProjectID)
+	}
+}
+
+// newEncrypted wraps the storage with the token encryption
+// and re-encrypts the tokens that are stored in plaintext or encrypted with the old keys.
+func newEncrypted(ctx context.Context, s storage.Storage, env Env) (*encrypted.Instance, *envelope.Envelope, error) {
+	kp, err := envelope.LoadLocalKeyProvider(env.Token.KeyFile, env.Token.OldKeyFiles...)
+	if err != nil {
+		return nil, nil, err
+	}
+	e, err := envelope.New(kp)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, err := encrypted.New(s, e)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n, err := enc.Rotate(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't rotate the keys: %w", err)
 	}
+	slog.Info("token encryption: ok", slog.String("key_id", kp.KeyID()), slog.Int("reencrypted", n))
 
-	log.Print("Bye.")
+	return enc, e, nil
 }
 
 func listenSig(callback func()) {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	<-sigs
-	log.Printf("Service: Received SIGINT/SIGTERM. Allowing %s to shutdown gracefully.", shutdownTO)
+	slog.Info("received SIGINT/SIGTERM: shutting down gracefully", slog.Duration("timeout", shutdownTO))
 	time.Sleep(shutdownTO)
 	callback()
 }
@@ -156,18 +279,18 @@ func ListenAndServe(ctx context.Context, addr string, h *http.Handler) error {
 	}
 	go func() {
 		<-ctx.Done()
-		log.Print("Server: exit signal received: exiting")
+		slog.Info("server: exit signal received: exiting")
 		if err := httpServer.Shutdown(context.Background()); err != nil {
-			log.Printf("Server: couldn't close: %s", err)
+			slog.Error("server: couldn't close", logging.Err(err))
 		}
 	}()
 
 	err := httpServer.ListenAndServe()
 	switch {
 	case err == gohttp.ErrServerClosed:
-		log.Print("Server: closed")
+		slog.Info("server: closed")
 	case err != nil:
-		log.Printf("Server: exited: %s", err)
+		slog.Error("server: exited", logging.Err(err))
 	}
 
 	return err
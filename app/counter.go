@@ -0,0 +1,107 @@
+package autocounter
+
+import (
+	"errors"
+	"time"
+)
+
+// Counter is the high-water mark of the values issued for the Table.
+type Counter struct {
+	TableID     string    `json:"tableId"`
+	WorkspaceID string    `json:"workspaceId"`
+	Value       int64     `json:"value"`
+	UpdatedAt   time.Time `json:"updatedAt,omitempty"`
+}
+
+// Sequence returns the key of the Table pages numbered by their own Counter, e.g. "WEB@2026".
+// Returns empty key for the pages numbered by the Counter of the whole Table.
+func Sequence(group, period string) string {
+	if period == "" {
+		return group
+	}
+
+	return group + "@" + period
+}
+
+// CounterID returns the ID of the Counter of the Table sequence.
+// The pages without a group and period, as well as the pages of the Tables that aren't grouped or reset, share the Counter of the Table.
+func CounterID(tableID, sequence string) string {
+	if sequence == "" {
+		return tableID
+	}
+
+	return tableID + "/" + sequence
+}
+
+// Reservation of the consecutive Counter values.
+type Reservation struct {
+	// Count of the values to reserve.
+	Count int64
+	// Step between the reserved values.
+	Step int64
+	// Floor is the lowest last issued value the reservation starts after.
+	// It allows to seed the new counters and to respect the start value of the Table.
+	Floor int64
+}
+
+// Validate the Reservation.
+func (r *Reservation) Validate() error {
+	switch {
+	case r.Count < 1:
+		return errors.New("count must be positive")
+	case r.Step < 1:
+		return errors.New("step must be positive")
+	}
+
+	return nil
+}
+
+// Values returns the reserved values that end with the provided last one.
+func (r Reservation) Values(last int64) []int64 {
+	vals := make([]int64, r.Count)
+	for i := range vals {
+		vals[i] = last - int64(len(vals)-1-i)*r.Step
+	}
+
+	return vals
+}
+
+// NewCounter returns the Counter that was never reserved from.
+// The first reservation starts right after its floor.
+func NewCounter(wsID, tableID string, r Reservation) Counter {
+	return Counter{
+		TableID:     tableID,
+		WorkspaceID: wsID,
+		Value:       r.Floor,
+	}
+}
+
+// Reserve returns the reserved values along with the Counter that has them issued.
+// The Counter never goes backwards.
+func (c Counter) Reserve(r Reservation) (Counter, []int64) {
+	last := c.Value
+	if last < r.Floor {
+		last = r.Floor
+	}
+
+	c.Value = last + r.Count*r.Step
+	c.UpdatedAt = time.Now()
+
+	return c, r.Values(c.Value)
+}
+
+// Release the values reserved after `to`, so they're issued again by the next reservation.
+// Returns ErrCounterMoved in case if the last issued value isn't `last` anymore, e.g. the values were reserved since.
+func (c Counter) Release(last, to int64) (Counter, error) {
+	switch {
+	case c.Value != last:
+		return Counter{}, ErrCounterMoved
+	case to > last:
+		return Counter{}, errors.New("counter can't be released forward")
+	}
+
+	c.Value = to
+	c.UpdatedAt = time.Now()
+
+	return c, nil
+}
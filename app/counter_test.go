@@ -0,0 +1,81 @@
+package autocounter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterReserve(t *testing.T) {
+	t.Run("new counter starts right after its floor", func(t *testing.T) {
+		r := Reservation{Count: 3, Step: 1, Floor: 9}
+		c := NewCounter("ws", "table", r)
+		assert.Equal(t, int64(9), c.Value)
+
+		c, vals := c.Reserve(r)
+		assert.Equal(t, []int64{10, 11, 12}, vals)
+		assert.Equal(t, int64(12), c.Value)
+		assert.False(t, c.UpdatedAt.IsZero())
+	})
+
+	t.Run("floor above the value moves the counter forward", func(t *testing.T) {
+		c := Counter{Value: 5}
+		c, vals := c.Reserve(Reservation{Count: 2, Step: 1, Floor: 100})
+		assert.Equal(t, []int64{101, 102}, vals)
+		assert.Equal(t, int64(102), c.Value)
+	})
+
+	t.Run("floor below the value is ignored", func(t *testing.T) {
+		c := Counter{Value: 100}
+		c, vals := c.Reserve(Reservation{Count: 1, Step: 1, Floor: 5})
+		assert.Equal(t, []int64{101}, vals)
+		assert.Equal(t, int64(101), c.Value)
+	})
+
+	t.Run("values are spaced by the step", func(t *testing.T) {
+		c := Counter{Value: 10}
+		c, vals := c.Reserve(Reservation{Count: 3, Step: 5})
+		assert.Equal(t, []int64{15, 20, 25}, vals)
+		assert.Equal(t, int64(25), c.Value)
+	})
+}
+
+func TestReservation(t *testing.T) {
+	t.Run("validates the count and the step", func(t *testing.T) {
+		assert.NoError(t, (&Reservation{Count: 1, Step: 1}).Validate())
+		assert.Error(t, (&Reservation{Count: 0, Step: 1}).Validate())
+		assert.Error(t, (&Reservation{Count: -1, Step: 1}).Validate())
+		assert.Error(t, (&Reservation{Count: 1, Step: 0}).Validate())
+	})
+
+	t.Run("values end with the last one", func(t *testing.T) {
+		assert.Equal(t, []int64{3, 4, 5}, Reservation{Count: 3, Step: 1}.Values(5))
+		assert.Equal(t, []int64{1, 11, 21}, Reservation{Count: 3, Step: 10}.Values(21))
+		assert.Empty(t, Reservation{Count: 0, Step: 1}.Values(5))
+	})
+}
+
+func TestCounterRelease(t *testing.T) {
+	c, _ := Counter{Value: 10}.Reserve(Reservation{Count: 3, Step: 1})
+
+	t.Run("moves the counter back", func(t *testing.T) {
+		released, err := c.Release(13, 11)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(11), released.Value)
+
+		_, vals := released.Reserve(Reservation{Count: 2, Step: 1})
+		assert.Equal(t, []int64{12, 13}, vals, "released values are issued again")
+	})
+
+	t.Run("is rejected once the counter moved", func(t *testing.T) {
+		moved, _ := c.Reserve(Reservation{Count: 1, Step: 1})
+		_, err := moved.Release(13, 11)
+		assert.Equal(t, ErrCounterMoved, err)
+	})
+
+	t.Run("is rejected forward", func(t *testing.T) {
+		_, err := c.Release(13, 14)
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrCounterMoved, err)
+	})
+}
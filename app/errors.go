@@ -11,4 +11,7 @@ var (
 	ErrNoResults         error = errors.New("no results")
 	ErrIncompatibleTable error = errors.New("incompatbile table")
 	ErrUnauthorized      error = errors.New("unauthorized")
+	ErrRateLimited       error = errors.New("rate limited")
+	ErrCounterMoved      error = errors.New("counter was moved")
+	ErrLeaseHeld         error = errors.New("lease is held by another holder")
 )
@@ -0,0 +1,226 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/service"
+)
+
+// adminWorkspace as returned by the admin API.
+type adminWorkspace struct {
+	autocounter.Workspace
+	// Token shadows the one of the Workspace, so it's never exposed.
+	Token string `json:"token,omitempty"`
+
+	Tables []adminTable `json:"tables,omitempty"`
+}
+
+// adminTable as returned by the admin API.
+type adminTable struct {
+	autocounter.Table
+	LastFill *service.FillReport `json:"lastFill,omitempty"`
+}
+
+func newAdminWorkspace(ws autocounter.Workspace) adminWorkspace {
+	return adminWorkspace{Workspace: ws}
+}
+
+func (h *Handler) newAdminTable(t autocounter.Table) adminTable {
+	res := adminTable{Table: t}
+	if lf, ok := h.d.Table.LastFill(t.WorkspaceID, t.ID); ok {
+		res.LastFill = &lf
+	}
+	return res
+}
+
+// registerAdmin routes under /v1/admin authorised with the admin token.
+func (h *Handler) registerAdmin(mw MiddlewareChain) {
+	mw = mw.Chain(BearerAuthMiddleware(h.d.AdminToken))
+
+	h.hr.GET("/v1/admin/workspaces", mw.Wrap(h.GetAdminWorkspaces))
+	h.hr.GET("/v1/admin/workspaces/:ws", mw.Wrap(h.GetAdminWorkspace))
+	h.hr.DELETE("/v1/admin/workspaces/:ws", mw.Wrap(h.DeleteAdminWorkspace))
+	h.hr.POST("/v1/admin/workspaces/:ws/tables/:table/enable", mw.Wrap(h.PostAdminTableEnable))
+	h.hr.POST("/v1/admin/workspaces/:ws/tables/:table/disable", mw.Wrap(h.PostAdminTableDisable))
+	h.hr.GET("/v1/admin/workspaces/:ws/tables/:table/fill", mw.Wrap(h.GetAdminTableFill))
+	h.hr.GET("/v1/admin/workspaces/:ws/tables/:table/holes", mw.Wrap(h.GetAdminTableHoles))
+}
+
+// GetAdminWorkspaces lists all the registered workspaces.
+func (h *Handler) GetAdminWorkspaces(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	wss, err := h.d.Tenant.Workspaces(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "couldn't list the workspaces", logging.Err(err))
+		WriteInternalServerErr(w)
+		return
+	}
+
+	res := make([]adminWorkspace, 0, len(wss))
+	for _, ws := range wss {
+		res = append(res, newAdminWorkspace(ws))
+	}
+
+	WriteJSON(w, http.StatusOK, res)
+}
+
+// GetAdminWorkspace returns the workspace together with all its tables.
+func (h *Handler) GetAdminWorkspace(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := logging.WithWorkspace(r.Context(), ps.ByName("ws"))
+
+	ws, ok := h.findWorkspace(w, r, ps.ByName("ws"))
+	if !ok {
+		return
+	}
+
+	tables, err := h.d.Table.ListAll(ctx, ws.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "couldn't list the tables", logging.Err(err))
+		WriteInternalServerErr(w)
+		return
+	}
+
+	res := newAdminWorkspace(ws)
+	for _, t := range tables {
+		res.Tables = append(res.Tables, h.newAdminTable(t))
+	}
+
+	WriteJSON(w, http.StatusOK, res)
+}
+
+// DeleteAdminWorkspace force-unregisters the workspace together with its tables.
+func (h *Handler) DeleteAdminWorkspace(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ctx := logging.WithWorkspace(r.Context(), ps.ByName("ws"))
+
+	ws, ok := h.findWorkspace(w, r, ps.ByName("ws"))
+	if !ok {
+		return
+	}
+
+	if err := h.d.Tenant.UnregisterWorkspace(ctx, ws.ID); err != nil {
+		slog.ErrorContext(ctx, "couldn't unregister the workspace", logging.Err(err))
+		WriteInternalServerErr(w)
+		return
+	}
+	slog.InfoContext(ctx, "workspace unregistered by the admin")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostAdminTableEnable enables the disabled or paused table.
+func (h *Handler) PostAdminTableEnable(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.setAdminTableStatus(w, r, ps, h.d.Table.Enable)
+}
+
+// PostAdminTableDisable pauses the table, so it's not filled until enabled by the admin.
+func (h *Handler) PostAdminTableDisable(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.setAdminTableStatus(w, r, ps, h.d.Table.Pause)
+}
+
+// GetAdminTableFill returns the result of the latest table fill.
+func (h *Handler) GetAdminTableFill(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	_, table, ok := h.findTable(w, r, ps)
+	if !ok {
+		return
+	}
+
+	lf, ok := h.d.Table.LastFill(table.WorkspaceID, table.ID)
+	if !ok {
+		WriteHTTPErr(w, http.StatusNotFound, NewHTTPErr(
+			HTTPErrCodeUnknownTable,
+			"Table wasn't filled yet",
+			"Fill results are kept only since the service start",
+		))
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, lf)
+}
+
+// GetAdminTableHoles reconciles the table and returns the holes in its numbering.
+func (h *Handler) GetAdminTableHoles(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ws, table, ok := h.findTable(w, r, ps)
+	if !ok {
+		return
+	}
+
+	res, err := h.d.Table.Reconcile(r.Context(), table.ID, ws)
+	if errors.Is(err, autocounter.ErrIncompatibleTable) {
+		WriteHTTPErr(w, http.StatusBadRequest, NewHTTPErr(
+			HTTPErrCodeUnsupportedTable,
+			"Holes aren't reconciled for this table",
+			"Grouped and dated tables are numbered by several counters or with the dates",
+		))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(logging.WithTable(logging.WithWorkspace(r.Context(), ws.ID), table.ID), "couldn't reconcile the table", logging.Err(err))
+		WriteInternalServerErr(w)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, res)
+}
+
+type setStatusFunc func(ctx context.Context, wsID, tableID string) (autocounter.Table, error)
+
+func (h *Handler) setAdminTableStatus(w http.ResponseWriter, r *http.Request, ps httprouter.Params, set setStatusFunc) {
+	if _, _, ok := h.findTable(w, r, ps); !ok {
+		return
+	}
+
+	ctx := logging.WithTable(logging.WithWorkspace(r.Context(), ps.ByName("ws")), ps.ByName("table"))
+	table, err := set(ctx, ps.ByName("ws"), ps.ByName("table"))
+	if err != nil {
+		slog.ErrorContext(ctx, "couldn't update the table status", logging.Err(err))
+		WriteInternalServerErr(w)
+		return
+	}
+	slog.InfoContext(ctx, "table status updated by the admin", slog.String("status", table.Status))
+
+	WriteJSON(w, http.StatusOK, h.newAdminTable(table))
+}
+
+// findWorkspace writes the error response and returns false in case if the workspace can't be found.
+func (h *Handler) findWorkspace(w http.ResponseWriter, r *http.Request, wsID string) (autocounter.Workspace, bool) {
+	ws, err := h.d.Tenant.Workspace(r.Context(), wsID)
+	switch {
+	case err == autocounter.ErrNoResults:
+		WriteHTTPErr(w, http.StatusNotFound, NewHTTPErr(HTTPErrCodeUnknownWorkspace, "Workspace isn't registered", ""))
+		return autocounter.Workspace{}, false
+	case err != nil:
+		slog.ErrorContext(r.Context(), "couldn't get the workspace", logging.Err(err))
+		WriteInternalServerErr(w)
+		return autocounter.Workspace{}, false
+	}
+
+	return ws, true
+}
+
+// findTable writes the error response and returns false in case if the table can't be found.
+// The workspace of the table is returned as well.
+func (h *Handler) findTable(w http.ResponseWriter, r *http.Request, ps httprouter.Params) (autocounter.Workspace, autocounter.Table, bool) {
+	ws, ok := h.findWorkspace(w, r, ps.ByName("ws"))
+	if !ok {
+		return autocounter.Workspace{}, autocounter.Table{}, false
+	}
+
+	table, err := h.d.Table.FetchForWs(r.Context(), ps.ByName("ws"), ps.ByName("table"))
+	switch {
+	case err == autocounter.ErrNoResults:
+		WriteHTTPErr(w, http.StatusNotFound, NewHTTPErr(HTTPErrCodeUnknownTable, "Table isn't registered", ""))
+		return autocounter.Workspace{}, autocounter.Table{}, false
+	case err != nil:
+		slog.ErrorContext(r.Context(), "couldn't get the table", logging.Err(err))
+		WriteInternalServerErr(w)
+		return autocounter.Workspace{}, autocounter.Table{}, false
+	}
+
+	return ws, table, true
+}
@@ -0,0 +1,215 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/provider/notion/notiontest"
+	"github.com/notionplusid/core/app/service"
+	"github.com/notionplusid/core/app/storage/bolt"
+)
+
+const adminToken = "admin_token"
+
+func newAdminHandler(t *testing.T) (*Handler, *service.Tenant, *service.Table, *notiontest.Server) {
+	srv := notiontest.NewServer()
+	t.Cleanup(srv.Close)
+
+	s, err := bolt.New(filepath.Join(t.TempDir(), "plusid.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	clients := notion.NewClients(notion.WithAPIURL(srv.URL()), notion.WithClient(srv.Client()))
+	tenant, err := service.NewTenant(s, notion.ExtConfig{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURI:  "https://example.com/v1/auth",
+		APIURL:       srv.URL(),
+	}, clients)
+	require.NoError(t, err)
+	table, err := service.NewTable(s, clients)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ws, err := autocounter.NewWorkspace("ws", "secret_token")
+	require.NoError(t, err)
+	_, err = tenant.RegisterWorkspace(ctx, ws)
+	require.NoError(t, err)
+	at, err := autocounter.New("db", "ws")
+	require.NoError(t, err)
+	require.NoError(t, table.Register(ctx, "ws", at))
+
+	h, err := New(ctx, Dep{
+		Tenant:     tenant,
+		Table:      table,
+		IsInternal: true,
+		AdminToken: adminToken,
+	})
+	require.NoError(t, err)
+
+	return h, tenant, table, srv
+}
+
+func serveAdmin(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if header == nil {
+		header = http.Header{"Authorization": {"Bearer " + adminToken}}
+	}
+	req.Header = header
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestAdminAuth(t *testing.T) {
+	h, _, _, _ := newAdminHandler(t)
+
+	for name, header := range map[string]http.Header{
+		"no token":     {},
+		"wrong bearer": {"Authorization": {"Bearer wrong"}},
+		"wrong key":    {"X-Api-Key": {"wrong"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := serveAdmin(h, http.MethodGet, "/v1/admin/workspaces", header)
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+			var he HTTPErr
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &he))
+			require.Equal(t, HTTPErrCodeUnauthorized, he.Code)
+		})
+	}
+
+	rec := serveAdmin(h, http.MethodGet, "/v1/admin/workspaces", http.Header{"X-Api-Key": {adminToken}})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	t.Run("not served without the token", func(t *testing.T) {
+		h, err := New(context.Background(), Dep{Tenant: h.d.Tenant, Table: h.d.Table, IsInternal: true})
+		require.NoError(t, err)
+
+		rec := serveAdmin(h, http.MethodGet, "/v1/admin/workspaces", nil)
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestAdminWorkspaces(t *testing.T) {
+	ctx := context.Background()
+	h, tenant, _, _ := newAdminHandler(t)
+
+	rec := serveAdmin(h, http.MethodGet, "/v1/admin/workspaces", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "secret_token")
+
+	var wss []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &wss))
+	require.Len(t, wss, 1)
+	require.Equal(t, "ws", wss[0]["id"])
+
+	rec = serveAdmin(h, http.MethodGet, "/v1/admin/workspaces/ws", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "secret_token")
+
+	var ws adminWorkspace
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &ws))
+	require.Len(t, ws.Tables, 1)
+	require.Equal(t, "db", ws.Tables[0].ID)
+	require.Nil(t, ws.Tables[0].LastFill)
+
+	rec = serveAdmin(h, http.MethodGet, "/v1/admin/workspaces/unknown", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = serveAdmin(h, http.MethodDelete, "/v1/admin/workspaces/ws", nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	_, err := tenant.Workspace(ctx, "ws")
+	require.Equal(t, autocounter.ErrNoResults, err)
+
+	rec = serveAdmin(h, http.MethodDelete, "/v1/admin/workspaces/ws", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminTables(t *testing.T) {
+	ctx := context.Background()
+	h, _, table, srv := newAdminHandler(t)
+
+	rec := serveAdmin(h, http.MethodPost, "/v1/admin/workspaces/ws/tables/db/disable", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var at adminTable
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &at))
+	require.Equal(t, autocounter.StatusPaused, at.Status)
+
+	active, err := table.ListAllActive(ctx, "ws")
+	require.True(t, err == nil || err == autocounter.ErrNoResults)
+	require.Empty(t, active)
+
+	rec = serveAdmin(h, http.MethodPost, "/v1/admin/workspaces/ws/tables/db/enable", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &at))
+	require.Equal(t, autocounter.StatusActive, at.Status)
+
+	rec = serveAdmin(h, http.MethodPost, "/v1/admin/workspaces/ws/tables/unknown/enable", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	var he HTTPErr
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &he))
+	require.Equal(t, HTTPErrCodeUnknownTable, he.Code)
+
+	// the table was never filled.
+	rec = serveAdmin(h, http.MethodGet, "/v1/admin/workspaces/ws/tables/db/fill", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// the fill fails as the workspace access was revoked.
+	srv.Revoke("secret_token")
+	ws, err := h.d.Tenant.Workspace(ctx, "ws")
+	require.NoError(t, err)
+	_, err = table.Fill(ctx, "db", ws)
+	require.Error(t, err)
+
+	rec = serveAdmin(h, http.MethodGet, "/v1/admin/workspaces/ws/tables/db/fill", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var lf service.FillReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &lf))
+	require.NotEmpty(t, lf.Error)
+	require.False(t, lf.FinishedAt.Before(lf.StartedAt))
+}
+
+func TestAdminTableHoles(t *testing.T) {
+	ctx := context.Background()
+	h, _, table, srv := newAdminHandler(t)
+
+	srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{
+		autocounter.DefaultTableParamName: notion.PropertyTypeNumber,
+	})
+	srv.AddPage("db", map[string]notion.PageProperty{autocounter.DefaultTableParamName: notiontest.Number(1)})
+	srv.AddPage("db", map[string]notion.PageProperty{autocounter.DefaultTableParamName: notiontest.Number(3)})
+	srv.AddPage("db", nil)
+	ws, err := h.d.Tenant.Workspace(ctx, "ws")
+	require.NoError(t, err)
+	_, err = table.Fill(ctx, "db", ws)
+	require.NoError(t, err)
+
+	rec := serveAdmin(h, http.MethodGet, "/v1/admin/workspaces/ws/tables/db/holes", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res service.Reconciliation
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Equal(t, int64(4), res.Counter)
+	require.Equal(t, []int64{2}, res.Holes)
+
+	// every group has holes of its own.
+	_, err = table.Configure(ctx, "ws", autocounter.Table{ID: "db", GroupBy: "Project"})
+	require.NoError(t, err)
+	rec = serveAdmin(h, http.MethodGet, "/v1/admin/workspaces/ws/tables/db/holes", nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// so does every year.
+	_, err = table.Configure(ctx, "ws", autocounter.Table{ID: "db", Reset: autocounter.PeriodYearly})
+	require.NoError(t, err)
+	rec = serveAdmin(h, http.MethodGet, "/v1/admin/workspaces/ws/tables/db/holes", nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -1,27 +1,88 @@
 package http
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 
 	"github.com/julienschmidt/httprouter"
+
+	"github.com/notionplusid/core/app/internal/logging"
 )
 
+// stateCookie keeps the nonce of the OAuth state issued to the browser.
+const stateCookie = "plusid_auth_state"
+
+// GetAuthStart starts the OAuth flow by redirecting to the Notion authorisation page.
+func (h *Handler) GetAuthStart(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if h.d.IsInternal {
+		writeInternalModeErr(w)
+		return
+	}
+
+	state, nonce, err := h.d.State.Issue()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "couldn't issue the auth state", logging.Err(err))
+		WriteInternalServerErr(w)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    nonce,
+		Path:     "/v1/auth",
+		MaxAge:   int(h.d.State.TTL().Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		// the cookie has to be sent with the top level redirect back from Notion.
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, h.d.Tenant.AuthorizeURL(state), http.StatusFound)
+}
+
 // GetAuth registers the entity and allows to start watching
 // the new tables.
 func (h *Handler) GetAuth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	if h.d.IsInternal {
-		WriteHTTPErr(w, http.StatusGone, NewHTTPErr(
-			HTTPErrCodeGone,
-			"Application is running in `internal` mode",
-			"This endpoint is meant to be used only for the public setup",
+		writeInternalModeErr(w)
+		return
+	}
+
+	// the state can be used only once.
+	http.SetCookie(w, &http.Cookie{
+		Name:   stateCookie,
+		Path:   "/v1/auth",
+		MaxAge: -1,
+	})
+
+	q := r.URL.Query()
+
+	var nonce string
+	if c, err := r.Cookie(stateCookie); err == nil {
+		nonce = c.Value
+	}
+	if err := h.d.State.Verify(q.Get("state"), nonce); err != nil {
+		slog.WarnContext(r.Context(), "rejected the auth callback", logging.Err(err))
+		h.authFailed(w, r, http.StatusForbidden, NewHTTPErr(
+			HTTPErrCodeInvalidAuthState,
+			"`state` is invalid or expired",
+			"Start the authorisation again",
+		))
+		return
+	}
+
+	if e := q.Get("error"); e != "" {
+		h.authFailed(w, r, http.StatusForbidden, NewHTTPErr(
+			HTTPErrCodeAuthDenied,
+			"Authorisation wasn't granted",
+			e,
 		))
 		return
 	}
 
-	code := r.URL.Query().Get("code")
+	code := q.Get("code")
 	if len(code) == 0 {
-		WriteHTTPErr(w, http.StatusUnprocessableEntity, NewHTTPErr(
+		h.authFailed(w, r, http.StatusUnprocessableEntity, NewHTTPErr(
 			HTTPErrCodeNoAuthCode,
 			"`code` is required for the workspace to be registered",
 			"Something is wrong with the Notion redirect?",
@@ -31,17 +92,46 @@ func (h *Handler) GetAuth(w http.ResponseWriter, r *http.Request, _ httprouter.P
 
 	ws, err := h.d.Tenant.AuthWorkspace(r.Context(), code)
 	if err != nil {
-		log.Printf("HTTP: Auth: couldn't authorise a workspace: %s", err)
-		WriteInternalServerErr(w)
+		slog.ErrorContext(r.Context(), "couldn't authorise a workspace", logging.Err(err))
+		h.authFailed(w, r, http.StatusInternalServerError, NewHTTPErr(HTTPErrCodeInternalError, "", ""))
 		return
 	}
 
-	_, err = h.d.Tenant.RegisterWorkspace(r.Context(), ws)
+	ctx := logging.WithWorkspace(r.Context(), ws.ID)
+	_, err = h.d.Tenant.RegisterWorkspace(ctx, ws)
 	if err != nil {
-		log.Printf("HTTP: Auth: couldn't register a workspace: %s", err)
-		WriteInternalServerErr(w)
+		slog.ErrorContext(ctx, "couldn't register a workspace", logging.Err(err))
+		h.authFailed(w, r, http.StatusInternalServerError, NewHTTPErr(HTTPErrCodeInternalError, "", ""))
+		return
+	}
+
+	http.Redirect(w, r, h.d.AuthSuccessURL, http.StatusFound)
+}
+
+// authFailed redirects to the failure URL with the error code or writes the error in case if there's no URL.
+func (h *Handler) authFailed(w http.ResponseWriter, r *http.Request, status int, he HTTPErr) {
+	if h.d.AuthFailureURL == "" {
+		WriteHTTPErr(w, status, he)
 		return
 	}
 
-	http.Redirect(w, r, "https://notionplusid.app/welcome", http.StatusFound)
+	u, err := url.Parse(h.d.AuthFailureURL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "couldn't parse the auth failure url", logging.Err(err))
+		WriteHTTPErr(w, status, he)
+		return
+	}
+	q := u.Query()
+	q.Set("error", string(he.Code))
+	u.RawQuery = q.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func writeInternalModeErr(w http.ResponseWriter) {
+	WriteHTTPErr(w, http.StatusGone, NewHTTPErr(
+		HTTPErrCodeGone,
+		"Application is running in `internal` mode",
+		"This endpoint is meant to be used only for the public setup",
+	))
 }
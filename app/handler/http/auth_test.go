@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/oauthstate"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/provider/notion/notiontest"
+	"github.com/notionplusid/core/app/service"
+	"github.com/notionplusid/core/app/storage/bolt"
+)
+
+const successURL = "https://example.com/welcome"
+
+func newAuthHandler(t *testing.T, failureURL string) (*Handler, *service.Tenant, *notiontest.Server) {
+	srv := notiontest.NewServer()
+	t.Cleanup(srv.Close)
+
+	s, err := bolt.New(filepath.Join(t.TempDir(), "plusid.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	nc := notion.ExtConfig{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURI:  "https://example.com/v1/auth",
+		APIURL:       srv.URL(),
+	}
+	srv.SetOAuthClient(nc.ClientID, nc.ClientSecret)
+	srv.AddOAuthCode("code", notion.OAuth2Res{AccessToken: "token", WorkspaceID: "ws"})
+
+	tenant, err := service.NewTenant(s, nc, notion.NewClients())
+	require.NoError(t, err)
+	table, err := service.NewTable(s, notion.NewClients())
+	require.NoError(t, err)
+	state, err := oauthstate.New([]byte("0123456789abcdef0123456789abcdef"), time.Minute)
+	require.NoError(t, err)
+
+	h, err := New(context.Background(), Dep{
+		Tenant:         tenant,
+		Table:          table,
+		State:          state,
+		AuthSuccessURL: successURL,
+		AuthFailureURL: failureURL,
+	})
+	require.NoError(t, err)
+
+	return h, tenant, srv
+}
+
+func serve(h http.Handler, target string, cookies ...*http.Cookie) *http.Response {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec.Result()
+}
+
+// start the flow and return the state with the cookie set for the browser.
+func start(t *testing.T, h http.Handler, srv *notiontest.Server) (string, *http.Cookie) {
+	res := serve(h, "/v1/auth/start")
+	require.Equal(t, http.StatusFound, res.StatusCode)
+
+	loc, err := url.Parse(res.Header.Get("Location"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(loc.String(), srv.URL()+"/v1/oauth/authorize?"))
+	require.Equal(t, "client", loc.Query().Get("client_id"))
+	require.Equal(t, "https://example.com/v1/auth", loc.Query().Get("redirect_uri"))
+
+	cookies := res.Cookies()
+	require.Len(t, cookies, 1)
+
+	return loc.Query().Get("state"), cookies[0]
+}
+
+func TestGetAuth(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("registers the workspace with the valid state", func(t *testing.T) {
+		h, tenant, srv := newAuthHandler(t, "")
+		state, cookie := start(t, h, srv)
+
+		res := serve(h, "/v1/auth?code=code&state="+url.QueryEscape(state), cookie)
+		require.Equal(t, http.StatusFound, res.StatusCode)
+		require.Equal(t, successURL, res.Header.Get("Location"))
+
+		ws, err := tenant.Workspace(ctx, "ws")
+		require.NoError(t, err)
+		require.Equal(t, "token", ws.Token)
+	})
+
+	t.Run("rejects the state of another browser", func(t *testing.T) {
+		h, tenant, srv := newAuthHandler(t, "")
+		state, _ := start(t, h, srv)
+		_, otherCookie := start(t, h, srv)
+
+		res := serve(h, "/v1/auth?code=code&state="+url.QueryEscape(state), otherCookie)
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		res = serve(h, "/v1/auth?code=code&state="+url.QueryEscape(state))
+		require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+		_, err := tenant.Workspace(ctx, "ws")
+		require.Equal(t, autocounter.ErrNoResults, err)
+	})
+
+	t.Run("redirects to the failure url", func(t *testing.T) {
+		h, _, srv := newAuthHandler(t, "https://example.com/failed?lang=en")
+
+		res := serve(h, "/v1/auth?code=code&state=forged")
+		require.Equal(t, http.StatusFound, res.StatusCode)
+		require.Equal(t, "https://example.com/failed?error=invalid_auth_state&lang=en", res.Header.Get("Location"))
+
+		state, cookie := start(t, h, srv)
+		res = serve(h, "/v1/auth?error=access_denied&state="+url.QueryEscape(state), cookie)
+		require.Equal(t, http.StatusFound, res.StatusCode)
+		require.Equal(t, "https://example.com/failed?error=auth_denied&lang=en", res.Header.Get("Location"))
+	})
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+)
+
+// PostTableFill fills the table right away and responds with the result of the fill.
+// Never overlaps with the background fill of the same table: waits for it to finish instead.
+func (h *Handler) PostTableFill(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	ws, table, ok := h.findTable(w, r, ps)
+	if !ok {
+		return
+	}
+
+	if table.Status != autocounter.StatusActive {
+		WriteHTTPErr(w, http.StatusUnprocessableEntity, NewHTTPErr(
+			HTTPErrCodeUnfillableTable,
+			"Table isn't active",
+			"Table is "+table.Status,
+		))
+		return
+	}
+
+	res, err := h.d.Table.FillNow(r.Context(), table.ID, ws)
+	if err != nil {
+		slog.ErrorContext(logging.WithTable(logging.WithWorkspace(r.Context(), ws.ID), table.ID), "couldn't fill the table", logging.Err(err))
+		WriteHTTPErr(w, http.StatusBadGateway, NewHTTPErr(HTTPErrCodeFillFailed, "Table couldn't be filled", err.Error()))
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, res)
+}
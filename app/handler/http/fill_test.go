@@ -0,0 +1,47 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/service"
+)
+
+func TestPostTableFill(t *testing.T) {
+	ctx := context.Background()
+	h, _, table, srv := newAdminHandler(t)
+
+	srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{
+		autocounter.DefaultTableParamName: notion.PropertyTypeNumber,
+	})
+	srv.AddPage("db", nil)
+	srv.AddPage("db", nil)
+
+	rec := serveAdmin(h, http.MethodPost, "/v1/workspaces/ws/tables/db/fill", http.Header{})
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = serveAdmin(h, http.MethodPost, "/v1/workspaces/ws/tables/db/fill", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var res service.FillReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Equal(t, int64(2), res.Succeeded)
+	require.Equal(t, int64(2), res.Counter)
+	require.Empty(t, res.Failed)
+
+	rec = serveAdmin(h, http.MethodPost, "/v1/workspaces/ws/tables/unknown/fill", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	_, err := table.Pause(ctx, "ws", "db")
+	require.NoError(t, err)
+	rec = serveAdmin(h, http.MethodPost, "/v1/workspaces/ws/tables/db/fill", nil)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var he HTTPErr
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &he))
+	require.Equal(t, HTTPErrCodeUnfillableTable, he.Code)
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 
+	"github.com/notionplusid/core/app/internal/metrics"
+	"github.com/notionplusid/core/app/internal/oauthstate"
 	"github.com/notionplusid/core/app/service"
 )
 
@@ -17,6 +19,25 @@ type Dep struct {
 	Table  *service.Table
 
 	IsInternal bool
+
+	// State signs the OAuth state, required for the public setup.
+	State *oauthstate.Signer
+	// AuthSuccessURL where the browser is redirected once the workspace is registered.
+	AuthSuccessURL string
+	// AuthFailureURL where the browser is redirected in case if the authorisation failed.
+	// The error response is written instead if empty.
+	AuthFailureURL string
+
+	// AdminToken authorises the admin API and the on-demand fill requests.
+	// Neither is served if empty.
+	AdminToken string
+
+	// Fills queues the table fills requested by the Notion webhooks.
+	// The webhooks aren't served if nil.
+	Fills *service.FillQueue
+	// WebhookSecret is the verification token the Notion webhook events are signed with.
+	// Only the subscription verification requests are accepted if empty.
+	WebhookSecret string
 }
 
 // Validate the Dep.
@@ -26,6 +47,11 @@ func (d Dep) Validate() error {
 		return errors.New("tenant is required")
 	case d.Table == nil:
 		return errors.New("table is required")
+	case d.IsInternal:
+	case d.State == nil:
+		return errors.New("state is required")
+	case d.AuthSuccessURL == "":
+		return errors.New("auth success url is required")
 	}
 	return nil
 }
@@ -53,7 +79,19 @@ func New(ctx context.Context, dep Dep) (*Handler, error) {
 		w.Write([]byte("ok")) // nolint: errcheck
 	})
 
+	h.hr.Handler(http.MethodGet, "/metrics", metrics.Handler())
+
 	h.hr.GET("/v1/auth", mw.Wrap(h.GetAuth))
+	h.hr.GET("/v1/auth/start", mw.Wrap(h.GetAuthStart))
+	if dep.AdminToken != "" {
+		h.registerAdmin(mw)
+		h.hr.POST("/v1/workspaces/:ws/tables/:table/fill", mw.Chain(BearerAuthMiddleware(dep.AdminToken)).Wrap(h.PostTableFill))
+	}
+
+	if dep.Fills != nil {
+		h.hr.POST("/v1/webhooks/notion", mw.Wrap(h.PostNotionWebhook))
+	}
+
 	h.hr.GET("/_ah/warmup", func(_ http.ResponseWriter, _ *http.Request, _ httprouter.Params) {})
 
 	return h, nil
@@ -1,7 +1,9 @@
 package http
 
 import (
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -47,3 +49,27 @@ func JSONContentMiddleware(h httprouter.Handle) httprouter.Handle {
 		h(w, r, ps)
 	}
 }
+
+// BearerAuthMiddleware allows only the requests authorised with the provided token,
+// either as `Authorization: Bearer <token>` or as `X-API-Key: <token>`.
+func BearerAuthMiddleware(token string) Middleware {
+	return func(h httprouter.Handle) httprouter.Handle {
+		return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			provided := r.Header.Get("X-API-Key")
+			if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				provided = strings.TrimPrefix(auth, "Bearer ")
+			}
+
+			if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				WriteHTTPErr(w, http.StatusUnauthorized, NewHTTPErr(
+					HTTPErrCodeUnauthorized,
+					"Valid API token is required",
+					"Provide it with `Authorization: Bearer <token>` or `X-API-Key` header",
+				))
+				return
+			}
+
+			h(w, r, ps)
+		}
+	}
+}
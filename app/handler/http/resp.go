@@ -2,8 +2,10 @@ package http
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+
+	"github.com/notionplusid/core/app/internal/logging"
 )
 
 // HTTPErrCode as returned by the server.
@@ -16,11 +18,18 @@ const (
 	HTTPErrCodeGone          HTTPErrCode = "gone"
 
 	HTTPErrCodeUnfillableTable HTTPErrCode = "unfillable_table"
+	HTTPErrCodeFillFailed      HTTPErrCode = "fill_failed"
 	HTTPErrCodeNoTables        HTTPErrCode = "no_tables"
 
-	HTTPErrCodeNoAuthCode HTTPErrCode = "no_auth_code"
+	HTTPErrCodeNoAuthCode       HTTPErrCode = "no_auth_code"
+	HTTPErrCodeInvalidAuthState HTTPErrCode = "invalid_auth_state"
+	HTTPErrCodeAuthDenied       HTTPErrCode = "auth_denied"
 
 	HTTPErrCodeUnknownWorkspace HTTPErrCode = "unknown_workspace"
+	HTTPErrCodeUnknownTable     HTTPErrCode = "unknown_table"
+	HTTPErrCodeUnsupportedTable HTTPErrCode = "unsupported_table"
+
+	HTTPErrCodeInvalidWebhook HTTPErrCode = "invalid_webhook"
 )
 
 // HTTPErr returned by the server in case of errors.
@@ -43,7 +52,7 @@ func NewHTTPErr(c HTTPErrCode, msg, desc string) HTTPErr {
 func WriteHTTPErr(w http.ResponseWriter, status int, he HTTPErr) {
 	b, err := json.Marshal(&he)
 	if err != nil {
-		log.Printf("Handler: WriteHTTPErr: couldn't write response: %s", err)
+		slog.Error("couldn't write the error response", logging.Err(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -57,3 +66,17 @@ func WriteHTTPErr(w http.ResponseWriter, status int, he HTTPErr) {
 func WriteInternalServerErr(w http.ResponseWriter) {
 	WriteHTTPErr(w, http.StatusInternalServerError, NewHTTPErr(HTTPErrCodeInternalError, "", ""))
 }
+
+// WriteJSON response with the provided status.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("couldn't write the response", logging.Err(err))
+		WriteInternalServerErr(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b) // nolint: errcheck
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/internal/metrics"
+	"github.com/notionplusid/core/app/provider/notion"
+)
+
+// maxWebhookBody is way above the size of the Notion events, which carry the IDs only.
+const maxWebhookBody = 1 << 20
+
+// PostNotionWebhook accepts the Notion webhook events signed with the WebhookSecret
+// and queues the fills of the tables whose pages were created or updated.
+// The events that don't concern the registered tables are acknowledged and ignored.
+func (h *Handler) PostNotionWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ctx := r.Context()
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		WriteHTTPErr(w, http.StatusBadRequest, NewHTTPErr(HTTPErrCodeInvalidWebhook, "Couldn't read the request body", err.Error()))
+		return
+	}
+
+	var e notion.WebhookEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		metrics.WebhookEvents.WithLabelValues(metrics.WebhookResultInvalid).Inc()
+		WriteHTTPErr(w, http.StatusBadRequest, NewHTTPErr(HTTPErrCodeInvalidWebhook, "Event isn't valid JSON", err.Error()))
+		return
+	}
+
+	// the subscription verification request isn't signed, as it delivers the token to sign the events with.
+	// It's accepted only till the token is set as the secret, so the unsigned requests can't pass as one afterwards.
+	if e.VerificationToken != "" && r.Header.Get(notion.WebhookSignatureHeader) == "" {
+		if h.d.WebhookSecret != "" {
+			slog.WarnContext(ctx, "notion webhook: verification rejected: NOTION_WEBHOOK_SECRET is already set")
+			metrics.WebhookEvents.WithLabelValues(metrics.WebhookResultInvalid).Inc()
+			WriteHTTPErr(w, http.StatusUnauthorized, NewHTTPErr(HTTPErrCodeInvalidWebhook, "Webhook secret is already configured", ""))
+			return
+		}
+
+		// the token itself is the secret of the events, so only its fingerprint is logged.
+		slog.WarnContext(ctx, "notion webhook: verification token received: confirm it in the integration settings and set it as NOTION_WEBHOOK_SECRET",
+			slog.String("verification_token_sha256", fingerprint(e.VerificationToken)),
+		)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if h.d.WebhookSecret == "" {
+		slog.WarnContext(ctx, "notion webhook: event rejected: NOTION_WEBHOOK_SECRET is not set")
+		metrics.WebhookEvents.WithLabelValues(metrics.WebhookResultInvalid).Inc()
+		WriteHTTPErr(w, http.StatusUnauthorized, NewHTTPErr(HTTPErrCodeInvalidWebhook, "Webhook secret isn't configured", ""))
+		return
+	}
+	if err := notion.VerifyWebhookSignature(h.d.WebhookSecret, body, r.Header.Get(notion.WebhookSignatureHeader)); err != nil {
+		metrics.WebhookEvents.WithLabelValues(metrics.WebhookResultInvalid).Inc()
+		WriteHTTPErr(w, http.StatusUnauthorized, NewHTTPErr(HTTPErrCodeInvalidWebhook, "Invalid signature", err.Error()))
+		return
+	}
+
+	result := h.queueWebhookFill(r, e)
+	metrics.WebhookEvents.WithLabelValues(result).Inc()
+	slog.DebugContext(ctx, "notion webhook: event received",
+		slog.String("event_id", e.ID),
+		slog.String("event_type", string(e.Type)),
+		slog.String("result", result),
+	)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// queueWebhookFill of the table the event is about and return the result label of the event.
+func (h *Handler) queueWebhookFill(r *http.Request, e notion.WebhookEvent) string {
+	tableID, ok := e.DatabaseID()
+	if !ok {
+		return metrics.WebhookResultIgnored
+	}
+
+	ctx := logging.WithTable(logging.WithWorkspace(r.Context(), e.WorkspaceID), tableID)
+
+	ws, ok := h.webhookWorkspace(r, e.WorkspaceID)
+	if !ok {
+		return metrics.WebhookResultIgnored
+	}
+
+	_, err := h.d.Table.FetchForWs(ctx, ws.ID, tableID)
+	switch {
+	case err == autocounter.ErrNoResults:
+		// the new tables are discovered by the background processing.
+		return metrics.WebhookResultIgnored
+	case err != nil:
+		slog.ErrorContext(ctx, "notion webhook: couldn't fetch the table", logging.Err(err))
+		return metrics.WebhookResultIgnored
+	}
+
+	if !h.d.Fills.Enqueue(ws, tableID) {
+		slog.WarnContext(ctx, "notion webhook: fill queue is full: leaving the table to the background processing")
+		return metrics.WebhookResultDropped
+	}
+
+	return metrics.WebhookResultQueued
+}
+
+// webhookWorkspace returns the registered workspace of the event.
+// The internal workspace is registered with the ID of its own, so it's the only one to match.
+func (h *Handler) webhookWorkspace(r *http.Request, wsID string) (autocounter.Workspace, bool) {
+	ctx := logging.WithWorkspace(r.Context(), wsID)
+
+	ws, err := h.d.Tenant.Workspace(ctx, wsID)
+	switch {
+	case err == nil:
+		return ws, true
+	case err != autocounter.ErrNoResults:
+		slog.ErrorContext(ctx, "notion webhook: couldn't fetch the workspace", logging.Err(err))
+		return autocounter.Workspace{}, false
+	case !h.d.IsInternal:
+		return autocounter.Workspace{}, false
+	}
+
+	wss, err := h.d.Tenant.Workspaces(ctx)
+	if err != nil || len(wss) != 1 {
+		return autocounter.Workspace{}, false
+	}
+
+	return wss[0], true
+}
+
+// fingerprint of the token that is safe to log: the head of its SHA-256 hash.
+func fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:4])
+}
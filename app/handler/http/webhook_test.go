@@ -0,0 +1,130 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/service"
+)
+
+const webhookSecret = "secret_webhook_token"
+
+// signatures of the testdata fixtures made with the webhookSecret.
+var webhookSignatures = map[string]string{
+	"page_created.json":               "sha256=189012fb19744776234015268a72b3cd03707644cfafc2c39284bb517bcd6c1e",
+	"page_properties_updated.json":    "sha256=989157af23d7a2ef639fdf287ca17d1d0ae7eeb49a86b7987b09a4c86acbeb69",
+	"page_created_unknown_table.json": "sha256=7be59029627ee99109470996673a4413e275f6e18ae624e014b6ed9526af62eb",
+	"comment_created.json":            "sha256=8929efad4c44e5d7a5d9b82f77fce83c19c2aea9ad0989b39ed291f23510b128",
+}
+
+func serveWebhook(t *testing.T, h http.Handler, fixture, signature string) *httptest.ResponseRecorder {
+	body, err := os.ReadFile(filepath.Join("testdata", fixture))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/webhooks/notion", bytes.NewReader(body))
+	if signature != "" {
+		req.Header.Set(notion.WebhookSignatureHeader, signature)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestPostNotionWebhook(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, tenant, table, srv := newAdminHandler(t)
+	fills, err := service.NewFillQueue(table, 10)
+	require.NoError(t, err)
+	h, err := New(ctx, Dep{
+		Tenant:        tenant,
+		Table:         table,
+		IsInternal:    true,
+		Fills:         fills,
+		WebhookSecret: webhookSecret,
+	})
+	require.NoError(t, err)
+
+	srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{
+		autocounter.DefaultTableParamName: notion.PropertyTypeNumber,
+	})
+	srv.AddPage("db", nil)
+
+	t.Run("rejects the subscription verification once the secret is set", func(t *testing.T) {
+		rec := serveWebhook(t, h, "verification.json", "")
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Zero(t, fills.Len())
+	})
+
+	t.Run("rejects the events that aren't signed with the secret", func(t *testing.T) {
+		rec := serveWebhook(t, h, "page_created.json", "")
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec = serveWebhook(t, h, "page_created.json", "sha256=00")
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec = serveWebhook(t, h, "page_created.json", webhookSignatures["page_properties_updated.json"])
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Zero(t, fills.Len())
+	})
+
+	t.Run("ignores the events of other tables and objects", func(t *testing.T) {
+		for _, fixture := range []string{"page_created_unknown_table.json", "comment_created.json"} {
+			rec := serveWebhook(t, h, fixture, webhookSignatures[fixture])
+			require.Equal(t, http.StatusOK, rec.Code, fixture)
+		}
+		require.Zero(t, fills.Len())
+	})
+
+	t.Run("queues the table fill once", func(t *testing.T) {
+		for _, fixture := range []string{"page_created.json", "page_properties_updated.json"} {
+			rec := serveWebhook(t, h, fixture, webhookSignatures[fixture])
+			require.Equal(t, http.StatusOK, rec.Code, fixture)
+		}
+		require.Equal(t, 1, fills.Len())
+
+		go fills.Run(ctx, 1) // nolint: errcheck
+		require.Eventually(t, func() bool {
+			n := srv.Pages("db")[0].Properties[autocounter.DefaultTableParamName].Number
+			return n != nil && *n == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Zero(t, fills.Len())
+	})
+}
+
+func TestPostNotionWebhookVerification(t *testing.T) {
+	ctx := context.Background()
+
+	_, tenant, table, _ := newAdminHandler(t)
+	fills, err := service.NewFillQueue(table, 10)
+	require.NoError(t, err)
+	h, err := New(ctx, Dep{
+		Tenant:     tenant,
+		Table:      table,
+		IsInternal: true,
+		Fills:      fills,
+	})
+	require.NoError(t, err)
+
+	t.Run("acknowledges the subscription verification", func(t *testing.T) {
+		rec := serveWebhook(t, h, "verification.json", "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Zero(t, fills.Len())
+	})
+
+	t.Run("rejects the events till the secret is set", func(t *testing.T) {
+		rec := serveWebhook(t, h, "page_created.json", webhookSignatures["page_created.json"])
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.Zero(t, fills.Len())
+	})
+}
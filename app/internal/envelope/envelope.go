@@ -0,0 +1,183 @@
+// Package envelope implements the envelope encryption of the secrets at rest.
+//
+// Every secret is encrypted with its own random data key (DEK),
+// and the data key itself is encrypted (wrapped) with the key encryption key (KEK) of the KeyProvider.
+// Only the wrapped data key is stored next to the secret, so the KEK never leaves the KeyProvider.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// prefix of the encrypted values, followed by the key ID, the wrapped data key and the ciphertext.
+const prefix = "enc:v1:"
+
+const dataKeySize = 32
+
+// ErrUnknownKey is returned when the value was encrypted with the key that isn't known to the KeyProvider.
+var ErrUnknownKey = errors.New("unknown key")
+
+// KeyProvider wraps and unwraps the data keys with the key encryption key.
+// It can be implemented by a local key or by a cloud KMS.
+type KeyProvider interface {
+	// KeyID of the key currently used to wrap the data keys.
+	// Must not contain ':'.
+	KeyID() string
+	// WrapKey encrypts the data key with the current key.
+	WrapKey(ctx context.Context, dek []byte) ([]byte, error)
+	// UnwrapKey decrypts the data key wrapped with the key of the provided ID.
+	// Returns ErrUnknownKey in case if the key isn't available.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Envelope encrypts and decrypts the secrets.
+type Envelope struct {
+	kp KeyProvider
+}
+
+// New Envelope constructor.
+func New(kp KeyProvider) (*Envelope, error) {
+	if kp == nil {
+		return nil, errors.New("key provider is required")
+	}
+	if strings.Contains(kp.KeyID(), ":") {
+		return nil, fmt.Errorf("invalid key id: %s", kp.KeyID())
+	}
+
+	return &Envelope{kp: kp}, nil
+}
+
+// IsEncrypted returns true in case if the value was produced by Encrypt.
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, prefix)
+}
+
+// Encrypt the plaintext with the new data key.
+func (e *Envelope) Encrypt(ctx context.Context, plaintext string) (string, error) {
+	dek := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return "", fmt.Errorf("couldn't generate data key: %w", err)
+	}
+
+	keyID := e.kp.KeyID()
+	wrapped, err := e.kp.WrapKey(ctx, dek)
+	if err != nil {
+		return "", fmt.Errorf("couldn't wrap data key: %w", err)
+	}
+
+	ciphertext, err := Seal(dek, []byte(plaintext), []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawStdEncoding
+	return prefix + keyID + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(ciphertext), nil
+}
+
+// Decrypt the value produced by Encrypt.
+// Values that aren't encrypted are returned as is.
+func (e *Envelope) Decrypt(ctx context.Context, v string) (string, error) {
+	if !IsEncrypted(v) {
+		return v, nil
+	}
+
+	keyID, wrapped, ciphertext, err := parse(v)
+	if err != nil {
+		return "", err
+	}
+
+	dek, err := e.kp.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return "", fmt.Errorf("couldn't unwrap data key: %w", err)
+	}
+
+	plaintext, err := Open(dek, ciphertext, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsRotation returns true in case if the value isn't encrypted or encrypted with the key that isn't current.
+func (e *Envelope) NeedsRotation(v string) bool {
+	if !IsEncrypted(v) {
+		return true
+	}
+
+	keyID, _, _, err := parse(v)
+	if err != nil {
+		return false
+	}
+
+	return keyID != e.kp.KeyID()
+}
+
+func parse(v string) (keyID string, wrapped, ciphertext []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(v, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("malformed encrypted value")
+	}
+
+	enc := base64.RawStdEncoding
+	if wrapped, err = enc.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, fmt.Errorf("malformed wrapped data key: %w", err)
+	}
+	if ciphertext, err = enc.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, fmt.Errorf("malformed ciphertext: %w", err)
+	}
+
+	return parts[0], wrapped, ciphertext, nil
+}
+
+// Seal the plaintext with AES-GCM using the provided key.
+// The random nonce is prepended to the result.
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("couldn't generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open the result of Seal.
+func Open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("sealed value is too short")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decrypt: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,80 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := bytes.Repeat([]byte{1}, keySize), bytes.Repeat([]byte{2}, keySize)
+
+	oldKP, err := NewLocalKeyProvider(oldKey)
+	require.NoError(t, err)
+	oldE, err := New(oldKP)
+	require.NoError(t, err)
+
+	enc, err := oldE.Encrypt(ctx, "secret_token")
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(enc))
+	require.NotContains(t, enc, "secret_token")
+
+	t.Run("decrypts", func(t *testing.T) {
+		v, err := oldE.Decrypt(ctx, enc)
+		require.NoError(t, err)
+		require.Equal(t, "secret_token", v)
+		require.False(t, oldE.NeedsRotation(enc))
+	})
+
+	t.Run("encrypts with the new data key every time", func(t *testing.T) {
+		again, err := oldE.Encrypt(ctx, "secret_token")
+		require.NoError(t, err)
+		require.NotEqual(t, enc, again)
+	})
+
+	t.Run("returns plaintext as is", func(t *testing.T) {
+		v, err := oldE.Decrypt(ctx, "plain")
+		require.NoError(t, err)
+		require.Equal(t, "plain", v)
+		require.True(t, oldE.NeedsRotation("plain"))
+	})
+
+	t.Run("decrypts with the old key after rotation", func(t *testing.T) {
+		kp, err := NewLocalKeyProvider(newKey, oldKey)
+		require.NoError(t, err)
+		e, err := New(kp)
+		require.NoError(t, err)
+
+		require.True(t, e.NeedsRotation(enc))
+		v, err := e.Decrypt(ctx, enc)
+		require.NoError(t, err)
+		require.Equal(t, "secret_token", v)
+	})
+
+	t.Run("fails with the unknown key", func(t *testing.T) {
+		kp, err := NewLocalKeyProvider(newKey)
+		require.NoError(t, err)
+		e, err := New(kp)
+		require.NoError(t, err)
+
+		_, err = e.Decrypt(ctx, enc)
+		require.True(t, errors.Is(err, ErrUnknownKey))
+	})
+
+	t.Run("fails on tampered value", func(t *testing.T) {
+		tampered := []byte(enc)
+		tampered[len(tampered)-2] ^= 1
+
+		_, err := oldE.Decrypt(ctx, string(tampered))
+		require.Error(t, err)
+	})
+
+	t.Run("rejects keys of invalid size", func(t *testing.T) {
+		_, err := NewLocalKeyProvider([]byte("short"))
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,89 @@
+package envelope
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keySize of the AES-256 key encryption key.
+const keySize = 32
+
+// LocalKeyProvider keeps the key encryption keys in memory.
+// Meant for the self-hosted setups where the keys are read from the local files.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+var _ KeyProvider = (*LocalKeyProvider)(nil)
+
+// NewLocalKeyProvider with the current key used for the encryption
+// and the old keys that are still accepted for the decryption, e.g. until the key rotation is over.
+func NewLocalKeyProvider(current []byte, old ...[]byte) (*LocalKeyProvider, error) {
+	kp := &LocalKeyProvider{
+		keys: map[string][]byte{},
+	}
+	for i, key := range append([][]byte{current}, old...) {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %d: expected %d bytes: provided - %d", i, keySize, len(key))
+		}
+
+		id := localKeyID(key)
+		if i == 0 {
+			kp.current = id
+		}
+		kp.keys[id] = key
+	}
+
+	return kp, nil
+}
+
+// LoadLocalKeyProvider reads the base64 encoded keys from the provided files.
+func LoadLocalKeyProvider(currentPath string, oldPaths ...string) (*LocalKeyProvider, error) {
+	var keys [][]byte
+	for _, path := range append([]string{currentPath}, oldPaths...) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read key file: %w", err)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil {
+			return nil, fmt.Errorf("key file %s: expected base64 encoded key: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return NewLocalKeyProvider(keys[0], keys[1:]...)
+}
+
+// localKeyID is derived from the key, so the same key always gets the same ID.
+func localKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "local-" + hex.EncodeToString(sum[:8])
+}
+
+// KeyID of the current key.
+func (kp *LocalKeyProvider) KeyID() string {
+	return kp.current
+}
+
+// WrapKey with the current key.
+func (kp *LocalKeyProvider) WrapKey(_ context.Context, dek []byte) ([]byte, error) {
+	return Seal(kp.keys[kp.current], dek, []byte(kp.current))
+}
+
+// UnwrapKey with the key of the provided ID.
+func (kp *LocalKeyProvider) UnwrapKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := kp.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+
+	return Open(key, wrapped, []byte(keyID))
+}
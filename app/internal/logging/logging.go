@@ -0,0 +1,162 @@
+// Package logging configures the structured logger of the service.
+//
+// The workspace, table and page IDs put into the context.Context with WithWorkspace, WithTable and WithPage
+// are added to every record logged with that context.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Attribute keys.
+const (
+	KeyWorkspaceID = "workspace_id"
+	KeyTableID     = "table_id"
+	KeyPageID      = "page_id"
+	KeyError       = "error"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the attribute keys which values are never logged.
+var sensitiveKeys = map[string]struct{}{
+	"token":         {},
+	"access_token":  {},
+	"authorization": {},
+	"client_secret": {},
+}
+
+// Format of the log output.
+type Format string
+
+var (
+	// FormatText outputs human readable key=value pairs.
+	FormatText Format = "text"
+
+	// FormatJSON outputs a JSON object per line.
+	FormatJSON Format = "json"
+)
+
+var validFormats = []Format{
+	FormatText,
+	FormatJSON,
+}
+
+// Validate the Format.
+func (f *Format) Validate() error {
+	if f == nil {
+		return errors.New("log format is required")
+	}
+	for _, vf := range validFormats {
+		if vf == *f {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown log format: %s", *f)
+}
+
+// ParseLevel parses the level name, e.g. "debug", "info", "warn" or "error".
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level: %s", s)
+	}
+
+	return l, nil
+}
+
+// New Logger that writes the records of the provided level and above to w.
+func New(w io.Writer, format Format, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+
+	var h slog.Handler = slog.NewTextHandler(w, opts)
+	if format == FormatJSON {
+		h = slog.NewJSONHandler(w, opts)
+	}
+
+	return slog.New(contextHandler{h})
+}
+
+// Err attribute of the error.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if _, ok := sensitiveKeys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, redacted)
+	}
+
+	return a
+}
+
+type ctxKey struct{}
+
+// WithWorkspace returns the context which log records are attributed to the workspace.
+func WithWorkspace(ctx context.Context, wsID string) context.Context {
+	return with(ctx, slog.String(KeyWorkspaceID, wsID))
+}
+
+// WithTable returns the context which log records are attributed to the table.
+func WithTable(ctx context.Context, tableID string) context.Context {
+	return with(ctx, slog.String(KeyTableID, tableID))
+}
+
+// WithPage returns the context which log records are attributed to the page.
+func WithPage(ctx context.Context, pageID string) context.Context {
+	return with(ctx, slog.String(KeyPageID, pageID))
+}
+
+func with(ctx context.Context, attr slog.Attr) context.Context {
+	parent := attrs(ctx)
+
+	res := make([]slog.Attr, 0, len(parent)+1)
+	for _, a := range parent {
+		// the attribute is overridden when set again.
+		if a.Key != attr.Key {
+			res = append(res, a)
+		}
+	}
+	res = append(res, attr)
+
+	return context.WithValue(ctx, ctxKey{}, res)
+}
+
+func attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	as, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return as
+}
+
+// contextHandler adds the attributes from the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if as := attrs(ctx); len(as) != 0 {
+		r = r.Clone()
+		r.AddAttrs(as...)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(as)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
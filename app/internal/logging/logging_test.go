@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+)
+
+func TestLogger(t *testing.T) {
+	ws, err := autocounter.NewWorkspace("ws", "secret_token")
+	require.NoError(t, err)
+
+	t.Run("adds context attributes", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := New(&buf, FormatJSON, slog.LevelInfo)
+
+		ctx := WithTable(WithWorkspace(context.Background(), "ws"), "table")
+		l.InfoContext(WithPage(ctx, "page"), "filled")
+		l.InfoContext(WithTable(ctx, "other"), "filled")
+
+		dec := json.NewDecoder(&buf)
+		var rec map[string]interface{}
+		require.NoError(t, dec.Decode(&rec))
+		require.Equal(t, "ws", rec[KeyWorkspaceID])
+		require.Equal(t, "table", rec[KeyTableID])
+		require.Equal(t, "page", rec[KeyPageID])
+
+		rec = nil
+		require.NoError(t, dec.Decode(&rec))
+		require.Equal(t, "other", rec[KeyTableID])
+		require.NotContains(t, rec, KeyPageID)
+	})
+
+	t.Run("never logs the token", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := New(&buf, FormatText, slog.LevelDebug)
+
+		l.Info("workspace", slog.Any("workspace", ws), slog.String("token", ws.Token))
+		l.With("access_token", ws.Token).Info("workspace")
+		l.Info("workspace " + ws.String())
+
+		require.NotContains(t, buf.String(), ws.Token)
+		require.Contains(t, buf.String(), "workspace.id=ws")
+	})
+
+	t.Run("filters by level", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := New(&buf, FormatText, slog.LevelWarn)
+
+		l.Info("skipped")
+		require.Empty(t, buf.String())
+		l.Warn("logged")
+		require.Contains(t, buf.String(), "logged")
+	})
+}
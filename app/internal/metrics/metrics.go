@@ -0,0 +1,176 @@
+// Package metrics holds the Prometheus series exposed by the service.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "plusid"
+
+// Result label values.
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+// Result label values of the webhook events.
+const (
+	WebhookResultQueued  = "queued"
+	WebhookResultIgnored = "ignored"
+	WebhookResultDropped = "dropped"
+	WebhookResultInvalid = "invalid"
+)
+
+// Registry of all the series exposed by the service.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// PagesNumbered counts the pages that got the identifier assigned.
+	PagesNumbered = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pages_numbered_total",
+		Help:      "Pages that got the identifier assigned.",
+	}, []string{"table_id"})
+
+	// PagesFailed counts the pages that couldn't get the identifier assigned.
+	PagesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pages_failed_total",
+		Help:      "Pages that couldn't get the identifier assigned.",
+	}, []string{"table_id"})
+
+	// PagesSkipped counts the pages that weren't patched as the fill was cancelled.
+	PagesSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pages_skipped_total",
+		Help:      "Pages that had the identifier issued but weren't patched as the fill was cancelled.",
+	}, []string{"table_id"})
+
+	// FillDuration of the single table fill.
+	FillDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "table_fill_duration_seconds",
+		Help:      "Duration of the table fills.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"result"})
+
+	// WorkspaceLag is the time since the workspace was due to be processed.
+	// As the worker always picks the most overdue workspaces,
+	// it's the amount of time the worker loop is behind the schedule.
+	WorkspaceLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "workspace_processing_lag_seconds",
+		Help:      "Time since the most overdue workspace picked by the worker was due to be processed.",
+	})
+
+	// WorkspacesQuarantined counts the workspaces quarantined as their passes kept failing.
+	WorkspacesQuarantined = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "workspaces_quarantined_total",
+		Help:      "Workspaces quarantined as their passes kept failing.",
+	})
+
+	// NotionRequests counts the requests sent to the Notion API.
+	NotionRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notion_requests_total",
+		Help:      "Requests sent to the Notion API, including the retries.",
+	}, []string{"endpoint", "code"})
+
+	// NotionRequestDuration is the latency of the Notion API.
+	NotionRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "notion_request_duration_seconds",
+		Help:      "Latency of the Notion API requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "code"})
+
+	// RateLimiterWait is the time the requests wait for the rate limiter.
+	RateLimiterWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ratelimiter_wait_seconds",
+		Help:      "Time the outgoing requests waited for the rate limiter.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	})
+
+	// WebhookEvents counts the Notion webhook events received.
+	WebhookEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_total",
+		Help:      "Notion webhook events received.",
+	}, []string{"result"})
+
+	// CacheSyncs counts the in-memory cache syncs with the storage.
+	CacheSyncs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "inmemcache_syncs_total",
+		Help:      "Syncs of the in-memory cache with the storage.",
+	}, []string{"result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		PagesNumbered,
+		PagesFailed,
+		PagesSkipped,
+		FillDuration,
+		WorkspaceLag,
+		WorkspacesQuarantined,
+		NotionRequests,
+		NotionRequestDuration,
+		RateLimiterWait,
+		WebhookEvents,
+		CacheSyncs,
+	)
+}
+
+// Handler serves the series in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Result label value of the operation that ended up with the provided error.
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultOK
+}
+
+// Transport records the count and the latency of the requests.
+type Transport struct {
+	roundTripperWrap http.RoundTripper
+	endpoint         func(r *http.Request) string
+}
+
+// NewTransport wraps transportWrap with the Notion API request metrics.
+// endpoint returns the label of the request, it's expected to have the object IDs stripped to keep the cardinality low.
+func NewTransport(endpoint func(r *http.Request) string, transportWrap http.RoundTripper) http.RoundTripper {
+	return &Transport{
+		roundTripperWrap: transportWrap,
+		endpoint:         endpoint,
+	}
+}
+
+func (c *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := c.roundTripperWrap.RoundTrip(r)
+
+	code := ResultError
+	if err == nil {
+		code = strconv.Itoa(res.StatusCode)
+	}
+	endpoint := c.endpoint(r)
+	NotionRequests.WithLabelValues(endpoint, code).Inc()
+	NotionRequestDuration.WithLabelValues(endpoint, code).Observe(time.Since(start).Seconds())
+
+	return res, err
+}
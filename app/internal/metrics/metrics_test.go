@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	endpoint := func(r *http.Request) string { return "GET /test" }
+	hc := &http.Client{Transport: NewTransport(endpoint, http.DefaultTransport)}
+
+	for i := 0; i < 2; i++ {
+		res, err := hc.Get(srv.URL)
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+	require.Equal(t, float64(2), testutil.ToFloat64(NotionRequests.WithLabelValues("GET /test", "429")))
+
+	srv.Close()
+	_, err := hc.Get(srv.URL)
+	require.Error(t, err)
+	require.Equal(t, float64(1), testutil.ToFloat64(NotionRequests.WithLabelValues("GET /test", ResultError)))
+
+	require.Equal(t, 2, testutil.CollectAndCount(NotionRequestDuration))
+}
@@ -2,6 +2,7 @@ package mock
 
 import (
 	"context"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 
@@ -45,6 +46,11 @@ func (s *Storage) Tables(ctx context.Context) ([]autocounter.Table, error) {
 	return args.Get(0).([]autocounter.Table), args.Error(1)
 }
 
+func (s *Storage) Table(ctx context.Context, wsID, tableID string) (autocounter.Table, error) {
+	args := s.Called(ctx, wsID, tableID)
+	return args.Get(0).(autocounter.Table), args.Error(1)
+}
+
 func (s *Storage) StoreTable(ctx context.Context, workspaceID string, table autocounter.Table) (autocounter.Table, error) {
 	args := s.Called(ctx, workspaceID, table)
 	return args.Get(0).(autocounter.Table), args.Error(1)
@@ -69,3 +75,43 @@ func (s *Storage) RemoveTablesFromWS(ctx context.Context, wsID string) error {
 	args := s.Called(ctx, wsID)
 	return args.Error(0)
 }
+
+func (s *Storage) StoreWorkspaceSchedule(ctx context.Context, wsID string, nextRunAt time.Time, pass autocounter.Pass) error {
+	args := s.Called(ctx, wsID, nextRunAt, pass)
+	return args.Error(0)
+}
+
+func (s *Storage) StoreTableWatermark(ctx context.Context, wsID, tableID string, w autocounter.Watermark) error {
+	args := s.Called(ctx, wsID, tableID, w)
+	return args.Error(0)
+}
+
+func (s *Storage) Counter(ctx context.Context, wsID, tableID string) (autocounter.Counter, error) {
+	args := s.Called(ctx, wsID, tableID)
+	return args.Get(0).(autocounter.Counter), args.Error(1)
+}
+
+func (s *Storage) ReserveCounter(ctx context.Context, wsID, tableID string, r autocounter.Reservation) ([]int64, error) {
+	args := s.Called(ctx, wsID, tableID, r)
+	return args.Get(0).([]int64), args.Error(1)
+}
+
+func (s *Storage) ReleaseCounter(ctx context.Context, wsID, tableID string, last, to int64) error {
+	args := s.Called(ctx, wsID, tableID, last, to)
+	return args.Error(0)
+}
+
+func (s *Storage) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (autocounter.Lease, error) {
+	args := s.Called(ctx, name, holder, ttl)
+	return args.Get(0).(autocounter.Lease), args.Error(1)
+}
+
+func (s *Storage) ReleaseLease(ctx context.Context, name, holder string) error {
+	args := s.Called(ctx, name, holder)
+	return args.Error(0)
+}
+
+func (s *Storage) ReserveRate(ctx context.Context, key string, interval time.Duration, burst int) (time.Time, error) {
+	args := s.Called(ctx, key, interval, burst)
+	return args.Get(0).(time.Time), args.Error(1)
+}
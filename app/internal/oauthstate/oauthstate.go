@@ -0,0 +1,108 @@
+// Package oauthstate issues and verifies the signed, expiring `state` of the OAuth flow.
+//
+// The state is bound to the browser by the nonce which is expected to be kept in a cookie:
+// the callback is accepted only when the state is signed by us, isn't expired and carries the nonce of the same browser.
+package oauthstate
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	minSecretSize = 16
+	nonceSize     = 16
+)
+
+// Known errors.
+var (
+	ErrInvalidState = errors.New("invalid oauth state")
+	ErrExpiredState = errors.New("expired oauth state")
+)
+
+// Signer of the OAuth states.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// New Signer constructor.
+// Issued states are valid for ttl.
+func New(secret []byte, ttl time.Duration) (*Signer, error) {
+	switch {
+	case len(secret) < minSecretSize:
+		return nil, errors.New("secret is too short")
+	case ttl <= 0:
+		return nil, errors.New("ttl must be positive")
+	}
+
+	return &Signer{
+		secret: secret,
+		ttl:    ttl,
+		now:    time.Now,
+	}, nil
+}
+
+// TTL of the issued states.
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue the new state.
+// The nonce is expected to be kept by the browser and provided to Verify together with the state.
+func (s *Signer) Issue() (state, nonce string, err error) {
+	b := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", "", err
+	}
+
+	nonce = base64.RawURLEncoding.EncodeToString(b)
+	payload := nonce + "." + strconv.FormatInt(s.now().Add(s.ttl).Unix(), 10)
+
+	return payload + "." + s.sign(payload), nonce, nil
+}
+
+// Verify the state issued together with the nonce.
+func (s *Signer) Verify(state, nonce string) error {
+	i := strings.LastIndex(state, ".")
+	if i < 0 {
+		return ErrInvalidState
+	}
+	payload, sig := state[:i], state[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.sign(payload))) {
+		return ErrInvalidState
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 2 {
+		return ErrInvalidState
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(nonce)) != 1 {
+		return ErrInvalidState
+	}
+
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ErrInvalidState
+	}
+	if !s.now().Before(time.Unix(exp, 0)) {
+		return ErrExpiredState
+	}
+
+	return nil
+}
+
+func (s *Signer) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload)) // nolint: errcheck
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,51 @@
+package oauthstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	s, err := New([]byte("0123456789abcdef0123456789abcdef"), time.Minute)
+	require.NoError(t, err)
+
+	state, nonce, err := s.Issue()
+	require.NoError(t, err)
+
+	t.Run("verifies issued state", func(t *testing.T) {
+		require.NoError(t, s.Verify(state, nonce))
+	})
+
+	t.Run("rejects state of another browser", func(t *testing.T) {
+		_, otherNonce, err := s.Issue()
+		require.NoError(t, err)
+
+		require.Equal(t, ErrInvalidState, s.Verify(state, otherNonce))
+		require.Equal(t, ErrInvalidState, s.Verify(state, ""))
+	})
+
+	t.Run("rejects tampered state", func(t *testing.T) {
+		require.Equal(t, ErrInvalidState, s.Verify(state+"x", nonce))
+		require.Equal(t, ErrInvalidState, s.Verify("", nonce))
+
+		other, err := New([]byte("fedcba9876543210fedcba9876543210"), time.Minute)
+		require.NoError(t, err)
+		forged, forgedNonce, err := other.Issue()
+		require.NoError(t, err)
+		require.Equal(t, ErrInvalidState, s.Verify(forged, forgedNonce))
+	})
+
+	t.Run("rejects expired state", func(t *testing.T) {
+		s.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+		defer func() { s.now = time.Now }()
+
+		require.Equal(t, ErrExpiredState, s.Verify(state, nonce))
+	})
+
+	t.Run("requires long enough secret", func(t *testing.T) {
+		_, err := New([]byte("short"), time.Minute)
+		require.Error(t, err)
+	})
+}
@@ -1,21 +1,36 @@
 package ratelimiter
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
+
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/internal/metrics"
 )
 
+// Limiter blocks till the request is allowed to be sent.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
 // ThrottledTransport Rate Limited HTTP Client
 type ThrottledTransport struct {
 	roundTripperWrap http.RoundTripper
-	ratelimiter      *rate.Limiter
+	ratelimiter      Limiter
 }
 
 func (c *ThrottledTransport) RoundTrip(r *http.Request) (*http.Response, error) {
 	// This is a blocking call. Honors the rate limit.
+	start := time.Now()
 	err := c.ratelimiter.Wait(r.Context())
+	metrics.RateLimiterWait.Observe(time.Since(start).Seconds())
 	if err != nil {
 		return nil, err
 	}
@@ -27,8 +42,111 @@ func (c *ThrottledTransport) RoundTrip(r *http.Request) (*http.Response, error)
 // client := http.DefaultClient
 // client.Transport = NewThrottledTransport(10*time.Seconds, 60, http.DefaultTransport) allows 60 requests every 10 seconds
 func NewThrottledTransport(limitPeriod time.Duration, requestCount int, transportWrap http.RoundTripper) http.RoundTripper {
+	return NewLimitedTransport(newLimiter(limitPeriod, requestCount), transportWrap)
+}
+
+// NewLimitedTransport wraps transportWrap with the provided rate limiter,
+// which might be shared with other transports.
+func NewLimitedTransport(limiter Limiter, transportWrap http.RoundTripper) http.RoundTripper {
 	return &ThrottledTransport{
 		roundTripperWrap: transportWrap,
-		ratelimiter:      rate.NewLimiter(rate.Every(limitPeriod), requestCount),
+		ratelimiter:      limiter,
+	}
+}
+
+// newLimiter allows requestCount requests every limitPeriod on average,
+// up to requestCount of them can be sent at once.
+func newLimiter(limitPeriod time.Duration, requestCount int) *rate.Limiter {
+	return rate.NewLimiter(rate.Every(limitPeriod/time.Duration(requestCount)), requestCount)
+}
+
+// Registry shares the rate limiters by the key, e.g. the API token,
+// so all the requests sent with the same key are limited together.
+type Registry struct {
+	newLimiter func(key string) Limiter
+
+	mu       sync.Mutex
+	limiters map[string]Limiter
+}
+
+// NewRegistry of the rate limiters that allow requestCount requests every limitPeriod within the process.
+func NewRegistry(limitPeriod time.Duration, requestCount int) *Registry {
+	return &Registry{
+		newLimiter: func(string) Limiter { return newLimiter(limitPeriod, requestCount) },
+		limiters:   map[string]Limiter{},
+	}
+}
+
+// NewSharedRegistry of the rate limiters that allow requestCount requests every limitPeriod
+// across all the processes sharing the Reserver.
+// The keys are hashed before they're passed to the Reserver, so the tokens are never stored as is.
+func NewSharedRegistry(r Reserver, limitPeriod time.Duration, requestCount int) *Registry {
+	return &Registry{
+		newLimiter: func(key string) Limiter {
+			sum := sha256.Sum256([]byte(key))
+			return &SharedLimiter{
+				r:        r,
+				key:      hex.EncodeToString(sum[:]),
+				interval: limitPeriod / time.Duration(requestCount),
+				burst:    requestCount,
+				fallback: newLimiter(limitPeriod, requestCount),
+			}
+		},
+		limiters: map[string]Limiter{},
+	}
+}
+
+// Get the rate limiter of the key, it's created on the first use.
+func (r *Registry) Get(key string) Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l, ok := r.limiters[key]
+	if !ok {
+		l = r.newLimiter(key)
+		r.limiters[key] = l
+	}
+
+	return l
+}
+
+// Reserver reserves the requests of the rate limits shared by the processes, e.g. the storage.
+type Reserver interface {
+	ReserveRate(ctx context.Context, key string, interval time.Duration, burst int) (time.Time, error)
+}
+
+// SharedLimiter is the rate limiter whose requests are reserved with the Reserver.
+// In case if the reservation fails, the requests are limited within the process.
+type SharedLimiter struct {
+	r        Reserver
+	key      string
+	interval time.Duration
+	burst    int
+	fallback *rate.Limiter
+}
+
+// Wait till the reserved request is allowed to be sent.
+func (l *SharedLimiter) Wait(ctx context.Context) error {
+	at, err := l.r.ReserveRate(ctx, l.key, l.interval, l.burst)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.WarnContext(ctx, "couldn't reserve the shared rate limit: limiting locally", logging.Err(err))
+		return l.fallback.Wait(ctx)
+	}
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
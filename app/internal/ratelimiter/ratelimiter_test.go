@@ -0,0 +1,94 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	autocounter "github.com/notionplusid/core/app"
+)
+
+func TestThrottledTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// 10 requests every 100ms: the burst of 10 goes right away, the other 5 take 10ms each.
+	hc := &http.Client{Transport: NewThrottledTransport(100*time.Millisecond, 10, http.DefaultTransport)}
+
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		res, err := hc.Get(srv.URL)
+		require.NoError(t, err)
+		res.Body.Close()
+	}
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 40*time.Millisecond)
+	require.Less(t, elapsed, 100*time.Millisecond, "the limit is spread over the period")
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(time.Second, 3)
+
+	a := r.Get("a")
+	require.Same(t, a, r.Get("a"))
+	require.NotSame(t, a, r.Get("b"))
+
+	l, ok := a.(*rate.Limiter)
+	require.True(t, ok)
+	require.Equal(t, 3, l.Burst())
+	require.InDelta(t, 3, float64(l.Limit()), 0.001)
+}
+
+// reserver shares the rate limits the same way the storage does.
+type reserver struct {
+	mu    sync.Mutex
+	rates map[string]autocounter.RateLimit
+	err   error
+}
+
+func (r *reserver) ReserveRate(ctx context.Context, key string, interval time.Duration, burst int) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return time.Time{}, r.err
+	}
+
+	rl, at := r.rates[key].Reserve(interval, burst, time.Now())
+	r.rates[key] = rl
+	return at, nil
+}
+
+func TestSharedRegistry(t *testing.T) {
+	res := &reserver{rates: map[string]autocounter.RateLimit{}}
+
+	// every registry stands for the separate process.
+	a := NewSharedRegistry(res, 100*time.Millisecond, 2)
+	b := NewSharedRegistry(res, 100*time.Millisecond, 2)
+
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, a.Get("token").Wait(ctx))
+		require.NoError(t, b.Get("token").Wait(ctx))
+	}
+	// the burst of 2 goes right away, the other 4 take 50ms each.
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	require.Len(t, res.rates, 1)
+	for key := range res.rates {
+		require.NotContains(t, key, "token", "the key is hashed")
+	}
+
+	t.Run("limits locally once the reservation fails", func(t *testing.T) {
+		res.err = errors.New("storage is down")
+		require.NoError(t, a.Get("another").Wait(ctx))
+	})
+}
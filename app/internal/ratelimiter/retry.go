@@ -0,0 +1,129 @@
+package ratelimiter
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryTransport retries the requests that were throttled or failed due to the temporary server errors.
+type RetryTransport struct {
+	roundTripperWrap http.RoundTripper
+	maxRetries       int
+	baseDelay        time.Duration
+	maxDelay         time.Duration
+}
+
+// NewRetryTransport wraps transportWrap with the retries of the throttled (429) and temporary failed (502, 503, 504) requests.
+// The `Retry-After` header of the response is honoured, otherwise the delay grows exponentially
+// from baseDelay up to maxDelay with a random jitter.
+// Once the retries are exhausted or the request deadline comes before the next attempt - the last response is returned.
+func NewRetryTransport(maxRetries int, baseDelay, maxDelay time.Duration, transportWrap http.RoundTripper) http.RoundTripper {
+	return &RetryTransport{
+		roundTripperWrap: transportWrap,
+		maxRetries:       maxRetries,
+		baseDelay:        baseDelay,
+		maxDelay:         maxDelay,
+	}
+}
+
+func (c *RetryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	req := r
+	for attempt := 0; ; attempt++ {
+		res, err := c.roundTripperWrap.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isRetryable(res.StatusCode) || attempt >= c.maxRetries {
+			return res, nil
+		}
+
+		// the body was consumed by the previous attempt and can't be sent again.
+		if r.Body != nil && r.GetBody == nil {
+			return res, nil
+		}
+
+		delay, ok := RetryAfter(res)
+		if !ok {
+			delay = c.backoff(attempt)
+		}
+
+		// there is no point to wait if the request would be cancelled before the next attempt.
+		if dl, ok := r.Context().Deadline(); ok && time.Until(dl) < delay {
+			return res, nil
+		}
+
+		io.Copy(io.Discard, res.Body) // nolint: errcheck
+		res.Body.Close()
+
+		t := time.NewTimer(delay)
+		select {
+		case <-r.Context().Done():
+			t.Stop()
+			return nil, r.Context().Err()
+		case <-t.C:
+		}
+
+		req = r.Clone(r.Context())
+		if r.GetBody != nil {
+			req.Body, err = r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// backoff returns the randomised exponential delay of the attempt.
+func (c *RetryTransport) backoff(attempt int) time.Duration {
+	delay := c.maxDelay
+	if attempt < 32 && c.baseDelay<<attempt < c.maxDelay {
+		delay = c.baseDelay << attempt
+	}
+
+	// half of the delay is fixed, so the retries never hit the API right away.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func isRetryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// RetryAfter returns the delay requested by the server with the `Retry-After` response header.
+// Both delay-seconds and HTTP-date formats are supported.
+func RetryAfter(res *http.Response) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	date, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	delay := time.Until(date)
+	if delay < 0 {
+		delay = 0
+	}
+
+	return delay, true
+}
@@ -0,0 +1,131 @@
+package ratelimiter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryTransport(t *testing.T) {
+	t.Run("retries temporary failures with the same body", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			assert.Equal(t, "payload", string(b))
+
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		hc := &http.Client{Transport: NewRetryTransport(3, time.Millisecond, 10*time.Millisecond, http.DefaultTransport)}
+		res, err := hc.Post(srv.URL, "text/plain", strings.NewReader("payload"))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("returns the last response once retries are exhausted", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer srv.Close()
+
+		hc := &http.Client{Transport: NewRetryTransport(2, time.Millisecond, 10*time.Millisecond, http.DefaultTransport)}
+		res, err := hc.Get(srv.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("gives up when Retry-After exceeds the deadline", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		hc := &http.Client{Transport: NewRetryTransport(5, time.Millisecond, 10*time.Millisecond, http.DefaultTransport)}
+		res, err := hc.Do(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("gives up once the attempt limit is reached", func(t *testing.T) {
+		for retries, attempts := range map[int]int32{0: 1, 1: 2, 4: 5} {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+
+			hc := &http.Client{Transport: NewRetryTransport(retries, time.Millisecond, time.Millisecond, http.DefaultTransport)}
+			res, err := hc.Get(srv.URL)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+			assert.Equal(t, attempts, atomic.LoadInt32(&calls), "retries: %d", retries)
+			srv.Close()
+		}
+	})
+
+	t.Run("doesn't retry the permanent failures", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		hc := &http.Client{Transport: NewRetryTransport(3, time.Millisecond, 10*time.Millisecond, http.DefaultTransport)}
+		res, err := hc.Get(srv.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestRetryAfter(t *testing.T) {
+	res := func(v string) *http.Response {
+		h := http.Header{}
+		if v != "" {
+			h.Set("Retry-After", v)
+		}
+		return &http.Response{Header: h}
+	}
+
+	d, ok := RetryAfter(res("7"))
+	assert.True(t, ok)
+	assert.Equal(t, 7*time.Second, d)
+
+	d, ok = RetryAfter(res(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)))
+	assert.True(t, ok)
+	assert.InDelta(t, time.Minute, d, float64(2*time.Second))
+
+	d, ok = RetryAfter(res(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)))
+	assert.True(t, ok)
+	assert.Zero(t, d, "the date in the past is no delay")
+
+	for _, v := range []string{"", "-1", "soon"} {
+		_, ok := RetryAfter(res(v))
+		assert.False(t, ok, v)
+	}
+}
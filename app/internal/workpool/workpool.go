@@ -0,0 +1,109 @@
+// Package workpool bounds the concurrency of the work submitted by the multiple producers.
+//
+// Submitting blocks until there's a free worker,
+// so the producers are slowed down to the pace of the workers instead of piling up the goroutines.
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Pool of the workers that run the submitted tasks.
+type Pool struct {
+	sem chan struct{}
+}
+
+// New Pool constructor with the provided amount of workers.
+func New(size int) (*Pool, error) {
+	if size < 1 {
+		return nil, errors.New("size must be positive")
+	}
+
+	return &Pool{sem: make(chan struct{}, size)}, nil
+}
+
+// Size of the Pool.
+func (p *Pool) Size() int {
+	return cap(p.sem)
+}
+
+// Go runs the task in the separate goroutine as soon as there's a free worker and blocks until then.
+// The task isn't run and the error is returned in case if the context is done first.
+func (p *Pool) Go(ctx context.Context, task func()) error {
+	if err := p.acquire(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		defer p.release()
+		task()
+	}()
+
+	return nil
+}
+
+// Do runs the task in the calling goroutine as soon as there's a free worker.
+// The task isn't run and the error is returned in case if the context is done first.
+func (p *Pool) Do(ctx context.Context, task func()) error {
+	if err := p.acquire(ctx); err != nil {
+		return err
+	}
+	defer p.release()
+
+	task()
+	return nil
+}
+
+func (p *Pool) acquire(ctx context.Context) error {
+	// the done context always wins over the free worker.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) release() {
+	<-p.sem
+}
+
+// Registry of the Pools of the same size by the key, e.g. the workspace token.
+type Registry struct {
+	size int
+
+	mu    sync.Mutex
+	pools map[string]*Pool
+}
+
+// NewRegistry constructor of the Pools with the provided amount of workers.
+func NewRegistry(size int) (*Registry, error) {
+	if size < 1 {
+		return nil, errors.New("size must be positive")
+	}
+
+	return &Registry{
+		size:  size,
+		pools: map[string]*Pool{},
+	}, nil
+}
+
+// Get the Pool of the key, it's created on the first use.
+func (r *Registry) Get(key string) *Pool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pools[key]
+	if !ok {
+		p = &Pool{sem: make(chan struct{}, r.size)}
+		r.pools[key] = p
+	}
+
+	return p
+}
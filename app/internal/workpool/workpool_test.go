@@ -0,0 +1,75 @@
+package workpool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	_, err := New(0)
+	require.Error(t, err)
+
+	p, err := New(2)
+	require.NoError(t, err)
+	require.Equal(t, 2, p.Size())
+
+	var running, peak int32
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		require.NoError(t, p.Go(context.Background(), func() {
+			defer wg.Done()
+			n := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}))
+	}
+	wg.Wait()
+	require.Equal(t, int32(2), peak)
+}
+
+func TestPoolBackPressure(t *testing.T) {
+	p, err := New(1)
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	require.NoError(t, p.Go(context.Background(), func() { <-release }))
+
+	// the pool is busy: the submission blocks until the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var ran bool
+	err = p.Do(ctx, func() { ran = true })
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.False(t, ran)
+
+	// the done context never runs the task even if there's a free worker.
+	close(release)
+	require.Eventually(t, func() bool { return p.Do(context.Background(), func() {}) == nil }, time.Second, time.Millisecond)
+	require.Error(t, p.Do(ctx, func() { ran = true }))
+	require.False(t, ran)
+}
+
+func TestRegistry(t *testing.T) {
+	_, err := NewRegistry(0)
+	require.Error(t, err)
+
+	r, err := NewRegistry(3)
+	require.NoError(t, err)
+
+	a := r.Get("a")
+	require.Same(t, a, r.Get("a"))
+	require.NotSame(t, a, r.Get("b"))
+	require.Equal(t, 3, a.Size())
+}
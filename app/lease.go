@@ -0,0 +1,63 @@
+package autocounter
+
+import (
+	"errors"
+	"time"
+)
+
+// Lease of the named resource, e.g. the processing of the Workspace, held by one of the app instances.
+// The Lease expires unless it's prolonged by its holder, so the resource of the crashed instance is picked up by the others.
+type Lease struct {
+	Name      string    `json:"name"`
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Acquire returns the Lease held by the holder till the ttl expires.
+// The holder is free to prolong its own Lease.
+// Returns ErrLeaseHeld in case if the Lease is held by another holder and isn't expired yet.
+func (l Lease) Acquire(holder string, ttl time.Duration, now time.Time) (Lease, error) {
+	switch {
+	case holder == "":
+		return Lease{}, errors.New("holder is required")
+	case ttl <= 0:
+		return Lease{}, errors.New("ttl must be positive")
+	case l.Holder != "" && l.Holder != holder && now.Before(l.ExpiresAt):
+		return Lease{}, ErrLeaseHeld
+	}
+
+	l.Holder = holder
+	l.ExpiresAt = now.Add(ttl)
+
+	return l, nil
+}
+
+// RateLimit shared by the app instances, e.g. the request limit of the Notion API token.
+// It's the generic cell rate algorithm: the requests are allowed up to the burst at once
+// and one per interval afterwards.
+type RateLimit struct {
+	Key string `json:"key"`
+	// NextAt is the time the next request would be allowed at without the burst.
+	NextAt time.Time `json:"nextAt"`
+}
+
+// Reserve the request and return the RateLimit with the request reserved,
+// along with the time the request is allowed to be sent at.
+func (r RateLimit) Reserve(interval time.Duration, burst int, now time.Time) (RateLimit, time.Time) {
+	if burst < 1 {
+		burst = 1
+	}
+
+	next := r.NextAt
+	if next.Before(now) {
+		next = now
+	}
+	r.NextAt = next.Add(interval)
+
+	at := r.NextAt.Add(-time.Duration(burst) * interval)
+	if at.Before(now) {
+		at = now
+	}
+
+	return r, at
+}
@@ -0,0 +1,54 @@
+package autocounter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseAcquire(t *testing.T) {
+	now := time.Now()
+
+	l, err := Lease{Name: "ws"}.Acquire("a", time.Minute, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", l.Holder)
+	assert.Equal(t, now.Add(time.Minute), l.ExpiresAt)
+
+	t.Run("is prolonged by the holder", func(t *testing.T) {
+		prolonged, err := l.Acquire("a", time.Minute, now.Add(time.Second))
+		assert.NoError(t, err)
+		assert.Equal(t, now.Add(time.Second+time.Minute), prolonged.ExpiresAt)
+	})
+
+	t.Run("is rejected for another holder until expired", func(t *testing.T) {
+		_, err := l.Acquire("b", time.Minute, now.Add(time.Second))
+		assert.Equal(t, ErrLeaseHeld, err)
+
+		taken, err := l.Acquire("b", time.Minute, now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, "b", taken.Holder)
+	})
+}
+
+func TestRateLimitReserve(t *testing.T) {
+	now := time.Now()
+	r := RateLimit{Key: "token"}
+
+	// the burst of 3 goes right away.
+	var at time.Time
+	for i := 0; i < 3; i++ {
+		r, at = r.Reserve(time.Second, 3, now)
+		assert.Equal(t, now, at)
+	}
+
+	// the rest goes one per interval.
+	r, at = r.Reserve(time.Second, 3, now)
+	assert.Equal(t, now.Add(time.Second), at)
+	r, at = r.Reserve(time.Second, 3, now)
+	assert.Equal(t, now.Add(2*time.Second), at)
+
+	// the burst is restored once the requests are spread.
+	_, at = r.Reserve(time.Second, 3, now.Add(time.Minute))
+	assert.Equal(t, now.Add(time.Minute), at)
+}
@@ -0,0 +1,139 @@
+package autocounter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Period the Table counters are reset at.
+type Period = string
+
+// Known periods.
+const (
+	PeriodNever   Period = "never"
+	PeriodYearly  Period = "yearly"
+	PeriodMonthly Period = "monthly"
+	PeriodDaily   Period = "daily"
+)
+
+var validPeriods = []Period{
+	PeriodNever,
+	PeriodYearly,
+	PeriodMonthly,
+	PeriodDaily,
+}
+
+// ValidatePeriod and return error if provided period is invalid.
+func ValidatePeriod(p Period) error {
+	if p == "" {
+		return errors.New("period is required")
+	}
+
+	for _, vp := range validPeriods {
+		if p == vp {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown period: %s", p)
+}
+
+// Placeholders of the Table identifier format.
+const (
+	FormatValue = "{n}"
+	FormatYear  = "{yyyy}"
+	FormatYY    = "{yy}"
+	FormatMonth = "{mm}"
+	FormatDay   = "{dd}"
+)
+
+// validateFormat of the text identifiers.
+func validateFormat(f string) error {
+	if n := strings.Count(f, FormatValue); n != 1 {
+		return fmt.Errorf("format must contain %s exactly once", FormatValue)
+	}
+
+	return nil
+}
+
+// IsPeriodic returns true if the counters of the Table are reset periodically.
+func (t Table) IsPeriodic() bool {
+	return t.Reset != "" && t.Reset != PeriodNever
+}
+
+// IsDated returns true if the identifiers of the Table depend on the date of the page:
+// the counters are reset periodically or the format contains the date.
+func (t Table) IsDated() bool {
+	if t.IsPeriodic() {
+		return true
+	}
+
+	for _, p := range []string{FormatYear, FormatYY, FormatMonth, FormatDay} {
+		if strings.Contains(t.Format, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Location the dates of the Table pages are taken in.
+// The time zone is validated with the Table, so UTC is returned for the invalid one.
+func (t Table) Location() *time.Location {
+	loc, err := time.LoadLocation(t.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// PeriodOf returns the key of the Table period the time falls into, e.g. "2026" or "2026-03".
+// The time is expected in the location of the Table.
+// Returns empty key in case if the Table counters are never reset.
+func (t Table) PeriodOf(at time.Time) string {
+	switch t.Reset {
+	case PeriodYearly:
+		return at.Format("2006")
+	case PeriodMonthly:
+		return at.Format("2006-01")
+	case PeriodDaily:
+		return at.Format("2006-01-02")
+	}
+
+	return ""
+}
+
+// PeriodStart returns the start of the Table period the time falls into.
+// Returns zero time in case if the Table counters are never reset.
+func (t Table) PeriodStart(at time.Time) time.Time {
+	switch t.Reset {
+	case PeriodYearly:
+		return time.Date(at.Year(), time.January, 1, 0, 0, 0, 0, at.Location())
+	case PeriodMonthly:
+		return time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, at.Location())
+	case PeriodDaily:
+		return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	}
+
+	return time.Time{}
+}
+
+// InPeriod returns the Table that formats the identifiers of the pages dated with the provided time:
+// the date placeholders of the format are replaced with the date.
+func (t Table) InPeriod(at time.Time) Table {
+	if t.Format == "" {
+		return t
+	}
+
+	t.Format = strings.NewReplacer(
+		FormatYear, at.Format("2006"),
+		FormatYY, at.Format("06"),
+		FormatMonth, at.Format("01"),
+		FormatDay, at.Format("02"),
+	).Replace(t.Format)
+
+	return t
+}
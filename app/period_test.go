@@ -0,0 +1,75 @@
+package autocounter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeriod(t *testing.T) {
+	at := time.Date(2026, time.March, 15, 10, 30, 0, 0, time.UTC)
+
+	t.Run("keys the periods of the reset", func(t *testing.T) {
+		for reset, key := range map[Period]string{
+			"":            "",
+			PeriodNever:   "",
+			PeriodYearly:  "2026",
+			PeriodMonthly: "2026-03",
+			PeriodDaily:   "2026-03-15",
+		} {
+			table := Table{Reset: reset}
+			assert.Equal(t, key, table.PeriodOf(at), reset)
+		}
+	})
+
+	t.Run("starts the period in the location of the time", func(t *testing.T) {
+		loc, err := time.LoadLocation("Europe/Berlin")
+		assert.NoError(t, err)
+
+		table := Table{Reset: PeriodYearly}
+		assert.Equal(t, time.Date(2026, time.January, 1, 0, 0, 0, 0, loc), table.PeriodStart(at.In(loc)))
+		assert.True(t, (Table{}).PeriodStart(at).IsZero())
+	})
+
+	t.Run("formats the identifiers with the date", func(t *testing.T) {
+		table := Table{ParamType: ParamTypeRichText, Prefix: "INV", Separator: "-", PadWidth: 4, Format: "{yyyy}/{mm}-{n}"}
+		assert.True(t, table.IsDated())
+
+		pt := table.InPeriod(at)
+		assert.Equal(t, "INV-2026/03-0042", pt.FormatID(42))
+
+		v, ok := pt.ParseID("INV-2026/03-0042")
+		assert.True(t, ok)
+		assert.Equal(t, int64(42), v)
+
+		_, ok = pt.ParseID("INV-2026/02-0042")
+		assert.False(t, ok)
+		_, ok = pt.ParseID("INV-2026/03-")
+		assert.False(t, ok)
+
+		assert.Equal(t, "26-7", Table{Format: "{yy}-{n}"}.InPeriod(at).FormatID(7))
+	})
+
+	t.Run("validates the reset settings", func(t *testing.T) {
+		valid := Table{ID: "1", WorkspaceID: "1", Status: StatusActive, ParamName: "ID", ParamType: ParamTypeRichText, StartValue: 1}
+
+		for name, mod := range map[string]func(*Table){
+			"unknown period":          func(t *Table) { t.Reset = "weekly" },
+			"unknown time zone":       func(t *Table) { t.TimeZone = "Mars/Olympus" },
+			"format without value":    func(t *Table) { t.Format = "{yyyy}" },
+			"format with two values":  func(t *Table) { t.Format = "{n}-{n}" },
+			"format of number column": func(t *Table) { t.ParamType = ParamTypeNumber; t.Format = "{n}" },
+			"gap-free dated table":    func(t *Table) { t.Reset = PeriodYearly; t.GapFree = true },
+		} {
+			table := valid
+			mod(&table)
+			assert.Error(t, table.Validate(), name)
+		}
+
+		valid.Reset = PeriodMonthly
+		valid.TimeZone = "Europe/Berlin"
+		valid.Format = "{yyyy}-{n}"
+		assert.NoError(t, valid.Validate())
+	})
+}
@@ -8,19 +8,32 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/notionplusid/core/app/internal/metrics"
 )
 
-var client = &http.Client{}
+var client = &http.Client{
+	Transport: metrics.NewTransport(endpoint, http.DefaultTransport),
+}
 
 // OAuth2Res is the Notion API response with the expected data.
 type OAuth2Res struct {
-	AccessToken          string          `json:"access_token"`
-	BotID                string          `json:"bot_id"`
-	DuplicatedTemplateID string          `json:"duplicated_template_id,omitempty"`
-	Owner                json.RawMessage `json:"owner"`
-	WorkspaceIconURL     string          `json:"workspace_icon,omitempty"`
-	WorkspaceID          string          `json:"workspace_id"`
-	WorkspaceName        string          `json:"workspace_name,omitempty"`
+	AccessToken          string      `json:"access_token"`
+	BotID                string      `json:"bot_id"`
+	DuplicatedTemplateID string      `json:"duplicated_template_id,omitempty"`
+	Owner                OAuth2Owner `json:"owner"`
+	WorkspaceIconURL     string      `json:"workspace_icon,omitempty"`
+	WorkspaceID          string      `json:"workspace_id"`
+	WorkspaceName        string      `json:"workspace_name,omitempty"`
+}
+
+// OAuth2Owner is the one who installed the integration.
+type OAuth2Owner struct {
+	Type      string `json:"type"`
+	User      *User  `json:"user,omitempty"`
+	Workspace bool   `json:"workspace,omitempty"`
 }
 
 // ExtConfig for the Notion Extension.
@@ -28,6 +41,9 @@ type ExtConfig struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURI  string
+
+	// APIURL is the base URL of the Notion API, the default one is used if empty.
+	APIURL string
 }
 
 // Validate the ExtConfig.
@@ -44,6 +60,26 @@ func (e *ExtConfig) Validate() error {
 	return nil
 }
 
+func (e *ExtConfig) apiURL() string {
+	if e.APIURL == "" {
+		return defaultAPIPath
+	}
+	return strings.TrimSuffix(e.APIURL, "/")
+}
+
+// AuthorizeURL returns the URL of the Notion page where the user authorises the extension.
+// The provided state is passed back to the redirect URI as is.
+func AuthorizeURL(config ExtConfig, state string) string {
+	q := url.Values{}
+	q.Set("client_id", config.ClientID)
+	q.Set("redirect_uri", config.RedirectURI)
+	q.Set("response_type", "code")
+	q.Set("owner", "user")
+	q.Set("state", state)
+
+	return config.apiURL() + "/v1/oauth/authorize?" + q.Encode()
+}
+
 // OAuth2 authorises the Workspace with provided code and client ID.
 func OAuth2(ctx context.Context, code string, config ExtConfig) (OAuth2Res, error) {
 	if code == "" {
@@ -68,14 +104,14 @@ func OAuth2(ctx context.Context, code string, config ExtConfig) (OAuth2Res, erro
 		return OAuth2Res{}, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, "https://api.notion.com/v1/oauth/token", bytes.NewReader(bs))
+	req, err := http.NewRequest(http.MethodPost, config.apiURL()+"/v1/oauth/token", bytes.NewReader(bs))
 	if err != nil {
 		return OAuth2Res{}, err
 	}
 	req.SetBasicAuth(config.ClientID, config.ClientSecret)
 	req.Header.Set("Content-Type", "application/json")
 
-	res, err := client.Do(req)
+	res, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return OAuth2Res{}, err
 	}
@@ -1,46 +1,182 @@
 package notion
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/metrics"
 	"github.com/notionplusid/core/app/internal/ratelimiter"
 )
 
 const defaultNotionAPIVersion = "2022-06-28"
 const defaultAPIPath = "https://api.notion.com"
 
+// Retries of the throttled and temporary failed requests.
+const (
+	defaultMaxRetries     = 5
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 30 * time.Second
+)
+
 // Notion API client.
 type Notion struct {
-	bearer string
-	http   *http.Client
+	bearer    string
+	apiURL    string
+	http      *http.Client
+	decrypter TokenDecrypter
+	limiters  *ratelimiter.Registry
+}
+
+// Request limits of the Notion API token: https://developers.notion.com/reference/request-limits
+const (
+	requestLimitPeriod = time.Second
+	requestLimitCount  = 3
+)
+
+// defaultLimiters are shared by all the clients created without WithLimiters,
+// so the clients of the same token honor the Notion API request limits together.
+var defaultLimiters = ratelimiter.NewRegistry(requestLimitPeriod, requestLimitCount)
+
+// NewSharedLimiters returns the registry of the Notion API rate limiters
+// shared by all the app instances through the provided Reserver, e.g. the storage.
+func NewSharedLimiters(r ratelimiter.Reserver) *ratelimiter.Registry {
+	return ratelimiter.NewSharedRegistry(r, requestLimitPeriod, requestLimitCount)
+}
+
+// TokenDecrypter decrypts the workspace tokens that are stored encrypted.
+type TokenDecrypter interface {
+	Decrypt(ctx context.Context, token string) (string, error)
+}
+
+// Option of the Notion API client.
+type Option func(n *Notion)
+
+// WithAPIURL sets the base URL of the Notion API, e.g. to talk to a fake server in tests.
+// Empty URL keeps the default one.
+func WithAPIURL(apiURL string) Option {
+	return func(n *Notion) {
+		if apiURL == "" {
+			return
+		}
+		n.apiURL = strings.TrimSuffix(apiURL, "/")
+	}
+}
+
+// WithClient sets the HTTP Client used for all the requests to the Notion API instead of the throttled one.
+func WithClient(hc *http.Client) Option {
+	return func(n *Notion) {
+		if hc == nil {
+			return
+		}
+		n.http = hc
+	}
+}
+
+// WithTokenDecrypter sets the decrypter of the workspace tokens used by NewFromWorkspace.
+func WithTokenDecrypter(d TokenDecrypter) Option {
+	return func(n *Notion) {
+		n.decrypter = d
+	}
+}
+
+// WithLimiters sets the registry of the rate limiters shared by the clients of the same token.
+// Ignored in case if the HTTP Client is set with WithClient.
+func WithLimiters(r *ratelimiter.Registry) Option {
+	return func(n *Notion) {
+		if r == nil {
+			return
+		}
+		n.limiters = r
+	}
 }
 
 // NewClient for Notion API.
-func NewClient(bearerToken string) (*Notion, error) {
+func NewClient(bearerToken string, opts ...Option) (*Notion, error) {
 	if bearerToken == "" {
 		return nil, errors.New("bearer token is required")
 	}
 
-	return &Notion{
-		bearer: bearerToken,
-		http: &http.Client{
-			// as expected per Notion API: https://developers.notion.com/reference/request-limits
-			Transport: ratelimiter.NewThrottledTransport(time.Second, 3, http.DefaultTransport),
-		},
-	}, nil
+	n := newNotion(bearerToken, opts...)
+	n.initHTTP()
+
+	return n, nil
+}
+
+func newNotion(bearerToken string, opts ...Option) *Notion {
+	n := &Notion{
+		bearer:   bearerToken,
+		apiURL:   defaultAPIPath,
+		limiters: defaultLimiters,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+// initHTTP sets the throttled HTTP Client unless the one was provided with the options.
+// The rate limiter is picked by the bearer token, so it must be final by the time of the call.
+func (n *Notion) initHTTP() {
+	if n.http != nil {
+		return
+	}
+
+	n.http = &http.Client{
+		// retries go through the throttling as well.
+		Transport: ratelimiter.NewRetryTransport(
+			defaultMaxRetries,
+			defaultRetryBaseDelay,
+			defaultRetryMaxDelay,
+			ratelimiter.NewLimitedTransport(n.limiters.Get(n.bearer), metrics.NewTransport(endpoint, http.DefaultTransport)),
+		),
+	}
 }
 
 // NewFromWorkspace initialiases Notion API client from the provided Workspace.
-func NewFromWorkspace(ws autocounter.Workspace) (*Notion, error) {
+// The token of the Workspace is decrypted in case if the TokenDecrypter is provided.
+func NewFromWorkspace(ctx context.Context, ws autocounter.Workspace, opts ...Option) (*Notion, error) {
 	if err := ws.Validate(); err != nil {
 		return nil, fmt.Errorf("workspace: %s", err)
 	}
 
-	return NewClient(ws.Token)
+	n := newNotion(ws.Token, opts...)
+	if n.decrypter != nil {
+		var err error
+		n.bearer, err = n.decrypter.Decrypt(ctx, ws.Token)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't decrypt the workspace token: %w", err)
+		}
+	}
+	n.initHTTP()
+
+	return n, nil
+}
+
+// objectPaths are the path segments followed by the object ID.
+var objectPaths = map[string]bool{
+	"databases": true,
+	"pages":     true,
+	"blocks":    true,
+	"users":     true,
+}
+
+// endpoint returns the method and the path of the request with the object IDs replaced,
+// e.g. "POST /v1/databases/{id}/query".
+func endpoint(r *http.Request) string {
+	parts := strings.Split(r.URL.Path, "/")
+	for i := 1; i < len(parts); i++ {
+		if objectPaths[parts[i-1]] && parts[i] != "me" {
+			parts[i] = "{id}"
+		}
+	}
+
+	return r.Method + " " + strings.Join(parts, "/")
 }
 
 // Close the client.
@@ -54,8 +190,8 @@ func (n *Notion) Close() {
 func (n *Notion) WithHTTPClient(hc *http.Client) *Notion {
 	copy := *n
 	if hc == nil {
-		return &copy
+		hc = &http.Client{}
 	}
 	copy.http = hc
-	return nil
+	return &copy
 }
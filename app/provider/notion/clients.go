@@ -0,0 +1,64 @@
+package notion
+
+import (
+	"context"
+	"sync"
+
+	autocounter "github.com/notionplusid/core/app"
+)
+
+// Clients caches the Notion API clients by the Workspace,
+// so the connections and the rate limits are shared by all the requests of the Workspace.
+type Clients struct {
+	opts []Option
+
+	mu      sync.Mutex
+	clients map[string]cachedClient
+}
+
+type cachedClient struct {
+	token string
+	n     *Notion
+}
+
+// NewClients cache of the clients created with the provided options.
+func NewClients(opts ...Option) *Clients {
+	return &Clients{
+		opts:    opts,
+		clients: map[string]cachedClient{},
+	}
+}
+
+// ForWorkspace returns the client of the Workspace, it's created on the first use
+// and recreated once the Workspace token changes.
+func (c *Clients) ForWorkspace(ctx context.Context, ws autocounter.Workspace) (*Notion, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.clients[ws.ID]
+	if ok && cached.token == ws.Token {
+		return cached.n, nil
+	}
+
+	n, err := NewFromWorkspace(ctx, ws, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		cached.n.Close()
+	}
+	c.clients[ws.ID] = cachedClient{token: ws.Token, n: n}
+
+	return n, nil
+}
+
+// Forget the client of the Workspace, e.g. once it's unregistered.
+func (c *Clients) Forget(wsID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.clients[wsID]; ok {
+		cached.n.Close()
+		delete(c.clients, wsID)
+	}
+}
@@ -0,0 +1,67 @@
+package notion_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/ratelimiter"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/provider/notion/notiontest"
+)
+
+const testToken = "secret_token"
+
+func TestClients(t *testing.T) {
+	srv := notiontest.NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	clients := notion.NewClients(notion.WithAPIURL(srv.URL()))
+
+	ws, err := autocounter.NewWorkspace("ws", testToken)
+	require.NoError(t, err)
+
+	n, err := clients.ForWorkspace(ctx, ws)
+	require.NoError(t, err)
+	same, err := clients.ForWorkspace(ctx, ws)
+	require.NoError(t, err)
+	require.Same(t, n, same, "the client is reused by the workspace")
+
+	ws.Token = "new_token"
+	renewed, err := clients.ForWorkspace(ctx, ws)
+	require.NoError(t, err)
+	require.NotSame(t, n, renewed, "the client is recreated once the token changes")
+
+	clients.Forget(ws.ID)
+	again, err := clients.ForWorkspace(ctx, ws)
+	require.NoError(t, err)
+	require.NotSame(t, renewed, again)
+}
+
+func TestSharedRateLimit(t *testing.T) {
+	srv := notiontest.NewServer()
+	defer srv.Close()
+
+	ctx := context.Background()
+	// 2 requests every 100ms shared by all the clients of the token.
+	limiters := ratelimiter.NewRegistry(100*time.Millisecond, 2)
+
+	var clis []*notion.Notion
+	for i := 0; i < 2; i++ {
+		n, err := notion.NewClient(testToken, notion.WithAPIURL(srv.URL()), notion.WithLimiters(limiters))
+		require.NoError(t, err)
+		clis = append(clis, n)
+	}
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		_, err := clis[i%2].Me(ctx)
+		require.NoError(t, err)
+	}
+	// the burst of 2 goes right away, the other 4 take 50ms each.
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
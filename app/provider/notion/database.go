@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 
@@ -43,54 +42,49 @@ const (
 
 // Database object.
 type Database struct {
-	ID             string     `json:"id"`
-	Object         string     `json:"object"`
-	CreatedTime    time.Time  `json:"created_time"`
-	LastEditedTime time.Time  `json:"last_edited_at"`
-	Title          []RichText `json:"title"`
-	Properties     map[string]struct {
-		ID     string       `json:"id"`
-		Type   PropertyType `json:"type"`
-		Number *struct {
-			Format string `json:"format"`
-		} `json:"number,omitempty"`
-		Formula *struct {
-			Expression string `json:"expression,omitempty"`
-		} `json:"formula,omitempty"`
-		Relation *struct {
-			DatabaseID         string `json:"database_id"`
-			SyncedPropertyName string `json:"synced_property_name,omitempty"`
-			SyncedPropertyID   string `json:"synced_property_id,omitempty"`
-		} `json:"relation,omitempty"`
-		Rollup *struct {
-			RelationPropertyName string `json:"relation_property_name"`
-			RelationPropertyID   string `json:"relation_property_id"`
-			RollupPropertyName   string `json:"rollup_property_name"`
-			RollupPropertyID     string `json:"rollup_property_id"`
-			Function             string `json:"function"`
-		} `json:"rollup,omitempty"`
-		Select *struct {
-			Options []struct {
-				ID    string `json:"id"`
-				Name  string `json:"name"`
-				Color string `json:"color"`
-			} `json:"options,omitempty"`
-		} `json:"select,omitempty"`
-		MultiSelect *struct {
-			Options []struct {
-				ID    string `json:"id"`
-				Name  string `json:"name"`
-				Color string `json:"color"`
-			} `json:"options,omitempty"`
-		} `json:"multi_select,omitempty"`
-	} `json:"properties"`
-	Parent struct {
+	ID             string                      `json:"id"`
+	Object         string                      `json:"object"`
+	CreatedTime    time.Time                   `json:"created_time"`
+	LastEditedTime time.Time                   `json:"last_edited_time"`
+	Title          []RichText                  `json:"title"`
+	Properties     map[string]DatabaseProperty `json:"properties"`
+	Parent         struct {
 		Type      string `json:"type"`
 		PageID    string `json:"page_id,omitempty"`
 		Workspace bool   `json:"workspace,omitempty"`
 	} `json:"parent"`
 }
 
+// DatabaseProperty is the schema of the Database column.
+type DatabaseProperty struct {
+	ID     string       `json:"id"`
+	Type   PropertyType `json:"type"`
+	Number *struct {
+		Format string `json:"format"`
+	} `json:"number,omitempty"`
+	Formula *struct {
+		Expression string `json:"expression,omitempty"`
+	} `json:"formula,omitempty"`
+	Relation *struct {
+		DatabaseID         string `json:"database_id"`
+		SyncedPropertyName string `json:"synced_property_name,omitempty"`
+		SyncedPropertyID   string `json:"synced_property_id,omitempty"`
+	} `json:"relation,omitempty"`
+	Rollup *struct {
+		RelationPropertyName string `json:"relation_property_name"`
+		RelationPropertyID   string `json:"relation_property_id"`
+		RollupPropertyName   string `json:"rollup_property_name"`
+		RollupPropertyID     string `json:"rollup_property_id"`
+		Function             string `json:"function"`
+	} `json:"rollup,omitempty"`
+	Select *struct {
+		Options []SelectOption `json:"options,omitempty"`
+	} `json:"select,omitempty"`
+	MultiSelect *struct {
+		Options []SelectOption `json:"options,omitempty"`
+	} `json:"multi_select,omitempty"`
+}
+
 type DBSortTimestamp string
 
 const (
@@ -141,15 +135,17 @@ type DBFilterCheckbox struct {
 type DBFilterSelect struct {
 	Equals       *string `json:"equals,omitempty"`
 	DoesNotEqual *string `json:"does_not_equal,omitempty"`
-	IsEmpty      bool    `json:"is_empty"`
-	IsNotEmpty   bool    `json:"is_not_empty"`
+	IsEmpty      bool    `json:"is_empty,omitempty"`
+	IsNotEmpty   bool    `json:"is_not_empty,omitempty"`
 }
 
 type DBFilterMultiSelect struct {
-	Equals       *string `json:"equals,omitempty"`
-	DoesNotEqual *string `json:"does_not_equal,omitempty"`
-	IsEmpty      bool    `json:"is_empty"`
-	IsNotEmpty   bool    `json:"is_not_empty"`
+	Equals         *string `json:"equals,omitempty"`
+	DoesNotEqual   *string `json:"does_not_equal,omitempty"`
+	Contains       string  `json:"contains,omitempty"`
+	DoesNotContain string  `json:"does_not_contain,omitempty"`
+	IsEmpty        bool    `json:"is_empty,omitempty"`
+	IsNotEmpty     bool    `json:"is_not_empty,omitempty"`
 }
 
 type DBFilterDate struct {
@@ -195,7 +191,11 @@ type DBFilterFormula struct {
 }
 
 type DBFilter struct {
-	Property       string               `json:"property"`
+	Property string `json:"property,omitempty"`
+	// Timestamp filters by the page timestamp instead of the property,
+	// the condition is set in CreatedTime or LastEditedTime respectively.
+	Timestamp DBSortTimestamp `json:"timestamp,omitempty"`
+
 	Title          *DBFilterText        `json:"title,omitempty"`
 	RichText       *DBFilterText        `json:"rich_text,omitempty"`
 	URL            *DBFilterText        `json:"url,omitempty"`
@@ -257,7 +257,7 @@ func (n *Notion) QueryDatabase(ctx context.Context, databaseID string, q DBQuery
 		return DBQueryRes{}, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, defaultAPIPath+"/v1/databases/"+databaseID+"/query", bytes.NewBuffer(srJSON))
+	req, err := http.NewRequest(http.MethodPost, n.apiURL+"/v1/databases/"+databaseID+"/query", bytes.NewBuffer(srJSON))
 	if err != nil {
 		return DBQueryRes{}, err
 	}
@@ -278,8 +278,7 @@ func (n *Notion) QueryDatabase(ctx context.Context, databaseID string, q DBQuery
 	case 404:
 		return DBQueryRes{}, autocounter.ErrTableNotFound
 	default:
-		e, _ := io.ReadAll(res.Body)
-		return DBQueryRes{}, fmt.Errorf("unexpected error %d: %s", res.StatusCode, e)
+		return DBQueryRes{}, unexpectedResErr(res)
 	}
 
 	var qRes DBQueryRes
@@ -296,7 +295,7 @@ func (n *Notion) Database(ctx context.Context, databaseID string) (Database, err
 		return Database{}, errors.New("database id is required")
 	}
 
-	req, err := http.NewRequest(http.MethodGet, defaultAPIPath+"/v1/databases/"+databaseID, nil)
+	req, err := http.NewRequest(http.MethodGet, n.apiURL+"/v1/databases/"+databaseID, nil)
 	if err != nil {
 		return Database{}, err
 	}
@@ -315,8 +314,7 @@ func (n *Notion) Database(ctx context.Context, databaseID string) (Database, err
 	case 404:
 		return Database{}, autocounter.ErrTableNotFound
 	default:
-		e, _ := io.ReadAll(res.Body)
-		return Database{}, fmt.Errorf("unexpected error %d: %s", res.StatusCode, e)
+		return Database{}, unexpectedResErr(res)
 	}
 
 	var qRes Database
@@ -0,0 +1,42 @@
+package notion
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/ratelimiter"
+)
+
+// RateLimitError is returned in case if Notion API kept throttling the requests after all the retries.
+type RateLimitError struct {
+	// RetryAfter is the delay requested by the Notion API, zero if unknown.
+	RetryAfter time.Duration
+}
+
+// Error implementation.
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter == 0 {
+		return autocounter.ErrRateLimited.Error()
+	}
+
+	return fmt.Sprintf("%s: retry after %s", autocounter.ErrRateLimited, e.RetryAfter)
+}
+
+// Is allows to match the error with autocounter.ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == autocounter.ErrRateLimited
+}
+
+// unexpectedResErr returns the error for the response with unexpected status code.
+func unexpectedResErr(res *http.Response) error {
+	if res.StatusCode == http.StatusTooManyRequests {
+		retryAfter, _ := ratelimiter.RetryAfter(res)
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+
+	e, _ := io.ReadAll(res.Body)
+	return fmt.Errorf("unexpected error %d: %s", res.StatusCode, e)
+}
@@ -0,0 +1,55 @@
+package notion
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	autocounter "github.com/notionplusid/core/app"
+)
+
+func TestUnexpectedResErr(t *testing.T) {
+	res := func(status int, header http.Header, body string) *http.Response {
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{StatusCode: status, Header: header, Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	t.Run("throttled response is the rate limit error", func(t *testing.T) {
+		err := unexpectedResErr(res(http.StatusTooManyRequests, http.Header{"Retry-After": {"30"}}, ""))
+
+		var rle *RateLimitError
+		assert.True(t, errors.As(err, &rle))
+		assert.Equal(t, 30*time.Second, rle.RetryAfter)
+		assert.True(t, errors.Is(err, autocounter.ErrRateLimited))
+		assert.Equal(t, "rate limited: retry after 30s", err.Error())
+	})
+
+	t.Run("rate limit error without delay", func(t *testing.T) {
+		err := unexpectedResErr(res(http.StatusTooManyRequests, nil, ""))
+
+		var rle *RateLimitError
+		assert.True(t, errors.As(err, &rle))
+		assert.Zero(t, rle.RetryAfter)
+		assert.Equal(t, autocounter.ErrRateLimited.Error(), err.Error())
+	})
+
+	t.Run("rate limit error is matched when wrapped", func(t *testing.T) {
+		err := fmt.Errorf("couldn't query the database: %w", &RateLimitError{RetryAfter: time.Second})
+		assert.True(t, errors.Is(err, autocounter.ErrRateLimited))
+		assert.False(t, errors.Is(err, autocounter.ErrUnauthorized))
+	})
+
+	t.Run("other responses carry the status and the body", func(t *testing.T) {
+		err := unexpectedResErr(res(http.StatusBadRequest, nil, `{"code":"validation_error"}`))
+		assert.False(t, errors.Is(err, autocounter.ErrRateLimited))
+		assert.Equal(t, `unexpected error 400: {"code":"validation_error"}`, err.Error())
+	})
+}
@@ -0,0 +1,308 @@
+package notiontest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/notionplusid/core/app/provider/notion"
+)
+
+// match reports whether the page satisfies the filter.
+// The error is returned when the filter doesn't fit the database schema.
+func match(db notion.Database, p *notion.Page, f notion.DBFilter) (bool, error) {
+	switch {
+	case len(f.And) > 0:
+		for _, sub := range f.And {
+			ok, err := match(db, p, sub)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	case len(f.Or) > 0:
+		for _, sub := range f.Or {
+			ok, err := match(db, p, sub)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	switch f.Timestamp {
+	case notion.DBSortTimestampCreated:
+		if f.CreatedTime == nil {
+			return false, fmt.Errorf("created_time filter is required for timestamp %s", f.Timestamp)
+		}
+		return matchDate(&p.CreatedTime, *f.CreatedTime), nil
+	case notion.DBSortTimestampLastEdited:
+		if f.LastEditedTime == nil {
+			return false, fmt.Errorf("last_edited_time filter is required for timestamp %s", f.Timestamp)
+		}
+		return matchDate(&p.LastEditedTime, *f.LastEditedTime), nil
+	}
+
+	schema, ok := db.Properties[f.Property]
+	if !ok {
+		return false, fmt.Errorf("Could not find property with name or id: %s", f.Property)
+	}
+	prop := p.Properties[f.Property]
+
+	expect := func(pt notion.PropertyType) error {
+		if schema.Type != pt {
+			return fmt.Errorf("database property %s does not match filter %s", schema.Type, pt)
+		}
+		return nil
+	}
+
+	switch {
+	case f.Number != nil:
+		if err := expect(notion.PropertyTypeNumber); err != nil {
+			return false, err
+		}
+		return matchNumber(prop.Number, *f.Number), nil
+	case f.RichText != nil:
+		if err := expect(notion.PropertyTypeRichText); err != nil {
+			return false, err
+		}
+		return matchText(notion.Text(prop.RichText), *f.RichText), nil
+	case f.Title != nil:
+		if err := expect(notion.PropertyTypeTitle); err != nil {
+			return false, err
+		}
+		return matchText(notion.Text(prop.Title), *f.Title), nil
+	case f.Checkbox != nil:
+		if err := expect(notion.PropertyTypeCheckbox); err != nil {
+			return false, err
+		}
+		v := prop.Checkbox != nil && *prop.Checkbox
+		switch {
+		case f.Checkbox.Equals != nil:
+			return v == *f.Checkbox.Equals, nil
+		case f.Checkbox.DoesNotEqual != nil:
+			return v != *f.Checkbox.DoesNotEqual, nil
+		}
+		return true, nil
+	case f.Select != nil:
+		if err := expect(notion.PropertyTypeSelect); err != nil {
+			return false, err
+		}
+		var names []string
+		if prop.Select != nil {
+			names = append(names, prop.Select.Name)
+		}
+		return matchOptions(names, f.Select.Equals, f.Select.DoesNotEqual, f.Select.IsEmpty, f.Select.IsNotEmpty), nil
+	case f.MultiSelect != nil:
+		if err := expect(notion.PropertyTypeMultiSelect); err != nil {
+			return false, err
+		}
+		var names []string
+		for _, o := range prop.MultiSelect {
+			names = append(names, o.Name)
+		}
+		fm := f.MultiSelect
+		equals, doesNotEqual := fm.Equals, fm.DoesNotEqual
+		if fm.Contains != "" {
+			equals = &fm.Contains
+		}
+		if fm.DoesNotContain != "" {
+			doesNotEqual = &fm.DoesNotContain
+		}
+		return matchOptions(names, equals, doesNotEqual, fm.IsEmpty, fm.IsNotEmpty), nil
+	case f.Relation != nil:
+		if err := expect(notion.PropertyTypeRelation); err != nil {
+			return false, err
+		}
+		var ids []string
+		for _, r := range prop.Relation {
+			ids = append(ids, r.ID)
+		}
+		fr := f.Relation
+		var contains, doesNotContain *string
+		if fr.Contains != "" {
+			contains = &fr.Contains
+		}
+		if fr.DoesNotContain != "" {
+			doesNotContain = &fr.DoesNotContain
+		}
+		return matchOptions(ids, contains, doesNotContain, fr.IsEmpty, fr.IsNotEmpty), nil
+	case f.Date != nil:
+		if err := expect(notion.PropertyTypeDate); err != nil {
+			return false, err
+		}
+		var v *time.Time
+		if prop.Date != nil {
+			v = &prop.Date.Start
+		}
+		return matchDate(v, *f.Date), nil
+	}
+
+	return false, fmt.Errorf("unsupported filter for property %s", f.Property)
+}
+
+func matchNumber(v *float64, f notion.DBFilterNumber) bool {
+	switch {
+	case f.IsEmpty:
+		return v == nil
+	case f.IsNotEmpty:
+		return v != nil
+	case v == nil:
+		return f.DoesNotEqual != nil
+	case f.Equals != nil:
+		return *v == *f.Equals
+	case f.DoesNotEqual != nil:
+		return *v != *f.DoesNotEqual
+	case f.GreaterThan != nil:
+		return *v > *f.GreaterThan
+	case f.LessThan != nil:
+		return *v < *f.LessThan
+	case f.GreaterThanOrEqualTo != nil:
+		return *v >= *f.GreaterThanOrEqualTo
+	case f.LessThanOrEqualTo != nil:
+		return *v <= *f.LessThanOrEqualTo
+	}
+
+	return true
+}
+
+func matchText(v string, f notion.DBFilterText) bool {
+	switch {
+	case f.IsEmpty:
+		return v == ""
+	case f.IsNotEmpty:
+		return v != ""
+	case f.Equals != nil:
+		return v == *f.Equals
+	case f.DoesNotEqual != nil:
+		return v != *f.DoesNotEqual
+	case f.Contains != "":
+		return strings.Contains(v, f.Contains)
+	case f.DoesNotContain != "":
+		return !strings.Contains(v, f.DoesNotContain)
+	case f.StartsWith != "":
+		return strings.HasPrefix(v, f.StartsWith)
+	case f.EndsWith != "":
+		return strings.HasSuffix(v, f.EndsWith)
+	}
+
+	return true
+}
+
+func matchOptions(vals []string, equals, doesNotEqual *string, isEmpty, isNotEmpty bool) bool {
+	has := func(s string) bool {
+		for _, v := range vals {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case isEmpty:
+		return len(vals) == 0
+	case isNotEmpty:
+		return len(vals) > 0
+	case equals != nil:
+		return has(*equals)
+	case doesNotEqual != nil:
+		return !has(*doesNotEqual)
+	}
+
+	return true
+}
+
+func matchDate(v *time.Time, f notion.DBFilterDate) bool {
+	switch {
+	case f.IsEmpty:
+		return v == nil
+	case f.IsNotEmpty:
+		return v != nil
+	case v == nil:
+		return false
+	case f.Equals != nil:
+		return v.Equal(*f.Equals)
+	case f.Before != nil:
+		return v.Before(*f.Before)
+	case f.After != nil:
+		return v.After(*f.After)
+	case f.OnOrBefore != nil:
+		return !v.After(*f.OnOrBefore)
+	case f.OnOrAfter != nil:
+		return !v.Before(*f.OnOrAfter)
+	}
+
+	return true
+}
+
+// less reports whether the page a goes before b according to the sorts.
+// Pages without the value go last, as they do in Notion.
+func less(a, b *notion.Page, sorts []notion.DBSort) bool {
+	for _, s := range sorts {
+		c := compare(a, b, s)
+		if c == 0 {
+			continue
+		}
+		if s.Direction == notion.DBSortDirectionDesc {
+			c = -c
+		}
+		return c < 0
+	}
+
+	return false
+}
+
+func compare(a, b *notion.Page, s notion.DBSort) int {
+	switch s.Timestamp {
+	case notion.DBSortTimestampCreated:
+		return compareTime(a.CreatedTime, b.CreatedTime)
+	case notion.DBSortTimestampLastEdited:
+		return compareTime(a.LastEditedTime, b.LastEditedTime)
+	}
+
+	pa, pb := a.Properties[s.Property], b.Properties[s.Property]
+	switch pa.Type {
+	case notion.PropertyTypeNumber:
+		switch {
+		case pa.Number == nil && pb.Number == nil:
+			return 0
+		case pa.Number == nil:
+			return emptyLast(s)
+		case pb.Number == nil:
+			return -emptyLast(s)
+		case *pa.Number < *pb.Number:
+			return -1
+		case *pa.Number > *pb.Number:
+			return 1
+		}
+		return 0
+	case notion.PropertyTypeRichText:
+		return strings.Compare(notion.Text(pa.RichText), notion.Text(pb.RichText))
+	case notion.PropertyTypeTitle:
+		return strings.Compare(notion.Text(pa.Title), notion.Text(pb.Title))
+	}
+
+	return 0
+}
+
+// emptyLast returns the comparison result that keeps the empty value last regardless of the direction.
+func emptyLast(s notion.DBSort) int {
+	if s.Direction == notion.DBSortDirectionDesc {
+		return -1
+	}
+	return 1
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
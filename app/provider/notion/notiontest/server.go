@@ -0,0 +1,634 @@
+// Package notiontest provides the in-process fake of the Notion API for tests.
+//
+// The fake keeps the databases and pages in memory and supports the subset of the API used by the notion package:
+// search, database retrieval and query, page update, bot user and OAuth token exchange.
+package notiontest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/notionplusid/core/app/provider/notion"
+)
+
+const maxPageSize = 100
+
+type database struct {
+	db notion.Database
+	// page IDs in the order of creation.
+	pageIDs []string
+}
+
+type failure struct {
+	status int
+	times  int
+}
+
+// Server is the fake Notion API.
+type Server struct {
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	dbs      map[string]*database
+	dbIDs    []string
+	pages    map[string]*notion.Page
+	revoked  map[string]struct{}
+	codes    map[string]notion.OAuth2Res
+	clientID string
+	secret   string
+	failures map[string]*failure
+	requests map[string]int
+	seq      int
+	lastTime time.Time
+}
+
+// NewServer starts the fake Notion API server.
+// Every bearer token is authorized unless it was revoked.
+func NewServer() *Server {
+	s := &Server{
+		dbs:      map[string]*database{},
+		pages:    map[string]*notion.Page{},
+		revoked:  map[string]struct{}{},
+		codes:    map[string]notion.OAuth2Res{},
+		failures: map[string]*failure{},
+		requests: map[string]int{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", s.authorized(s.search))
+	mux.HandleFunc("/v1/databases/", s.authorized(s.databases))
+	mux.HandleFunc("/v1/pages/", s.authorized(s.patchPage))
+	mux.HandleFunc("/v1/users/me", s.authorized(s.me))
+	mux.HandleFunc("/v1/oauth/token", s.oauthToken)
+
+	s.srv = httptest.NewServer(s.intercept(mux))
+
+	return s
+}
+
+// URL of the server to be used as the Notion API URL.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Client returns the HTTP Client that is able to talk to the server.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// Close the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Revoke the access of the bearer token.
+func (s *Server) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[token] = struct{}{}
+}
+
+// SetOAuthClient sets the credentials expected by the OAuth token exchange.
+func (s *Server) SetOAuthClient(clientID, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clientID = clientID
+	s.secret = secret
+}
+
+// AddOAuthCode registers the code that is exchanged to the provided response.
+func (s *Server) AddOAuthCode(code string, res notion.OAuth2Res) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.codes[code] = res
+}
+
+// Fail the next `times` requests with the provided method and path with the status code.
+func (s *Server) Fail(method, path string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[method+" "+path] = &failure{status: status, times: times}
+}
+
+// Requests returns the amount of the requests received with the provided method and path prefix.
+func (s *Server) Requests(method, pathPrefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var count int
+	for k, n := range s.requests {
+		if strings.HasPrefix(k, method+" "+pathPrefix) {
+			count += n
+		}
+	}
+
+	return count
+}
+
+// AddDatabase with the provided schema of property names and types.
+func (s *Server) AddDatabase(id, title string, schema map[string]notion.PropertyType) notion.Database {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.tick()
+	db := notion.Database{
+		ID:             id,
+		Object:         "database",
+		CreatedTime:    now,
+		LastEditedTime: now,
+		Title:          notion.NewRichText(title),
+		Properties:     map[string]notion.DatabaseProperty{},
+	}
+	db.Parent.Type = "workspace"
+	db.Parent.Workspace = true
+	for name, pt := range schema {
+		db.Properties[name] = notion.DatabaseProperty{ID: s.nextID("prop"), Type: pt}
+	}
+
+	s.dbs[id] = &database{db: db}
+	s.dbIDs = append(s.dbIDs, id)
+
+	return db
+}
+
+// RemoveDatabase so it's not found anymore.
+func (s *Server) RemoveDatabase(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.dbs, id)
+	for i, dbID := range s.dbIDs {
+		if dbID == id {
+			s.dbIDs = append(s.dbIDs[:i], s.dbIDs[i+1:]...)
+			break
+		}
+	}
+}
+
+// AddPage to the database with the provided property values.
+// The page is created after all the previously added pages.
+func (s *Server) AddPage(dbID string, props map[string]notion.PageProperty) notion.Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addPage(dbID, s.tick(), props)
+}
+
+// AddPageAt adds the page to the database with the provided creation time.
+func (s *Server) AddPageAt(dbID string, createdTime time.Time, props map[string]notion.PageProperty) notion.Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addPage(dbID, createdTime, props)
+}
+
+func (s *Server) addPage(dbID string, createdTime time.Time, props map[string]notion.PageProperty) notion.Page {
+	d, ok := s.dbs[dbID]
+	if !ok {
+		panic(fmt.Sprintf("notiontest: unknown database %s", dbID))
+	}
+
+	p := &notion.Page{
+		ID:             s.nextID("page"),
+		Object:         "page",
+		CreatedTime:    createdTime,
+		LastEditedTime: createdTime,
+		Properties:     map[string]notion.PageProperty{},
+	}
+	p.Parent.Type = "database_id"
+	p.Parent.DatabaseID = dbID
+
+	for name, schema := range d.db.Properties {
+		prop, ok := props[name]
+		if !ok {
+			prop = notion.PageProperty{}
+		}
+		p.Properties[name] = normalize(schema, prop)
+	}
+
+	s.pages[p.ID] = p
+	d.pageIDs = append(d.pageIDs, p.ID)
+
+	return *p
+}
+
+// ArchivePage so it's not returned by the queries anymore.
+func (s *Server) ArchivePage(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.pages[id]; ok {
+		p.Archived = true
+	}
+}
+
+// Page returns the current state of the page.
+func (s *Server) Page(id string) (notion.Page, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pages[id]
+	if !ok {
+		return notion.Page{}, false
+	}
+
+	return *p, true
+}
+
+// Pages returns the current state of the database pages in the order of creation.
+func (s *Server) Pages(dbID string) []notion.Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.dbs[dbID]
+	if !ok {
+		return nil
+	}
+
+	var res []notion.Page
+	for _, id := range d.pageIDs {
+		res = append(res, *s.pages[id])
+	}
+
+	return res
+}
+
+// tick returns the current time that is always after the previously returned one.
+func (s *Server) tick() time.Time {
+	now := time.Now().UTC()
+	if !now.After(s.lastTime) {
+		now = s.lastTime.Add(time.Millisecond)
+	}
+	s.lastTime = now
+
+	return now
+}
+
+func (s *Server) nextID(prefix string) string {
+	s.seq++
+	return prefix + "-" + strconv.Itoa(s.seq)
+}
+
+// normalize the property value according to the schema, the way Notion API returns it.
+func normalize(schema notion.DatabaseProperty, prop notion.PageProperty) notion.PageProperty {
+	prop.ID = schema.ID
+	prop.Type = schema.Type
+	for _, rts := range [][]notion.RichText{prop.Title, prop.RichText} {
+		for i := range rts {
+			if rts[i].PlainText == "" && rts[i].Text != nil {
+				rts[i].PlainText = rts[i].Text.Content
+			}
+		}
+	}
+
+	return prop
+}
+
+// Number property value.
+func Number(v float64) notion.PageProperty {
+	return notion.PageProperty{Type: notion.PropertyTypeNumber, Number: &v}
+}
+
+// RichText property value.
+func RichText(text string) notion.PageProperty {
+	return notion.PageProperty{Type: notion.PropertyTypeRichText, RichText: notion.NewRichText(text)}
+}
+
+// Title property value.
+func Title(text string) notion.PageProperty {
+	return notion.PageProperty{Type: notion.PropertyTypeTitle, Title: notion.NewRichText(text)}
+}
+
+// Select property value.
+func Select(name string) notion.PageProperty {
+	return notion.PageProperty{Type: notion.PropertyTypeSelect, Select: &notion.SelectOption{Name: name}}
+}
+
+// MultiSelect property value.
+func MultiSelect(names ...string) notion.PageProperty {
+	p := notion.PageProperty{Type: notion.PropertyTypeMultiSelect}
+	for _, n := range names {
+		p.MultiSelect = append(p.MultiSelect, notion.SelectOption{Name: n})
+	}
+
+	return p
+}
+
+// Relation property value.
+func Relation(pageIDs ...string) notion.PageProperty {
+	p := notion.PageProperty{Type: notion.PropertyTypeRelation}
+	for _, id := range pageIDs {
+		p.Relation = append(p.Relation, notion.PageReference{ID: id})
+	}
+
+	return p
+}
+
+// Date property value.
+func Date(start time.Time) notion.PageProperty {
+	return notion.PageProperty{Type: notion.PropertyTypeDate, Date: &notion.PagePropertyDate{Start: start}}
+}
+
+type apiErr struct {
+	Object  string `json:"object"`
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeErr(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, apiErr{Object: "error", Status: status, Code: code, Message: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v) // nolint: errcheck
+}
+
+// intercept counts the requests and fails the ones that were requested to fail.
+func (s *Server) intercept(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+
+		s.mu.Lock()
+		s.requests[key]++
+		f, ok := s.failures[key]
+		if ok && f.times > 0 {
+			f.times--
+			s.mu.Unlock()
+			writeErr(w, f.status, "fake_failure", "failure requested by the test")
+			return
+		}
+		s.mu.Unlock()
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authorized(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		_, revoked := s.revoked[token]
+		s.mu.Unlock()
+
+		if token == "" || revoked {
+			writeErr(w, http.StatusUnauthorized, "unauthorized", "API token is invalid.")
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErr(w, http.StatusMethodNotAllowed, "invalid_request", "method not allowed")
+		return
+	}
+
+	var req notion.SearchReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := []json.RawMessage{}
+	if req.Filter == nil || req.Filter.Value == notion.FilterValueDB {
+		for _, id := range s.dbIDs {
+			b, _ := json.Marshal(s.dbs[id].db)
+			results = append(results, b)
+		}
+	}
+	if req.Filter == nil || req.Filter.Value == notion.FilterValuePage {
+		for _, id := range s.dbIDs {
+			for _, pID := range s.dbs[id].pageIDs {
+				b, _ := json.Marshal(s.pages[pID])
+				results = append(results, b)
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"object":   "list",
+		"results":  results,
+		"has_more": false,
+	})
+}
+
+func (s *Server) databases(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/databases/")
+	id, action, _ := strings.Cut(path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.dbs[id]
+	if !ok {
+		writeErr(w, http.StatusNotFound, "object_not_found", fmt.Sprintf("Could not find database with ID: %s.", id))
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, d.db)
+	case action == "query" && r.Method == http.MethodPost:
+		s.query(w, r, d)
+	default:
+		writeErr(w, http.StatusNotFound, "invalid_request_url", "Invalid request URL.")
+	}
+}
+
+func (s *Server) query(w http.ResponseWriter, r *http.Request, d *database) {
+	var req notion.DBQueryReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+
+	var res []*notion.Page
+	for _, id := range d.pageIDs {
+		p := s.pages[id]
+		if p.Archived {
+			continue
+		}
+
+		if req.Filter != nil {
+			ok, err := match(d.db, p, *req.Filter)
+			if err != nil {
+				writeErr(w, http.StatusBadRequest, "validation_error", err.Error())
+				return
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		res = append(res, p)
+	}
+
+	for _, srt := range req.Sorts {
+		if srt.Property == "" {
+			continue
+		}
+		if _, ok := d.db.Properties[srt.Property]; !ok {
+			writeErr(w, http.StatusBadRequest, "validation_error", fmt.Sprintf("Could not find sort property with name or id: %s", srt.Property))
+			return
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return less(res[i], res[j], req.Sorts)
+	})
+
+	// the cursor is the ID of the first page of the next batch.
+	start := 0
+	if req.StartCursor != "" {
+		start = -1
+		for i, p := range res {
+			if p.ID == req.StartCursor {
+				start = i
+				break
+			}
+		}
+		if start < 0 {
+			writeErr(w, http.StatusBadRequest, "validation_error", "start_cursor provided is invalid")
+			return
+		}
+	}
+
+	size := int(req.PageSize)
+	if size <= 0 || size > maxPageSize {
+		size = maxPageSize
+	}
+
+	end := start + size
+	if end > len(res) {
+		end = len(res)
+	}
+
+	qRes := notion.DBQueryRes{
+		Object: "list",
+		Result: []notion.Page{},
+	}
+	for _, p := range res[start:end] {
+		qRes.Result = append(qRes.Result, *p)
+	}
+	if end < len(res) {
+		next := res[end].ID
+		qRes.HasMore = true
+		qRes.NextCursor = &next
+	}
+
+	writeJSON(w, http.StatusOK, qRes)
+}
+
+func (s *Server) patchPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		writeErr(w, http.StatusMethodNotAllowed, "invalid_request", "method not allowed")
+		return
+	}
+
+	var req notion.PatchPageReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/v1/pages/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pages[id]
+	if !ok {
+		writeErr(w, http.StatusNotFound, "object_not_found", fmt.Sprintf("Could not find page with ID: %s.", id))
+		return
+	}
+	d, ok := s.dbs[p.Parent.DatabaseID]
+	if !ok {
+		writeErr(w, http.StatusNotFound, "object_not_found", fmt.Sprintf("Could not find page with ID: %s.", id))
+		return
+	}
+
+	for name, prop := range req.Properties {
+		schema, ok := d.db.Properties[name]
+		if !ok {
+			writeErr(w, http.StatusBadRequest, "validation_error", fmt.Sprintf("%s is not a property that exists.", name))
+			return
+		}
+		if prop.Type != "" && prop.Type != schema.Type {
+			writeErr(w, http.StatusBadRequest, "validation_error", fmt.Sprintf("%s is expected to be %s.", name, schema.Type))
+			return
+		}
+	}
+
+	for name, prop := range req.Properties {
+		p.Properties[name] = normalize(d.db.Properties[name], prop)
+	}
+	if req.Archived {
+		p.Archived = true
+	}
+	p.LastEditedTime = s.tick()
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) me(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, notion.User{
+		Object: "user",
+		ID:     "bot",
+		Type:   "bot",
+		Name:   "Plus ID",
+	})
+}
+
+func (s *Server) oauthToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErr(w, http.StatusMethodNotAllowed, "invalid_request", "method not allowed")
+		return
+	}
+
+	var req struct {
+		GrantType string `json:"grant_type"`
+		Code      string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid_json", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != s.clientID || secret != s.secret {
+		writeErr(w, http.StatusUnauthorized, "invalid_client", "Invalid client.")
+		return
+	}
+
+	res, ok := s.codes[req.Code]
+	if !ok || req.GrantType != "authorization_code" {
+		writeErr(w, http.StatusBadRequest, "invalid_grant", "Invalid code.")
+		return
+	}
+	// the code can be exchanged only once.
+	delete(s.codes, req.Code)
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -21,7 +20,7 @@ type Page struct {
 	URL            string    `json:"url"`
 	Archived       bool      `json:"archived"`
 	CreatedTime    time.Time `json:"created_time"`
-	LastEditedTime time.Time `json:"last_edited_at"`
+	LastEditedTime time.Time `json:"last_edited_time"`
 	Parent         struct {
 		Type       string `json:"type"`
 		PageID     string `json:"page_id,omitempty"`
@@ -45,28 +44,18 @@ type PageProperty struct {
 		Boolean *bool             `json:"boolean,omitempty"`
 		Date    *PagePropertyDate `json:"date,omitempty"`
 	} `json:"formula,omitempty"`
-	Relation []struct {
-		ID string `json:"id"`
-	} `json:"relation,omitempty"`
-	Rollup *struct {
+	Relation []PageReference `json:"relation,omitempty"`
+	Rollup   *struct {
 		Type   string            `json:"type"`
 		Number float64           `json:"number,omitempty"`
 		Date   *PagePropertyDate `json:"date,omitempty"`
 		Array  []PageProperty    `json:"array,omitempty"`
 	} `json:"rollup,omitempty"`
-	Select *struct {
-		ID    string `json:"id,omitempty"`
-		Name  string `json:"name,omitempty"`
-		Color string `json:"color,omitempty"`
-	} `json:"select,omitempty"`
-	MultiSelect []struct {
-		ID    string `json:"id"`
-		Name  string `json:"name"`
-		Color string `json:"color"`
-	} `json:"multi_select,omitempty"`
-	Date   *PagePropertyDate `json:"date,omitempty"`
-	People []User            `json:"people,omitempty"`
-	Files  []struct {
+	Select      *SelectOption     `json:"select,omitempty"`
+	MultiSelect []SelectOption    `json:"multi_select,omitempty"`
+	Date        *PagePropertyDate `json:"date,omitempty"`
+	People      []User            `json:"people,omitempty"`
+	Files       []struct {
 		Name string `json:"name"`
 	} `json:"files,omitempty"`
 	Checkbox       *bool      `json:"checkbox,omitempty"`
@@ -79,6 +68,18 @@ type PageProperty struct {
 	LastEditedBy   *User      `json:"last_edited_by,omitempty"`
 }
 
+// PageReference is a link to another page, e.g. within the relation property.
+type PageReference struct {
+	ID string `json:"id"`
+}
+
+// SelectOption of the select and multi-select properties.
+type SelectOption struct {
+	ID    string `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Color string `json:"color,omitempty"`
+}
+
 // PagePropertyDate format allows to cast the otherwise string formatted date property values.
 type PagePropertyDate struct {
 	// "2006-01-02"
@@ -98,13 +99,13 @@ func (p *PagePropertyDate) MarshalJSON() ([]byte, error) {
 	}{}
 
 	startFormat := "2006-01-02"
-	if onlyDate(p.Start) {
+	if !onlyDate(p.Start) {
 		startFormat = time.RFC3339Nano
 	}
 	pRaw.Start = p.Start.Format(startFormat)
 	if !p.End.IsZero() {
 		endFormat := "2006-01-02"
-		if onlyDate(p.End) {
+		if !onlyDate(p.End) {
 			endFormat = time.RFC3339Nano
 		}
 		pRaw.End = p.End.Format(endFormat)
@@ -179,7 +180,7 @@ func (n *Notion) PatchPage(ctx context.Context, pageID string, q PatchPageReq) (
 		return Page{}, fmt.Errorf("couldn't parse patch page request: %s", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPatch, defaultAPIPath+"/v1/pages/"+pageID, bytes.NewBuffer(b))
+	req, err := http.NewRequest(http.MethodPatch, n.apiURL+"/v1/pages/"+pageID, bytes.NewBuffer(b))
 	if err != nil {
 		return Page{}, err
 	}
@@ -198,8 +199,7 @@ func (n *Notion) PatchPage(ctx context.Context, pageID string, q PatchPageReq) (
 	case 404:
 		return Page{}, autocounter.ErrPageNotFound
 	default:
-		e, _ := io.ReadAll(res.Body)
-		return Page{}, fmt.Errorf("unexpected error %d: %s", res.StatusCode, e)
+		return Page{}, unexpectedResErr(res)
 	}
 
 	var qRes Page
@@ -2,23 +2,18 @@ package notion
 
 // RichText representation.
 type RichText struct {
-	PlainText   string `json:"plain_text"`
+	PlainText   string `json:"plain_text,omitempty"`
 	HRef        string `json:"href,omitempty"`
-	Annotations struct {
-		Bold          bool   `json:"bool"`
+	Annotations *struct {
+		Bold          bool   `json:"bold"`
 		Italic        bool   `json:"italic"`
 		Strikethrough bool   `json:"strikethrough"`
 		Underline     bool   `json:"underline"`
 		Code          bool   `json:"code"`
 		Color         string `json:"color"`
-	} `json:"annotations"`
-	Type string `json:"type"`
-	Text *struct {
-		Content string `json:"content"`
-		Link    *struct {
-			URL string `json:"url,omitempty"`
-		} `json:"link,omitempty"`
-	} `json:"text,omitempty"`
+	} `json:"annotations,omitempty"`
+	Type    string       `json:"type"`
+	Text    *TextContent `json:"text,omitempty"`
 	Mention *struct {
 		Type string `json:"type"`
 		User *User  `json:"user,omitempty"`
@@ -34,3 +29,34 @@ type RichText struct {
 		Expression string `json:"expression"`
 	} `json:"equation,omitempty"`
 }
+
+// TextContent of the RichText with the "text" type.
+type TextContent struct {
+	Content string `json:"content"`
+	Link    *struct {
+		URL string `json:"url,omitempty"`
+	} `json:"link,omitempty"`
+}
+
+// NewRichText returns the rich text sequence that consists of the single plain text item.
+func NewRichText(content string) []RichText {
+	return []RichText{{
+		Type: "text",
+		Text: &TextContent{Content: content},
+	}}
+}
+
+// Text returns the plain text content of the rich text sequence.
+func Text(rts []RichText) string {
+	var text string
+	for _, rt := range rts {
+		switch {
+		case rt.PlainText != "":
+			text += rt.PlainText
+		case rt.Text != nil:
+			text += rt.Text.Content
+		}
+	}
+
+	return text
+}
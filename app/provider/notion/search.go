@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 
 	autocounter "github.com/notionplusid/core/app"
@@ -254,7 +253,7 @@ func (n *Notion) Search(ctx context.Context, sReq SearchReq) (SearchRes, error)
 		return SearchRes{}, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, defaultAPIPath+"/v1/search", bytes.NewBuffer(srJSON))
+	req, err := http.NewRequest(http.MethodPost, n.apiURL+"/v1/search", bytes.NewBuffer(srJSON))
 	if err != nil {
 		return SearchRes{}, err
 	}
@@ -273,8 +272,7 @@ func (n *Notion) Search(ctx context.Context, sReq SearchReq) (SearchRes, error)
 	case 401:
 		return SearchRes{}, autocounter.ErrUnauthorized
 	default:
-		e, _ := io.ReadAll(res.Body)
-		return SearchRes{}, fmt.Errorf("unexpected error %d: %s", res.StatusCode, e)
+		return SearchRes{}, unexpectedResErr(res)
 	}
 
 	var sRes SearchRes
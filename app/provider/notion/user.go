@@ -3,8 +3,6 @@ package notion
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
 
 	autocounter "github.com/notionplusid/core/app"
@@ -24,7 +22,7 @@ type User struct {
 // Me returns the bot's User data.
 // Primarily used to verify the access to the workspace.
 func (n *Notion) Me(ctx context.Context) (User, error) {
-	req, err := http.NewRequest(http.MethodGet, defaultAPIPath+"/v1/users/me", nil)
+	req, err := http.NewRequest(http.MethodGet, n.apiURL+"/v1/users/me", nil)
 	if err != nil {
 		return User{}, err
 	}
@@ -43,8 +41,7 @@ func (n *Notion) Me(ctx context.Context) (User, error) {
 	case 401:
 		return User{}, autocounter.ErrUnauthorized
 	default:
-		e, _ := io.ReadAll(res.Body)
-		return User{}, fmt.Errorf("unexpected error %d: %s", res.StatusCode, e)
+		return User{}, unexpectedResErr(res)
 	}
 
 	var u User
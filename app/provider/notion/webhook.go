@@ -0,0 +1,89 @@
+package notion
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// WebhookSignatureHeader carries the signature of the webhook request body.
+const WebhookSignatureHeader = "X-Notion-Signature"
+
+// ErrInvalidSignature is returned in case if the webhook request isn't signed with the verification token.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// WebhookEventType as sent by Notion: https://developers.notion.com/reference/webhooks-events-delivery
+type WebhookEventType string
+
+// Known WebhookEventTypes that might bring the pages to number.
+const (
+	WebhookEventPageCreated           WebhookEventType = "page.created"
+	WebhookEventPagePropertiesUpdated WebhookEventType = "page.properties_updated"
+	WebhookEventPageUndeleted         WebhookEventType = "page.undeleted"
+	WebhookEventPageMoved             WebhookEventType = "page.moved"
+)
+
+// WebhookEvent delivered by Notion.
+// The very first request of the subscription carries only the VerificationToken,
+// which has to be confirmed in the integration settings and is used to sign the following events.
+type WebhookEvent struct {
+	VerificationToken string `json:"verification_token,omitempty"`
+
+	ID             string           `json:"id"`
+	Type           WebhookEventType `json:"type"`
+	Timestamp      time.Time        `json:"timestamp"`
+	WorkspaceID    string           `json:"workspace_id"`
+	SubscriptionID string           `json:"subscription_id"`
+	IntegrationID  string           `json:"integration_id"`
+	AttemptNumber  int              `json:"attempt_number"`
+	Entity         WebhookEntity    `json:"entity"`
+	Data           struct {
+		Parent *WebhookEntity `json:"parent,omitempty"`
+	} `json:"data"`
+}
+
+// WebhookEntity is the object the event is about.
+type WebhookEntity struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// DatabaseID returns the ID of the database the page of the event belongs to.
+// Returns false in case if the event isn't about the database page or might not bring the pages to number.
+func (e WebhookEvent) DatabaseID() (string, bool) {
+	switch e.Type {
+	case WebhookEventPageCreated, WebhookEventPagePropertiesUpdated, WebhookEventPageUndeleted, WebhookEventPageMoved:
+	default:
+		return "", false
+	}
+
+	if e.Entity.Type != "page" || e.Data.Parent == nil || e.Data.Parent.Type != "database" {
+		return "", false
+	}
+
+	return e.Data.Parent.ID, true
+}
+
+// VerifyWebhookSignature of the request body.
+// The signature is expected as `sha256=<hex HMAC-SHA256 of the body keyed with the verification token>`.
+func VerifyWebhookSignature(verificationToken string, body []byte, signature string) error {
+	if verificationToken == "" {
+		return errors.New("verification token is required")
+	}
+
+	provided, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !strings.HasPrefix(signature, "sha256=") {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(verificationToken))
+	mac.Write(body) // nolint: errcheck
+	if !hmac.Equal(provided, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
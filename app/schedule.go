@@ -0,0 +1,114 @@
+package autocounter
+
+import (
+	"errors"
+	"time"
+)
+
+// Pass is the outcome of the Workspace processing.
+type Pass struct {
+	// Numbered is the amount of pages that got their identifiers.
+	Numbered int64 `json:"numbered,omitempty"`
+	// Registered is the amount of tables that were found and registered.
+	Registered int `json:"registered,omitempty"`
+	// Error of the failed pass.
+	Error string `json:"error,omitempty"`
+
+	// Idle is the amount of passes in a row, including this one, that neither numbered pages nor registered tables.
+	Idle int `json:"idle,omitempty"`
+	// Failures is the amount of passes in a row, including this one, that failed.
+	Failures int `json:"failures,omitempty"`
+}
+
+// Busy returns true if the pass numbered pages or registered tables.
+func (p Pass) Busy() bool {
+	return p.Numbered > 0 || p.Registered > 0
+}
+
+// DefaultSchedule of the Workspace passes.
+var DefaultSchedule = Schedule{
+	Busy:            0,
+	Idle:            30 * time.Second,
+	MaxIdle:         10 * time.Minute,
+	QuarantineAfter: 5,
+	Quarantine:      6 * time.Hour,
+}
+
+// Schedule of the Workspace passes: the busy Workspaces are processed right away,
+// the idle and failing ones are backed off exponentially and the repeatedly failing ones are quarantined.
+type Schedule struct {
+	// Busy is the pause after the busy pass.
+	Busy time.Duration
+	// Idle is the pause after the first idle or failed pass, it doubles with every next one up to MaxIdle.
+	Idle    time.Duration
+	MaxIdle time.Duration
+	// QuarantineAfter failed passes in a row the Workspace is paused for Quarantine, zero disables the quarantine.
+	QuarantineAfter int
+	Quarantine      time.Duration
+}
+
+// Validate the Schedule.
+func (s Schedule) Validate() error {
+	switch {
+	case s.Busy < 0:
+		return errors.New("busy pause can't be negative")
+	case s.Idle < 0:
+		return errors.New("idle pause can't be negative")
+	case s.MaxIdle < s.Idle:
+		return errors.New("max idle pause can't be less than the idle one")
+	case s.QuarantineAfter < 0:
+		return errors.New("quarantine threshold can't be negative")
+	case s.Quarantine < 0:
+		return errors.New("quarantine can't be negative")
+	}
+
+	return nil
+}
+
+// Next returns the Workspace with the outcome of the pass finished at `now` recorded
+// and the next pass scheduled accordingly.
+func (s Schedule) Next(ws Workspace, p Pass, now time.Time) Workspace {
+	p.Idle, p.Failures = 0, 0
+	switch {
+	case p.Error != "":
+		p.Failures = ws.LastPass.Failures + 1
+	case !p.Busy():
+		p.Idle = ws.LastPass.Idle + 1
+	}
+
+	ws.LastPass = p
+	ws.NextRunAt = now.Add(s.pause(p))
+
+	return ws
+}
+
+// Quarantined returns true if the pass makes the Workspace quarantined.
+func (s Schedule) Quarantined(p Pass) bool {
+	return s.QuarantineAfter > 0 && p.Failures >= s.QuarantineAfter
+}
+
+func (s Schedule) pause(p Pass) time.Duration {
+	switch {
+	case s.Quarantined(p):
+		return s.Quarantine
+	case p.Failures > 0:
+		return s.backoff(p.Failures)
+	case p.Idle > 0:
+		return s.backoff(p.Idle)
+	}
+
+	return s.Busy
+}
+
+// backoff returns the pause after n idle or failed passes in a row.
+func (s Schedule) backoff(n int) time.Duration {
+	d := s.Idle
+	for i := 1; i < n && d < s.MaxIdle; i++ {
+		d *= 2
+	}
+	if d > s.MaxIdle {
+		d = s.MaxIdle
+	}
+
+	return d
+}
@@ -0,0 +1,71 @@
+package autocounter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleNext(t *testing.T) {
+	now := time.Now()
+	s := Schedule{
+		Busy:            time.Second,
+		Idle:            time.Minute,
+		MaxIdle:         5 * time.Minute,
+		QuarantineAfter: 3,
+		Quarantine:      time.Hour,
+	}
+	ws := Workspace{ID: "ws"}
+
+	t.Run("busy workspace is processed soon", func(t *testing.T) {
+		busy := s.Next(ws, Pass{Numbered: 10}, now)
+		assert.Equal(t, now.Add(time.Second), busy.NextRunAt)
+		assert.Equal(t, int64(10), busy.LastPass.Numbered)
+
+		registered := s.Next(ws, Pass{Registered: 1}, now)
+		assert.Equal(t, now.Add(time.Second), registered.NextRunAt)
+	})
+
+	t.Run("idle workspace is backed off up to the cap", func(t *testing.T) {
+		idle := ws
+		var pauses []time.Duration
+		for i := 0; i < 5; i++ {
+			idle = s.Next(idle, Pass{}, now)
+			pauses = append(pauses, idle.NextRunAt.Sub(now))
+		}
+		assert.Equal(t, []time.Duration{
+			time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute,
+		}, pauses)
+		assert.Equal(t, 5, idle.LastPass.Idle)
+
+		// the busy pass resets the backoff.
+		busy := s.Next(idle, Pass{Numbered: 1}, now)
+		assert.Equal(t, 0, busy.LastPass.Idle)
+		assert.Equal(t, now.Add(time.Second), busy.NextRunAt)
+	})
+
+	t.Run("repeatedly failing workspace is quarantined", func(t *testing.T) {
+		failing := s.Next(ws, Pass{Error: "boom"}, now)
+		assert.Equal(t, now.Add(time.Minute), failing.NextRunAt)
+		assert.False(t, s.Quarantined(failing.LastPass))
+
+		failing = s.Next(failing, Pass{Error: "boom"}, now)
+		assert.Equal(t, now.Add(2*time.Minute), failing.NextRunAt)
+
+		failing = s.Next(failing, Pass{Error: "boom"}, now)
+		assert.True(t, s.Quarantined(failing.LastPass))
+		assert.Equal(t, now.Add(time.Hour), failing.NextRunAt)
+		assert.Equal(t, "boom", failing.LastPass.Error)
+
+		recovered := s.Next(failing, Pass{}, now)
+		assert.Equal(t, 0, recovered.LastPass.Failures)
+		assert.Equal(t, now.Add(time.Minute), recovered.NextRunAt)
+	})
+}
+
+func TestScheduleValidate(t *testing.T) {
+	assert.NoError(t, DefaultSchedule.Validate())
+	assert.Error(t, Schedule{Idle: time.Minute}.Validate(), "max idle pause is less than the idle one")
+	assert.Error(t, Schedule{Busy: -time.Second}.Validate())
+}
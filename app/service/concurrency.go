@@ -0,0 +1,35 @@
+package service
+
+import "errors"
+
+// Concurrency limits of the workspace processing.
+type Concurrency struct {
+	// Workspaces processed concurrently by the Tenant.
+	Workspaces int
+	// Tables of the same workspace filled concurrently.
+	Tables int
+	// PageWorkers patch the pages of all the tables of the same workspace token.
+	PageWorkers int
+}
+
+// DefaultConcurrency is used by the services unless configured otherwise.
+// The page workers match the rate limit of the Notion API, which is 3 requests per second.
+var DefaultConcurrency = Concurrency{
+	Workspaces:  10,
+	Tables:      2,
+	PageWorkers: 3,
+}
+
+// Validate the Concurrency.
+func (c *Concurrency) Validate() error {
+	switch {
+	case c.Workspaces < 1:
+		return errors.New("workspaces must be positive")
+	case c.Tables < 1:
+		return errors.New("tables must be positive")
+	case c.PageWorkers < 1:
+		return errors.New("page workers must be positive")
+	}
+
+	return nil
+}
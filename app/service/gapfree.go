@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/internal/metrics"
+	"github.com/notionplusid/core/app/internal/workpool"
+	"github.com/notionplusid/core/app/provider/notion"
+)
+
+// maxHoles is the max amount of the holes collected by the reconciliation, so the huge gaps can't exhaust the memory.
+const maxHoles = 1000
+
+// Reconciliation of the identifiers issued for the Table.
+type Reconciliation struct {
+	TableID string `json:"tableId"`
+	// Counter is the last value issued for the Table.
+	Counter int64 `json:"counter"`
+	// Holes are the values up to the Counter that no page has.
+	Holes []int64 `json:"holes,omitempty"`
+}
+
+// Reconcile finds the holes in the numbering of the Table.
+// All the numbered pages of the Table are scanned.
+// Returns autocounter.ErrIncompatibleTable in case if the Table is grouped or dated.
+func (t *Table) Reconcile(ctx context.Context, tableID string, ws autocounter.Workspace) (Reconciliation, error) {
+	ctx = logging.WithTable(logging.WithWorkspace(ctx, ws.ID), tableID)
+	res := Reconciliation{TableID: tableID}
+
+	table, err := t.FetchForWs(ctx, ws.ID, tableID)
+	if err != nil {
+		return res, err
+	}
+	if table.GroupBy != "" || table.IsDated() {
+		return res, fmt.Errorf("%w: holes aren't reconciled for the grouped and dated tables", autocounter.ErrIncompatibleTable)
+	}
+
+	counter, err := t.s.Counter(ctx, ws.ID, tableID)
+	switch {
+	case err == autocounter.ErrNoResults:
+		// nothing was issued yet.
+		return res, nil
+	case err != nil:
+		return res, fmt.Errorf("couldn't fetch the counter: %w", err)
+	}
+	res.Counter = counter.Value
+
+	notionCli, err := t.clients.ForWorkspace(ctx, ws)
+	if err != nil {
+		return res, fmt.Errorf("couldn't initialize notion api client: %s", err)
+	}
+
+	res.Holes, err = t.holes(ctx, notionCli, table, counter.Value)
+	return res, err
+}
+
+// holes returns the values up to the last issued one that no page of the Table has.
+// The values below the lowest identifier are never the holes, as the counter might be seeded by the identifiers written before.
+func (t *Table) holes(ctx context.Context, notionCli *notion.Notion, table autocounter.Table, last int64) ([]int64, error) {
+	seen := map[int64]struct{}{}
+	var (
+		lowest *int64
+		cursor string
+	)
+	for {
+		res, err := notionCli.QueryDatabase(ctx, table.ID, notion.DBQueryReq{
+			StartCursor: cursor,
+			Filter:      issuedParamFilter(table),
+			PageSize:    int32(t.batchSize),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range res.Result {
+			v, ok, err := pageValue(table, p)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			if lowest == nil || v < *lowest {
+				lowest = &v
+			}
+		}
+
+		if !res.HasMore || res.NextCursor == nil {
+			break
+		}
+		cursor = *res.NextCursor
+	}
+	if lowest == nil {
+		return nil, nil
+	}
+
+	var holes []int64
+	for v := *lowest; v <= last && len(holes) < maxHoles; v += table.Step {
+		if _, ok := seen[v]; !ok {
+			holes = append(holes, v)
+		}
+	}
+
+	return holes, nil
+}
+
+// fillGapFree numbers the pages one by one, so none of the issued values is left unused:
+//   - the holes left by the previous fills are issued first;
+//   - the value of the page that couldn't be patched is reassigned to the next pending page;
+//   - the failed pages are retried once at the end of every batch;
+//   - the reserved values that weren't used are released back to the counter,
+//     the ones that can't be released are kept as the holes of the Table watermark.
+//
+// The holes are looked up by the scan of every numbered page of the Table, so it's done by the full scans only
+// and only when there are pages to number. The rest of the fills issue the holes kept by the watermark,
+// the holes left by the crashed fills are found by the next full scan.
+// The pages that take the holes might get the values lower than the pages created before them.
+func (t *Table) fillGapFree(
+	ctx context.Context,
+	notionCli *notion.Notion,
+	pool *workpool.Pool,
+	table autocounter.Table,
+	floor int64,
+	filter *notion.DBFilter,
+	fr *FillReport,
+) error {
+	var cursor string
+	holes := table.Watermark.Holes
+	defer func() { fr.Holes = holes }()
+
+	for first := true; ; first = false {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		res, err := notionCli.QueryDatabase(ctx, table.ID, notion.DBQueryReq{
+			StartCursor: cursor,
+			Filter:      filter,
+			Sorts: []notion.DBSort{{
+				Timestamp: notion.DBSortTimestampCreated,
+				Direction: notion.DBSortDirectionAsc,
+			}},
+		})
+		switch {
+		case err == autocounter.ErrIncompatibleTable:
+			return t.Disable(ctx, table.WorkspaceID, table.ID)
+		case err == autocounter.ErrTableNotFound:
+			return t.Disable(ctx, table.WorkspaceID, table.ID)
+		case err != nil:
+			return fmt.Errorf("couldn't fetch next batch of pages from db %s: %s", table.ID, err)
+		}
+		if len(res.Result) == 0 {
+			return nil
+		}
+
+		if first && fr.FullScan && fr.Counter > floor {
+			if holes, err = t.holes(ctx, notionCli, table, fr.Counter); err != nil {
+				return fmt.Errorf("couldn't find the holes: %w", err)
+			}
+		}
+
+		if holes, err = t.patchGapFree(ctx, notionCli, pool, table, floor, holes, res.Result, fr); err != nil {
+			return err
+		}
+
+		if !res.HasMore || res.NextCursor == nil {
+			return nil
+		}
+		cursor = *res.NextCursor
+	}
+}
+
+// patchGapFree numbers the batch of the pages and returns the holes that are left.
+func (t *Table) patchGapFree(
+	ctx context.Context,
+	notionCli *notion.Notion,
+	pool *workpool.Pool,
+	table autocounter.Table,
+	floor int64,
+	holes []int64,
+	pages []notion.Page,
+	fr *FillReport,
+) ([]int64, error) {
+	// the holes are issued first, the rest of the values is reserved.
+	n := len(holes)
+	if n > len(pages) {
+		n = len(pages)
+	}
+	vals := append([]int64(nil), holes[:n]...)
+	holes = holes[n:]
+
+	var reserved []int64
+	if len(pages) > n {
+		var err error
+		reserved, err = t.s.ReserveCounter(ctx, table.WorkspaceID, table.ID, autocounter.Reservation{
+			Count: int64(len(pages) - n),
+			Step:  table.Step,
+			Floor: floor,
+		})
+		if err != nil {
+			return append(vals, holes...), fmt.Errorf("couldn't reserve the counter values: %w", err)
+		}
+		vals = append(vals, reserved...)
+		fr.Counter = reserved[len(reserved)-1]
+	}
+
+	// next is the index of the value the next page gets.
+	// the pages are patched one by one, still by the workers of the workspace token.
+	var next int
+	patch := func(p notion.Page) error {
+		var err error
+		if perr := pool.Do(ctx, func() { err = patchParam(ctx, notionCli, table, p.ID, vals[next]) }); perr != nil {
+			return perr
+		}
+		if err == nil {
+			next++
+			fr.Succeeded++
+			metrics.PagesNumbered.WithLabelValues(table.ID).Inc()
+		}
+		return err
+	}
+
+	fr.Attempted += int64(len(pages))
+	var failed []notion.Page
+	for i, p := range pages {
+		if ctx.Err() != nil {
+			fr.Skipped += int64(len(pages) - i)
+			break
+		}
+
+		if err := patch(p); err != nil {
+			failed = append(failed, p)
+		}
+	}
+
+	// the failed pages are retried once with the values that are left.
+	for _, p := range failed {
+		if ctx.Err() != nil {
+			fr.Skipped++
+			continue
+		}
+
+		err := patch(p)
+		if err == nil {
+			continue
+		}
+
+		pe := PageError{PageID: p.ID, Value: vals[next], Reason: err.Error()}
+		slog.ErrorContext(logging.WithPage(ctx, p.ID), "couldn't set the page identifier", slog.String("reason", pe.Reason), slog.Int64("value", pe.Value))
+		fr.Failed = append(fr.Failed, pe)
+	}
+
+	// the holes that weren't used are still the holes.
+	var unusedReserved []int64
+	if next < n {
+		holes = append(vals[next:n:n], holes...)
+		unusedReserved = reserved
+	} else {
+		unusedReserved = reserved[next-n:]
+	}
+	if len(unusedReserved) == 0 {
+		return holes, nil
+	}
+
+	// the release has to happen even if the fill was cancelled.
+	last, to := unusedReserved[len(unusedReserved)-1], unusedReserved[0]-table.Step
+	err := t.s.ReleaseCounter(context.WithoutCancel(ctx), table.WorkspaceID, table.ID, last, to)
+	switch {
+	case errors.Is(err, autocounter.ErrCounterMoved):
+		// the values were reserved by someone else since, so the unused ones can only be issued as the holes.
+		return append(holes, unusedReserved...), nil
+	case err != nil:
+		return append(holes, unusedReserved...), fmt.Errorf("couldn't release the counter values: %w", err)
+	}
+	fr.Counter = to
+
+	return holes, nil
+}
+
+// patchParam writes the identifier of the provided value into the page.
+func patchParam(ctx context.Context, notionCli *notion.Notion, table autocounter.Table, pageID string, v int64) error {
+	_, err := notionCli.PatchPage(ctx, pageID, notion.PatchPageReq{
+		Properties: map[string]notion.PageProperty{
+			table.ParamName: paramValue(table, v),
+		},
+	})
+	return err
+}
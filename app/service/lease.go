@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"time"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/storage"
+)
+
+// Leases keep the app instances sharing the storage from processing the same workspaces and tables at once.
+const (
+	// defaultLeaseTTL is the time the lease of the crashed instance is taken over after.
+	defaultLeaseTTL = time.Minute
+	// defaultLeasePoll is the interval of the attempts to acquire the lease held by another instance.
+	defaultLeasePoll = time.Second
+)
+
+// newHolder returns the lease holder unique to the service instance.
+func newHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		// the time is unique enough within the host.
+		return host + "-" + time.Now().Format("150405.000000000")
+	}
+
+	return host + "-" + hex.EncodeToString(b)
+}
+
+func workspaceLease(wsID string) string {
+	return "workspace/" + wsID
+}
+
+func tableLease(wsID, tableID string) string {
+	return "table/" + wsID + "/" + tableID
+}
+
+// withLease runs fn while holding the named lease.
+// The lease is prolonged while fn runs, the context of fn is cancelled in case if the lease is lost.
+// Returns autocounter.ErrLeaseHeld without running fn in case if the lease is held by another holder.
+func withLease(ctx context.Context, s storage.Storage, name, holder string, fn func(ctx context.Context) error) error {
+	if _, err := s.AcquireLease(ctx, name, holder, defaultLeaseTTL); err != nil {
+		return err
+	}
+	defer func() {
+		// the lease is released even if the processing was cancelled.
+		if err := s.ReleaseLease(context.WithoutCancel(ctx), name, holder); err != nil {
+			slog.WarnContext(ctx, "couldn't release the lease", slog.String("lease", name), logging.Err(err))
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(defaultLeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			_, err := s.AcquireLease(ctx, name, holder, defaultLeaseTTL)
+			switch {
+			case err == nil:
+			case err == autocounter.ErrLeaseHeld:
+				slog.WarnContext(ctx, "lease was taken over: cancelling", slog.String("lease", name))
+				cancel()
+				return
+			default:
+				// the lease is still held till it expires, so the next attempt might succeed.
+				slog.WarnContext(ctx, "couldn't prolong the lease", slog.String("lease", name), logging.Err(err))
+			}
+		}
+	}()
+
+	return fn(ctx)
+}
+
+// waitLease runs fn while holding the named lease, waiting for it in case if it's held by another holder.
+func waitLease(ctx context.Context, s storage.Storage, name, holder string, fn func(ctx context.Context) error) error {
+	for {
+		err := withLease(ctx, s, name, holder, fn)
+		if err != autocounter.ErrLeaseHeld {
+			return err
+		}
+
+		select {
+		case <-time.After(defaultLeasePoll):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
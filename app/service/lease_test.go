@@ -0,0 +1,210 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/provider/notion/notiontest"
+	"github.com/notionplusid/core/app/service"
+	"github.com/notionplusid/core/app/storage"
+	"github.com/notionplusid/core/app/storage/inmemcache"
+)
+
+// gate holds the requests of the method until it's opened.
+type gate struct {
+	method string
+	rt     http.RoundTripper
+
+	once    sync.Once
+	reached chan struct{}
+	open    chan struct{}
+}
+
+func newGate(method string, rt http.RoundTripper) *gate {
+	return &gate{method: method, rt: rt, reached: make(chan struct{}), open: make(chan struct{})}
+}
+
+func (g *gate) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method == g.method {
+		g.once.Do(func() { close(g.reached) })
+		<-g.open
+	}
+
+	return g.rt.RoundTrip(r)
+}
+
+func TestInstances(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	t.Run("table is filled by a single instance at once", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		patches := newGate(http.MethodPatch, srv.Client().Transport)
+		first, err := service.NewTable(s, notion.NewClients(
+			notion.WithAPIURL(srv.URL()),
+			notion.WithClient(&http.Client{Transport: patches}),
+		))
+		require.NoError(t, err)
+		second, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "DB", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		for i := 0; i < 3; i++ {
+			srv.AddPage("db", nil)
+		}
+		registerTable(t, first, ws, autocounter.Table{ID: "db"})
+
+		done := make(chan service.FillReport)
+		go func() {
+			res, err := first.Fill(ctx, "db", ws)
+			require.NoError(t, err)
+			done <- res
+		}()
+		<-patches.reached
+
+		res := fill(t, second, ws, "db")
+		require.Zero(t, res.Attempted, "the table being filled by another instance is skipped")
+
+		close(patches.open)
+		res = <-done
+		require.Equal(t, int64(3), res.Succeeded)
+		require.Equal(t, []float64{1, 2, 3}, numbers(t, srv.Pages("db"), paramName))
+
+		res = fill(t, second, ws, "db")
+		require.Zero(t, res.Attempted)
+		require.Equal(t, int64(3), res.Counter, "the lease is released once the fill is done")
+	})
+
+	t.Run("on-demand fill waits for another instance", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		patches := newGate(http.MethodPatch, srv.Client().Transport)
+		first, err := service.NewTable(s, notion.NewClients(
+			notion.WithAPIURL(srv.URL()),
+			notion.WithClient(&http.Client{Transport: patches}),
+		))
+		require.NoError(t, err)
+		second, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "DB", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		srv.AddPage("db", nil)
+		registerTable(t, first, ws, autocounter.Table{ID: "db"})
+
+		filled := make(chan struct{})
+		go func() {
+			defer close(filled)
+			_, err := first.Fill(ctx, "db", ws)
+			require.NoError(t, err)
+		}()
+		<-patches.reached
+
+		srv.AddPage("db", nil)
+		done := make(chan service.FillReport)
+		go func() {
+			res, err := second.FillNow(ctx, "db", ws)
+			require.NoError(t, err)
+			done <- res
+		}()
+
+		close(patches.open)
+		<-filled
+		res := <-done
+		require.Equal(t, int64(1), res.Succeeded)
+		require.Equal(t, []float64{1, 2}, numbers(t, srv.Pages("db"), paramName))
+	})
+
+	// newTenants builds the tenants the way the API does: each one on top of its own in-mem cache of the shared storage.
+	newTenants := func(t *testing.T, s storage.Storage, srv *notiontest.Server, n int) []*service.Tenant {
+		var tenants []*service.Tenant
+		for i := 0; i < n; i++ {
+			inmem, err := inmemcache.New(s)
+			require.NoError(t, err)
+			require.NoError(t, inmem.Sync(ctx))
+
+			tenant, err := service.NewTenant(inmem, testExtConfig, newClients(srv))
+			require.NoError(t, err)
+			tenants = append(tenants, tenant)
+		}
+
+		return tenants
+	}
+
+	t.Run("workspace is processed by a single instance at once", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		newWorkspace(t, s, "ws")
+		tenants := newTenants(t, s, srv, 3)
+
+		var processed int32
+		open := make(chan struct{})
+		procWs := func(ctx context.Context, ws autocounter.Workspace) (autocounter.Pass, error) {
+			atomic.AddInt32(&processed, 1)
+			<-open
+			return autocounter.Pass{}, nil
+		}
+
+		reached, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			require.NoError(t, tenants[0].ProcOldestUpdated(ctx, 10, func(ctx context.Context, ws autocounter.Workspace) (autocounter.Pass, error) {
+				close(reached)
+				return procWs(ctx, ws)
+			}))
+		}()
+		<-reached
+
+		for _, tenant := range tenants[1:] {
+			err := tenant.ProcOldestUpdated(ctx, 10, procWs)
+			require.ErrorIs(t, err, autocounter.ErrNoResults, "claimed workspace isn't due")
+		}
+		close(open)
+		<-done
+		require.Equal(t, int32(1), atomic.LoadInt32(&processed))
+	})
+
+	t.Run("processed workspace isn't due for the other instances", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		newWorkspace(t, s, "ws")
+		tenants := newTenants(t, s, srv, 2)
+
+		var processed int32
+		procWs := func(ctx context.Context, ws autocounter.Workspace) (autocounter.Pass, error) {
+			atomic.AddInt32(&processed, 1)
+			return autocounter.Pass{}, nil
+		}
+
+		require.NoError(t, tenants[0].ProcOldestUpdated(ctx, 10, procWs))
+		err := tenants[1].ProcOldestUpdated(ctx, 10, procWs)
+		require.ErrorIs(t, err, autocounter.ErrNoResults, "scheduled workspace isn't due")
+		require.Equal(t, int32(1), atomic.LoadInt32(&processed))
+	})
+
+	t.Run("schedule stored by another instance is respected", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		newWorkspace(t, s, "ws")
+		tenants := newTenants(t, s, srv, 2)
+
+		var processed int32
+		procWs := func(ctx context.Context, ws autocounter.Workspace) (autocounter.Pass, error) {
+			atomic.AddInt32(&processed, 1)
+			return autocounter.Pass{}, nil
+		}
+
+		// the second instance caches the workspace scheduled by its own pass.
+		require.NoError(t, tenants[1].ProcOldestUpdated(ctx, 10, procWs))
+		// while another instance makes it due right away.
+		require.NoError(t, s.StoreWorkspaceSchedule(ctx, "ws", time.Now().Add(-time.Minute), autocounter.Pass{}))
+
+		require.NoError(t, tenants[1].ProcOldestUpdated(ctx, 10, procWs))
+		require.Equal(t, int32(2), atomic.LoadInt32(&processed))
+	})
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/provider/notion"
+)
+
+// emptyParamFilter returns the filter of the pages that have no identifier assigned yet.
+func emptyParamFilter(table autocounter.Table) *notion.DBFilter {
+	f := &notion.DBFilter{Property: table.ParamName}
+	switch table.ParamType {
+	case autocounter.ParamTypeRichText:
+		f.RichText = &notion.DBFilterText{IsEmpty: true}
+	case autocounter.ParamTypeTitle:
+		f.Title = &notion.DBFilterText{IsEmpty: true}
+	default:
+		f.Number = &notion.DBFilterNumber{IsEmpty: true}
+	}
+
+	return f
+}
+
+// editedSinceFilter narrows down the filter to the pages edited on or after `since`.
+func editedSinceFilter(f *notion.DBFilter, since time.Time) *notion.DBFilter {
+	return &notion.DBFilter{And: []notion.DBFilter{
+		*f,
+		{
+			Timestamp:      notion.DBSortTimestampLastEdited,
+			LastEditedTime: &notion.DBFilterDate{OnOrAfter: &since},
+		},
+	}}
+}
+
+// issuedParamFilter returns the filter of the pages that might have an identifier issued with the current table settings.
+func issuedParamFilter(table autocounter.Table) *notion.DBFilter {
+	tf := &notion.DBFilterText{IsNotEmpty: true}
+	if prefix := table.IDPrefix(); prefix != "" {
+		tf = &notion.DBFilterText{StartsWith: prefix}
+	}
+
+	f := &notion.DBFilter{Property: table.ParamName}
+	switch table.ParamType {
+	case autocounter.ParamTypeRichText:
+		f.RichText = tf
+	case autocounter.ParamTypeTitle:
+		f.Title = tf
+	default:
+		f.Number = &notion.DBFilterNumber{IsNotEmpty: true}
+	}
+
+	return f
+}
+
+// paramValue returns the page property that holds the identifier of the provided value.
+func paramValue(table autocounter.Table, v int64) notion.PageProperty {
+	switch table.ParamType {
+	case autocounter.ParamTypeRichText:
+		return notion.PageProperty{
+			Type:     notion.PropertyTypeRichText,
+			RichText: notion.NewRichText(table.FormatID(v)),
+		}
+	case autocounter.ParamTypeTitle:
+		return notion.PageProperty{
+			Type:  notion.PropertyTypeTitle,
+			Title: notion.NewRichText(table.FormatID(v)),
+		}
+	}
+
+	num := float64(v)
+	return notion.PageProperty{
+		Type:   notion.PropertyTypeNumber,
+		Number: &num,
+	}
+}
+
+// pageValue returns the identifier value of the page.
+// Returns false in case if the page has no identifier issued with the current table settings.
+func pageValue(table autocounter.Table, p notion.Page) (int64, bool, error) {
+	prop, ok := p.Properties[table.ParamName]
+	if !ok {
+		return 0, false, fmt.Errorf("%w: missing column: %s", autocounter.ErrInvalidTableParam, table.ParamName)
+	}
+	if string(prop.Type) != table.ParamType {
+		return 0, false, fmt.Errorf("%w: wrong type of column %s: %s", autocounter.ErrInvalidTableParam, table.ParamName, prop.Type)
+	}
+
+	switch table.ParamType {
+	case autocounter.ParamTypeRichText:
+		v, ok := table.ParseID(notion.Text(prop.RichText))
+		return v, ok, nil
+	case autocounter.ParamTypeTitle:
+		v, ok := table.ParseID(notion.Text(prop.Title))
+		return v, ok, nil
+	}
+
+	if prop.Number == nil {
+		return 0, false, nil
+	}
+
+	return int64(*prop.Number), true, nil
+}
+
+// lastValue returns the highest identifier value written into the pages of the table sequence,
+// the sequence of the page dated with `at`.
+// Returns nil in case if there are no identifiers yet.
+func (t *Table) lastValue(ctx context.Context, notionCli *notion.Notion, table autocounter.Table, s sequence, at time.Time) (*int64, error) {
+	if !table.IsText() && table.GroupBy == "" && !table.IsPeriodic() {
+		res, err := notionCli.QueryDatabase(ctx, table.ID, notion.DBQueryReq{
+			Filter: issuedParamFilter(table),
+			Sorts: []notion.DBSort{{
+				Property:  table.ParamName,
+				Direction: notion.DBSortDirectionDesc,
+			}},
+			PageSize: 1,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Result) == 0 {
+			return nil, nil
+		}
+
+		v, ok, err := pageValue(table, res.Result[0])
+		switch {
+		case err != nil:
+			return nil, err
+		case !ok:
+			return nil, errors.New("unexpected empty column value")
+		}
+		return &v, nil
+	}
+
+	// text identifiers can't be sorted numerically and the sequence filters aren't exact,
+	// so every issued identifier has to be checked.
+	gt := table.InGroup(s.label())
+	filter := seqFilter(table, s, at, issuedParamFilter(gt))
+	loc := table.Location()
+	var (
+		last   *int64
+		cursor string
+	)
+	for {
+		res, err := notionCli.QueryDatabase(ctx, table.ID, notion.DBQueryReq{
+			StartCursor: cursor,
+			Filter:      filter,
+			PageSize:    int32(t.batchSize),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range res.Result {
+			ps, pat, err := pageSequence(table, loc, p)
+			if err != nil {
+				return nil, err
+			}
+			if ps != s {
+				continue
+			}
+
+			v, ok, err := pageValue(gt.InPeriod(pat), p)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if last == nil || v > *last {
+				last = &v
+			}
+		}
+
+		if !res.HasMore || res.NextCursor == nil {
+			return last, nil
+		}
+		cursor = *res.NextCursor
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+)
+
+// FillQueue of the targeted Table fills, e.g. requested by the Notion webhooks.
+// The Table queued several times before its fill starts is filled once.
+type FillQueue struct {
+	table *Table
+	items chan fillKey
+
+	mu     sync.Mutex
+	queued map[fillKey]autocounter.Workspace
+}
+
+// NewFillQueue constructor with the provided capacity.
+func NewFillQueue(table *Table, size int) (*FillQueue, error) {
+	switch {
+	case table == nil:
+		return nil, errors.New("table is required")
+	case size < 1:
+		return nil, errors.New("size must be positive")
+	}
+
+	return &FillQueue{
+		table:  table,
+		items:  make(chan fillKey, size),
+		queued: map[fillKey]autocounter.Workspace{},
+	}, nil
+}
+
+// Enqueue the fill of the Table.
+// Returns false in case if the queue is full, the Table is left to the background fills then.
+func (q *FillQueue) Enqueue(ws autocounter.Workspace, tableID string) bool {
+	key := fillKey{workspaceID: ws.ID, tableID: tableID}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.queued[key]; ok {
+		// the workspace might be updated since, e.g. with the new token.
+		q.queued[key] = ws
+		return true
+	}
+
+	select {
+	case q.items <- key:
+		q.queued[key] = ws
+		return true
+	default:
+		return false
+	}
+}
+
+// Len returns the amount of the Tables waiting for the fill.
+func (q *FillQueue) Len() int {
+	return len(q.items)
+}
+
+// Run the fills with the provided amount of workers until the context is done.
+func (q *FillQueue) Run(ctx context.Context, workers int) error {
+	if workers < 1 {
+		return errors.New("workers must be positive")
+	}
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case key := <-q.items:
+					q.fill(ctx, key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (q *FillQueue) fill(ctx context.Context, key fillKey) {
+	// the Table queued from now on is filled once again, as its new pages might be missed by this fill.
+	q.mu.Lock()
+	ws := q.queued[key]
+	delete(q.queued, key)
+	q.mu.Unlock()
+
+	ctx = logging.WithTable(logging.WithWorkspace(ctx, key.workspaceID), key.tableID)
+
+	table, err := q.table.FetchForWs(ctx, key.workspaceID, key.tableID)
+	switch {
+	case err == autocounter.ErrNoResults:
+		slog.DebugContext(ctx, "queued table isn't registered: skipping")
+		return
+	case err != nil:
+		slog.ErrorContext(ctx, "couldn't fetch the queued table", logging.Err(err))
+		return
+	case table.Status != autocounter.StatusActive:
+		slog.DebugContext(ctx, "queued table isn't active: skipping", slog.String("status", table.Status))
+		return
+	}
+
+	if _, err := q.table.FillNow(ctx, key.tableID, ws); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "couldn't fill the queued table", logging.Err(err))
+	}
+}
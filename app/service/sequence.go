@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/provider/notion"
+)
+
+// sequence of the Table pages numbered by their own counter: the pages of the same group dated within the same period.
+// The zero sequence stands for the pages of the Tables that are neither grouped nor reset periodically,
+// as well as for the pages without the group value.
+type sequence struct {
+	// typ of the grouping column.
+	typ notion.PropertyType
+	// group is the value of the grouping column: the option name or the ID of the related page.
+	group string
+	// period the pages are dated within, e.g. "2026".
+	period string
+}
+
+// key of the sequence the counter and the fill report refer to.
+func (s sequence) key() string {
+	return autocounter.Sequence(s.group, s.period)
+}
+
+// label of the group the text identifiers of its pages are prefixed with.
+// The related pages are referenced by their IDs, so the relation groups have no label.
+func (s sequence) label() string {
+	if s.typ == notion.PropertyTypeRelation {
+		return ""
+	}
+
+	return s.group
+}
+
+// pageSequence returns the sequence of the page along with the date of the page in the provided location.
+// The date is returned only for the dated Tables.
+func pageSequence(table autocounter.Table, loc *time.Location, p notion.Page) (sequence, time.Time, error) {
+	s, err := pageGroup(table, p)
+	if err != nil {
+		return sequence{}, time.Time{}, err
+	}
+	if !table.IsDated() {
+		return s, time.Time{}, nil
+	}
+
+	at, err := pageDate(table, loc, p)
+	if err != nil {
+		return sequence{}, time.Time{}, err
+	}
+	s.period = table.PeriodOf(at)
+
+	return s, at, nil
+}
+
+// pageGroup returns the sequence of the page group: the option of the select column,
+// the first option of the multi-select column or the first page of the relation column.
+func pageGroup(table autocounter.Table, p notion.Page) (sequence, error) {
+	if table.GroupBy == "" {
+		return sequence{}, nil
+	}
+
+	prop, ok := p.Properties[table.GroupBy]
+	if !ok {
+		return sequence{}, fmt.Errorf("%w: missing column: %s", autocounter.ErrInvalidTableParam, table.GroupBy)
+	}
+
+	s := sequence{typ: prop.Type}
+	switch prop.Type {
+	case notion.PropertyTypeSelect:
+		if prop.Select != nil {
+			s.group = prop.Select.Name
+		}
+	case notion.PropertyTypeMultiSelect:
+		if len(prop.MultiSelect) != 0 {
+			s.group = prop.MultiSelect[0].Name
+		}
+	case notion.PropertyTypeRelation:
+		if len(prop.Relation) != 0 {
+			s.group = prop.Relation[0].ID
+		}
+	default:
+		return sequence{}, fmt.Errorf("%w: wrong type of group column %s: %s", autocounter.ErrInvalidTableParam, table.GroupBy, prop.Type)
+	}
+	if s.group == "" {
+		return sequence{}, nil
+	}
+
+	return s, nil
+}
+
+// pageDate returns the date of the page in the provided location:
+// the value of the date column or the creation time of the page in case if the column is empty or not set.
+func pageDate(table autocounter.Table, loc *time.Location, p notion.Page) (time.Time, error) {
+	if table.DateParam == "" {
+		return p.CreatedTime.In(loc), nil
+	}
+
+	prop, ok := p.Properties[table.DateParam]
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: missing column: %s", autocounter.ErrInvalidTableParam, table.DateParam)
+	}
+
+	switch prop.Type {
+	case notion.PropertyTypeDate:
+		if prop.Date == nil || prop.Date.Start.IsZero() {
+			return p.CreatedTime.In(loc), nil
+		}
+		d := prop.Date.Start
+		if d.Location() == time.UTC && d.Equal(d.Truncate(24*time.Hour)) {
+			// the dates without time are parsed as the UTC midnight, they stand for the same date in any location.
+			return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc), nil
+		}
+		return d.In(loc), nil
+	case notion.PropertyTypeCreatedTime:
+		if prop.CreatedTime == nil {
+			return p.CreatedTime.In(loc), nil
+		}
+		return prop.CreatedTime.In(loc), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%w: wrong type of date column %s: %s", autocounter.ErrInvalidTableParam, table.DateParam, prop.Type)
+}
+
+// seqPages are the pages of the batch that belong to the sequence along with their dates.
+type seqPages struct {
+	seq   sequence
+	pages []notion.Page
+	dates []time.Time
+}
+
+// splitSequences returns the pages of the batch split by their sequences in the order of their first appearance.
+// The order of the pages is preserved within the sequence.
+func splitSequences(table autocounter.Table, ps []notion.Page) ([]seqPages, error) {
+	var (
+		res []seqPages
+		idx = map[sequence]int{}
+		loc = table.Location()
+	)
+	for _, p := range ps {
+		s, at, err := pageSequence(table, loc, p)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := idx[s]
+		if !ok {
+			i = len(res)
+			idx[s] = i
+			res = append(res, seqPages{seq: s})
+		}
+		res[i].pages = append(res[i].pages, p)
+		res[i].dates = append(res[i].dates, at)
+	}
+
+	return res, nil
+}
+
+// seqFilter narrows down the filter to the pages that might belong to the sequence of the page dated with `at`.
+// The multi-select and relation columns are matched by any of their values and the later periods aren't skipped,
+// so the pages have to be checked with pageSequence as well.
+func seqFilter(table autocounter.Table, s sequence, at time.Time, f *notion.DBFilter) *notion.DBFilter {
+	and := []notion.DBFilter{*f}
+
+	gf := notion.DBFilter{Property: table.GroupBy}
+	switch s.typ {
+	case notion.PropertyTypeSelect:
+		gf.Select = &notion.DBFilterSelect{Equals: &s.group}
+		and = append(and, gf)
+	case notion.PropertyTypeMultiSelect:
+		gf.MultiSelect = &notion.DBFilterMultiSelect{Contains: s.group}
+		and = append(and, gf)
+	case notion.PropertyTypeRelation:
+		gf.Relation = &notion.DBFilterRelation{Contains: s.group}
+		and = append(and, gf)
+	}
+	// the type of the grouping column isn't known for the pages without the group value,
+	// so they are told apart with pageSequence only.
+
+	// the pages dated with the date column are told apart with pageSequence only, as the column might be empty.
+	if table.IsPeriodic() && table.DateParam == "" {
+		// the pages created before the period are skipped.
+		since := table.PeriodStart(at)
+		and = append(and, notion.DBFilter{
+			Timestamp:   notion.DBSortTimestampCreated,
+			CreatedTime: &notion.DBFilterDate{OnOrAfter: &since},
+		})
+	}
+
+	if len(and) == 1 {
+		return f
+	}
+
+	return &notion.DBFilter{And: and}
+}
+
+// validateGroupBy returns no error in case if the grouping column of the Table exists and is of the supported type.
+func validateGroupBy(db notion.Database, table autocounter.Table) error {
+	if table.GroupBy == "" {
+		return nil
+	}
+
+	p, ok := db.Properties[table.GroupBy]
+	if !ok {
+		return fmt.Errorf("%w: missing column: %s", autocounter.ErrInvalidTableParam, table.GroupBy)
+	}
+	switch p.Type {
+	case notion.PropertyTypeSelect, notion.PropertyTypeMultiSelect, notion.PropertyTypeRelation:
+		return nil
+	}
+
+	return fmt.Errorf("%w: wrong type of group column %s: %s", autocounter.ErrInvalidTableParam, table.GroupBy, p.Type)
+}
+
+// validateDateParam returns no error in case if the date column of the Table exists and holds the dates.
+func validateDateParam(db notion.Database, table autocounter.Table) error {
+	if table.DateParam == "" {
+		return nil
+	}
+
+	p, ok := db.Properties[table.DateParam]
+	if !ok {
+		return fmt.Errorf("%w: missing column: %s", autocounter.ErrInvalidTableParam, table.DateParam)
+	}
+	switch p.Type {
+	case notion.PropertyTypeDate, notion.PropertyTypeCreatedTime:
+		return nil
+	}
+
+	return fmt.Errorf("%w: wrong type of date column %s: %s", autocounter.ErrInvalidTableParam, table.DateParam, p.Type)
+}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/internal/metrics"
+	"github.com/notionplusid/core/app/internal/workpool"
 	"github.com/notionplusid/core/app/provider/notion"
 	"github.com/notionplusid/core/app/storage"
 )
@@ -18,28 +23,192 @@ const (
 	defaultProcTO    = 20 * time.Second
 )
 
+// DefaultFullScanInterval is how often the whole Table is queried instead of the pages edited since its watermark.
+const DefaultFullScanInterval = time.Hour
+
+// FillReport of the Table fill.
+type FillReport struct {
+	TableID    string    `json:"tableId"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+
+	// Attempted is the amount of pages the identifiers were issued for.
+	Attempted int64 `json:"attempted"`
+	// Succeeded is the amount of pages that got the identifier assigned.
+	Succeeded int64 `json:"succeeded"`
+	// Failed pages that couldn't get the identifier assigned.
+	Failed []PageError `json:"failed,omitempty"`
+	// Skipped is the amount of pages that weren't patched as the fill was cancelled.
+	Skipped int64 `json:"skipped"`
+	// FullScan is true when the whole Table was queried, otherwise only the pages edited since its watermark were.
+	FullScan bool `json:"fullScan"`
+
+	// Counter is the last value issued for the Table, or for its pages without a group in case if the Table is grouped.
+	Counter int64 `json:"counter"`
+	// Sequences are the last values issued within this fill by the group value and period, e.g. "WEB@2026",
+	// reported only for the grouped and periodically reset Tables.
+	Sequences map[string]int64 `json:"sequences,omitempty"`
+	// Holes are the values below the Counter left unused, reported only for the gap-free Tables.
+	Holes []int64 `json:"holes,omitempty"`
+	// Error of the whole fill.
+	Error string `json:"error,omitempty"`
+}
+
+// PageError describes the page that couldn't get its identifier.
+type PageError struct {
+	PageID string `json:"pageId"`
+	Value  int64  `json:"value"`
+	Reason string `json:"reason"`
+}
+
+// WorkspaceReport combines the FillReports of the Workspace tables.
+type WorkspaceReport struct {
+	WorkspaceID string `json:"workspaceId"`
+
+	// Tables is the amount of tables that were filled.
+	Tables int `json:"tables"`
+	// FailedTables is the amount of tables whose fill returned an error.
+	FailedTables int `json:"failedTables"`
+	// Registered is the amount of tables that were found and activated.
+	Registered int `json:"registered"`
+
+	Attempted int64 `json:"attempted"`
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+	Skipped   int64 `json:"skipped"`
+
+	Fills []FillReport `json:"fills,omitempty"`
+}
+
+// Add the FillReport of the table.
+func (r *WorkspaceReport) Add(fr FillReport) {
+	r.Tables++
+	if fr.Error != "" {
+		r.FailedTables++
+	}
+	r.Attempted += fr.Attempted
+	r.Succeeded += fr.Succeeded
+	r.Failed += int64(len(fr.Failed))
+	r.Skipped += fr.Skipped
+	r.Fills = append(r.Fills, fr)
+}
+
+// Pass returns the outcome of the Workspace pass the report is of.
+// The pass is failed in case if the fill of every table failed.
+func (r WorkspaceReport) Pass() autocounter.Pass {
+	p := autocounter.Pass{Numbered: r.Succeeded, Registered: r.Registered}
+	if r.Tables > 0 && r.FailedTables == r.Tables {
+		p.Error = r.Fills[0].Error
+	}
+
+	return p
+}
+
+// LogValue implements slog.LogValuer with the totals of the report.
+func (r WorkspaceReport) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("tables", r.Tables),
+		slog.Int("failed_tables", r.FailedTables),
+		slog.Int("registered", r.Registered),
+		slog.Int64("attempted", r.Attempted),
+		slog.Int64("succeeded", r.Succeeded),
+		slog.Int64("failed", r.Failed),
+		slog.Int64("skipped", r.Skipped),
+	)
+}
+
 // Table service.
 type Table struct {
 	s         storage.Storage
+	clients   *notion.Clients
 	batchSize int64
+
+	// latest fill results by the workspace and table IDs, kept in memory only.
+	mu        sync.RWMutex
+	lastFills map[fillKey]FillReport
+
+	// fill locks by the workspace and table IDs, so the same Table is never filled concurrently.
+	// The fills of other instances are kept away by the table leases held by the holder.
+	locks  sync.Map
+	holder string
+
+	// tables filled concurrently within the workspace.
+	tableConc int
+	// pages patched concurrently by the workspace token.
+	pageWorkers *workpool.Registry
+
+	// fullScanEvery is the max age of the latest full scan before the Table is queried whole again.
+	fullScanEvery time.Duration
+}
+
+type fillKey struct {
+	workspaceID string
+	tableID     string
 }
 
 // NewTable service constructor.
-func NewTable(s storage.Storage) (*Table, error) {
-	return &Table{
+// The Notion API clients are expected to be shared with the Tenant service,
+// so both of them talk to the workspace through the same client.
+func NewTable(s storage.Storage, clients *notion.Clients) (*Table, error) {
+	if clients == nil {
+		return nil, errors.New("notion clients are required")
+	}
+
+	t := &Table{
 		s:         s,
+		clients:   clients,
 		batchSize: defaultBatchSize,
-	}, nil
+		lastFills: map[fillKey]FillReport{},
+		holder:    newHolder(),
+
+		fullScanEvery: DefaultFullScanInterval,
+	}
+	if err := t.SetConcurrency(DefaultConcurrency); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// SetConcurrency limits of the service.
+// Expected to be called before the service is used.
+func (t *Table) SetConcurrency(c Concurrency) error {
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("invalid concurrency: %w", err)
+	}
+
+	pageWorkers, err := workpool.NewRegistry(c.PageWorkers)
+	if err != nil {
+		return err
+	}
+	t.tableConc = c.Tables
+	t.pageWorkers = pageWorkers
+
+	return nil
+}
+
+// SetFullScanInterval sets how often the whole Table is queried,
+// the fills in between query only the pages edited since the Table watermark.
+// Zero interval makes every fill query the whole Table.
+// Expected to be called before the service is used.
+func (t *Table) SetFullScanInterval(d time.Duration) error {
+	if d < 0 {
+		return errors.New("full scan interval can't be negative")
+	}
+	t.fullScanEvery = d
+
+	return nil
 }
 
 // FetchForWs returns Table that can be found in the provided workspace.
+// Numbering settings that were never configured for the Table are set to their default values.
 func (t *Table) FetchForWs(ctx context.Context, workspaceID, tableID string) (autocounter.Table, error) {
-	return autocounter.Table{
-		ID:          tableID,
-		WorkspaceID: workspaceID,
-		Status:      autocounter.StatusActive,
-		ParamName:   autocounter.DefaultTableParamName,
-	}, nil
+	table, err := t.s.Table(ctx, workspaceID, tableID)
+	if err != nil {
+		return autocounter.Table{}, err
+	}
+
+	return table.WithDefaults(), nil
 }
 
 // IsFillable returns no error in case if the table is eligable for the autoincrement fill.
@@ -53,7 +222,7 @@ func (t *Table) IsFillable(ctx context.Context, tableID string, ws autocounter.W
 		return fmt.Errorf("couldn't fetch table: %w", err)
 	}
 
-	n, err := notion.NewFromWorkspace(ws)
+	n, err := t.clients.ForWorkspace(ctx, ws)
 	if err != nil {
 		return fmt.Errorf("couldn't initialize notion api client: %s", err)
 	}
@@ -63,23 +232,22 @@ func (t *Table) IsFillable(ctx context.Context, tableID string, ws autocounter.W
 		return fmt.Errorf("couldn't fetch table information: %s", err)
 	}
 
-	p, ok := db.Properties[table.ParamName]
-	if !ok {
-		return fmt.Errorf("%w: missing column: %s", autocounter.ErrInvalidTableParam, table.ParamName)
+	if err := validateExpectedDatabaseParam(db, table.ParamName, table.ParamType); err != nil {
+		return err
 	}
-	if p.Type != notion.PropertyTypeNumber {
-		return fmt.Errorf("%w: wrong type of column %s: %s", autocounter.ErrInvalidTableParam, table.ParamName, p.Type)
+	if err := validateGroupBy(db, table); err != nil {
+		return err
 	}
 
-	return validateExpectedDatabaseParam(db, table.ParamName)
+	return validateDateParam(db, table)
 }
 
-func validateExpectedDatabaseParam(db notion.Database, paramName string) error {
+func validateExpectedDatabaseParam(db notion.Database, paramName string, paramType autocounter.ParamType) error {
 	p, ok := db.Properties[paramName]
 	if !ok {
 		return fmt.Errorf("%w: missing column: %s", autocounter.ErrInvalidTableParam, paramName)
 	}
-	if p.Type != notion.PropertyTypeNumber {
+	if string(p.Type) != paramType {
 		return fmt.Errorf("%w: wrong type of column %s: %s", autocounter.ErrInvalidTableParam, paramName, p.Type)
 	}
 	return nil
@@ -91,18 +259,75 @@ func (t *Table) Active(ctx context.Context, workspaceID string, tableIDs []strin
 }
 
 // Register the Table within the Workspace for further scans and autocounter fills.
+// In case if the Table was registered before - its numbering settings are preserved.
+// Paused Tables are kept paused.
 func (t *Table) Register(ctx context.Context, workspaceID string, table autocounter.Table) error {
-	_, err := t.s.StoreTable(ctx, workspaceID, table)
+	_, err := t.register(ctx, workspaceID, table)
 	return err
 }
 
+// register the Table and return true in case if the Table was activated by the registration.
+func (t *Table) register(ctx context.Context, workspaceID string, table autocounter.Table) (bool, error) {
+	var prev autocounter.Status
+
+	existing, err := t.s.Table(ctx, workspaceID, table.ID)
+	switch {
+	case err == autocounter.ErrNoResults:
+	case err != nil:
+		return false, err
+	case existing.Status == autocounter.StatusPaused:
+		return false, nil
+	default:
+		if existing.Status == autocounter.StatusDisabled {
+			// the pages of the disabled Table might have been missed.
+			existing.Watermark = autocounter.Watermark{}
+		}
+		prev = existing.Status
+		existing.Status = table.Status
+		table = existing
+	}
+
+	if _, err = t.s.StoreTable(ctx, workspaceID, table.WithDefaults()); err != nil {
+		return false, err
+	}
+
+	return table.Status == autocounter.StatusActive && prev != autocounter.StatusActive, nil
+}
+
+// Configure the numbering settings of the Table that is already registered within the Workspace.
+// The settings left unset are set to their default values, e.g. the zero start value stands for DefaultTableStartValue.
+func (t *Table) Configure(ctx context.Context, workspaceID string, table autocounter.Table) (autocounter.Table, error) {
+	existing, err := t.s.Table(ctx, workspaceID, table.ID)
+	if err != nil {
+		return autocounter.Table{}, err
+	}
+
+	existing.ParamName = table.ParamName
+	existing.ParamType = table.ParamType
+	existing.Prefix = table.Prefix
+	existing.Separator = table.Separator
+	existing.PadWidth = table.PadWidth
+	existing.StartValue = table.StartValue
+	existing.Step = table.Step
+	existing.GapFree = table.GapFree
+	existing.GroupBy = table.GroupBy
+	existing.Reset = table.Reset
+	existing.DateParam = table.DateParam
+	existing.TimeZone = table.TimeZone
+	existing.Format = table.Format
+	// the pages have to be numbered according to the new settings.
+	existing.Watermark = autocounter.Watermark{}
+
+	return t.s.StoreTable(ctx, workspaceID, existing.WithDefaults())
+}
+
 // Available databases for read.
 func (t *Table) Available(ctx context.Context, ws autocounter.Workspace) ([]autocounter.Table, error) {
 	if err := ws.Validate(); err != nil {
 		return nil, err
 	}
 
-	notionCli, err := notion.NewFromWorkspace(ws)
+	notionCli, err := t.clients.ForWorkspace(ctx, ws)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't initialize notion api client: %s", err)
 	}
@@ -126,22 +351,26 @@ func (t *Table) Available(ctx context.Context, ws autocounter.Workspace) ([]auto
 		return nil, autocounter.ErrNoResults
 	}
 
+	ctx = logging.WithWorkspace(ctx, ws.ID)
+
 	var tables []autocounter.Table
 	for _, item := range res.Result {
 		if item.Database == nil {
-			log.Printf("Table service: Available: Workspace %s: Unexpected item of non-database type", ws.ID)
+			slog.WarnContext(ctx, "unexpected search result item of non-database type")
 			continue
 		}
 
-		if err := hasIDProperty(*item.Database, autocounter.DefaultTableParamName, notion.PropertyTypeNumber); err != nil {
+		pt, err := idPropertyType(*item.Database, autocounter.DefaultTableParamName)
+		if err != nil {
 			continue
 		}
 
 		t, err := autocounter.New(item.Database.ID, ws.ID)
 		if err != nil {
-			log.Printf("Table service: Available: Workspace %s: Couldn't compose a table: %s", ws.ID, err)
+			slog.ErrorContext(logging.WithTable(ctx, item.Database.ID), "couldn't compose a table", logging.Err(err))
 			continue
 		}
+		t.ParamType = pt
 
 		tables = append(tables, t)
 	}
@@ -149,20 +378,21 @@ func (t *Table) Available(ctx context.Context, ws autocounter.Workspace) ([]auto
 	return tables, nil
 }
 
-func hasIDProperty(t notion.Database, prop string, ptype notion.PropertyType) error {
+// idPropertyType returns the type of the provided property in case if it's able to hold the identifiers.
+func idPropertyType(t notion.Database, prop string) (autocounter.ParamType, error) {
 	for n, p := range t.Properties {
 		if n != prop {
 			continue
 		}
 
-		if p.Type != ptype {
+		if err := autocounter.ValidateParamType(string(p.Type)); err != nil {
 			continue
 		}
 
-		return nil
+		return string(p.Type), nil
 	}
 
-	return autocounter.ErrIncompatibleTable
+	return "", autocounter.ErrIncompatibleTable
 }
 
 // ListAllActive tables for the provided Workspace ID.
@@ -176,8 +406,154 @@ func (t *Table) Disable(ctx context.Context, wsID string, tableID string) error
 	return err
 }
 
+// Pause the Table so it's not filled until enabled again.
+// Unlike the disabled Tables, the paused ones are never re-enabled by the workspace scans.
+func (t *Table) Pause(ctx context.Context, wsID string, tableID string) (autocounter.Table, error) {
+	return t.setStatus(ctx, wsID, tableID, autocounter.StatusPaused)
+}
+
+// Enable the disabled or paused Table.
+func (t *Table) Enable(ctx context.Context, wsID string, tableID string) (autocounter.Table, error) {
+	return t.setStatus(ctx, wsID, tableID, autocounter.StatusActive)
+}
+
+func (t *Table) setStatus(ctx context.Context, wsID, tableID string, status autocounter.Status) (autocounter.Table, error) {
+	table, err := t.s.Table(ctx, wsID, tableID)
+	if err != nil {
+		return autocounter.Table{}, err
+	}
+	if table.Status == autocounter.StatusDisabled {
+		// the pages of the disabled Table might have been missed.
+		table.Watermark = autocounter.Watermark{}
+	}
+	table.Status = status
+
+	table, err = t.s.StoreTable(ctx, wsID, table.WithDefaults())
+	if err != nil {
+		return autocounter.Table{}, err
+	}
+
+	return table.WithDefaults(), nil
+}
+
+// ListAll tables of the Workspace regardless of their status.
+func (t *Table) ListAll(ctx context.Context, workspaceID string) ([]autocounter.Table, error) {
+	ts, err := t.s.Tables(ctx)
+	switch {
+	case err == autocounter.ErrNoResults:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	var res []autocounter.Table
+	for _, table := range ts {
+		if table.WorkspaceID == workspaceID {
+			res = append(res, table.WithDefaults())
+		}
+	}
+
+	return res, nil
+}
+
+// LastFill returns the result of the latest fill of the Table made by this instance.
+func (t *Table) LastFill(workspaceID, tableID string) (FillReport, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	res, ok := t.lastFills[fillKey{workspaceID: workspaceID, tableID: tableID}]
+	return res, ok
+}
+
 // Fill the Table within provided Workspace with autoincrementing IDs.
-func (t *Table) Fill(ctx context.Context, tableID string, ws autocounter.Workspace) error {
+// The fill is skipped and the empty report is returned in case if the Table is being filled at the moment,
+// by this or another instance.
+func (t *Table) Fill(ctx context.Context, tableID string, ws autocounter.Workspace) (FillReport, error) {
+	ctx = logging.WithTable(logging.WithWorkspace(ctx, ws.ID), tableID)
+
+	lock := t.lock(ws.ID, tableID)
+	select {
+	case lock <- struct{}{}:
+	default:
+		slog.DebugContext(ctx, "table is being filled already: skipping")
+		return FillReport{TableID: tableID}, nil
+	}
+	defer func() { <-lock }()
+
+	res := FillReport{TableID: tableID}
+	err := withLease(ctx, t.s, tableLease(ws.ID, tableID), t.holder, func(ctx context.Context) error {
+		var err error
+		res, err = t.run(ctx, tableID, ws)
+		return err
+	})
+	if err == autocounter.ErrLeaseHeld {
+		slog.DebugContext(ctx, "table is being filled by another instance: skipping")
+		return FillReport{TableID: tableID}, nil
+	}
+
+	return res, err
+}
+
+// FillNow fills the Table right away and returns the result of the fill.
+// In case if the Table is being filled at the moment, by this or another instance, waits for that fill to finish first.
+func (t *Table) FillNow(ctx context.Context, tableID string, ws autocounter.Workspace) (FillReport, error) {
+	ctx = logging.WithTable(logging.WithWorkspace(ctx, ws.ID), tableID)
+
+	lock := t.lock(ws.ID, tableID)
+	select {
+	case lock <- struct{}{}:
+	case <-ctx.Done():
+		return FillReport{}, ctx.Err()
+	}
+	defer func() { <-lock }()
+
+	res := FillReport{TableID: tableID}
+	err := waitLease(ctx, t.s, tableLease(ws.ID, tableID), t.holder, func(ctx context.Context) error {
+		var err error
+		res, err = t.run(ctx, tableID, ws)
+		return err
+	})
+
+	return res, err
+}
+
+// lock of the Table fill: it's acquired by sending into the channel and released by receiving from it.
+func (t *Table) lock(workspaceID, tableID string) chan struct{} {
+	l, _ := t.locks.LoadOrStore(fillKey{workspaceID: workspaceID, tableID: tableID}, make(chan struct{}, 1))
+	return l.(chan struct{})
+}
+
+func (t *Table) run(ctx context.Context, tableID string, ws autocounter.Workspace) (FillReport, error) {
+	res := FillReport{TableID: tableID, StartedAt: time.Now()}
+	err := t.fill(ctx, tableID, ws, &res)
+	res.FinishedAt = time.Now()
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	duration := res.FinishedAt.Sub(res.StartedAt)
+	metrics.FillDuration.WithLabelValues(metrics.Result(err)).Observe(duration.Seconds())
+	metrics.PagesFailed.WithLabelValues(tableID).Add(float64(len(res.Failed)))
+	metrics.PagesSkipped.WithLabelValues(tableID).Add(float64(res.Skipped))
+	slog.DebugContext(ctx, "table fill finished",
+		slog.Duration("duration", duration),
+		slog.Int64("attempted", res.Attempted),
+		slog.Int64("succeeded", res.Succeeded),
+		slog.Int("failed", len(res.Failed)),
+		slog.Int64("skipped", res.Skipped),
+		slog.Bool("full_scan", res.FullScan),
+		logging.Err(err),
+	)
+
+	t.mu.Lock()
+	t.lastFills[fillKey{workspaceID: ws.ID, tableID: tableID}] = res
+	t.mu.Unlock()
+
+	return res, err
+}
+
+// fill the Table, the outcome is collected into the provided FillReport.
+func (t *Table) fill(ctx context.Context, tableID string, ws autocounter.Workspace, fr *FillReport) (err error) {
 	if tableID == "" {
 		return errors.New("table id is required")
 	}
@@ -187,56 +563,66 @@ func (t *Table) Fill(ctx context.Context, tableID string, ws autocounter.Workspa
 		return fmt.Errorf("couldn't fetch table: %w", err)
 	}
 
-	notionCli, err := notion.NewFromWorkspace(ws)
+	notionCli, err := t.clients.ForWorkspace(ctx, ws)
 	if err != nil {
 		return fmt.Errorf("couldn't initialize notion api client: %s", err)
 	}
-	defer notionCli.Close()
 
-	// fetch latest page number.
-	res, err := notionCli.QueryDatabase(ctx, tableID, notion.DBQueryReq{
-		Filter: &notion.DBFilter{
-			Property: table.ParamName,
-			Number: &notion.DBFilterNumber{
-				IsNotEmpty: true,
-			},
-		},
-		Sorts: []notion.DBSort{{
-			Property:  table.ParamName,
-			Direction: notion.DBSortDirectionDesc,
-		}},
-		PageSize: 1,
-	})
-	switch {
-	case err == autocounter.ErrIncompatibleTable:
-		return t.Disable(ctx, ws.ID, tableID)
-	case err == autocounter.ErrTableNotFound:
-		return t.Disable(ctx, ws.ID, tableID)
-	case err != nil:
-		return err
+	// the counter of the Table is seeded right away, the counters of the sequences once their pages are found.
+	floors := map[sequence]int64{}
+	if table.GroupBy == "" && !table.IsPeriodic() {
+		floor, last, err := t.floor(ctx, notionCli, ws.ID, table, sequence{}, time.Time{})
+		switch {
+		case err == autocounter.ErrIncompatibleTable:
+			return t.Disable(ctx, ws.ID, tableID)
+		case err == autocounter.ErrTableNotFound:
+			return t.Disable(ctx, ws.ID, tableID)
+		case err != nil:
+			return err
+		}
+		floors[sequence{}] = floor
+		fr.Counter = last
 	}
 
-	// default value of the counter is 0.
-	var counter int64
-	if len(res.Result) != 0 {
-		p, ok := res.Result[0].Properties[table.ParamName]
-		if !ok {
-			return fmt.Errorf("%w: missing column: %s", autocounter.ErrInvalidTableParam, table.ParamName)
-		}
-		if p.Type != notion.PropertyTypeNumber {
-			return fmt.Errorf("%w: wrong type of column %s: %s", autocounter.ErrInvalidTableParam, table.ParamName, p.Type)
+	// the pages are patched by the workers shared by all the tables of the workspace token.
+	pool := t.pageWorkers.Get(ws.Token)
+
+	// only the pages edited since the watermark are queried, unless the whole Table is due to be scanned.
+	filter := emptyParamFilter(table)
+	since, incremental := table.Watermark.Since(fr.StartedAt, t.fullScanEvery)
+	if incremental {
+		filter = editedSinceFilter(filter, since)
+	}
+	fr.FullScan = !incremental
+
+	// the watermark advances only when every queried page got its identifier, the holes are kept anyway.
+	// the context is passed along as the one of the fill is cancelled by the time the function returns.
+	defer func(ctx context.Context) {
+		w := table.Watermark
+		switch {
+		case err == nil && len(fr.Failed) == 0 && fr.Skipped == 0:
+			w = w.Advance(fr.StartedAt, fr.FullScan)
+		case slices.Equal(w.Holes, fr.Holes):
+			return
 		}
-		if p.Number == nil {
-			return errors.New("unexpected empty column value")
+		w.Holes = fr.Holes
+		if err := t.s.StoreTableWatermark(ctx, ws.ID, tableID, w); err != nil {
+			slog.WarnContext(ctx, "couldn't store the table watermark", logging.Err(err))
 		}
-		counter = int64(*p.Number)
+	}(ctx)
+
+	if table.GapFree {
+		return t.fillGapFree(ctx, notionCli, pool, table, floors[sequence{}], filter, fr)
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	// for loop until context is cancelled.
+	// the patches that are in progress always finish before the result is returned.
 	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+	mu := &sync.Mutex{}
 	var cursor string
 	for {
 		select {
@@ -248,48 +634,93 @@ func (t *Table) Fill(ctx context.Context, tableID string, ws autocounter.Workspa
 		// fetch batch of the pages IDs with empty number ordered by asc created at.
 		res, err := notionCli.QueryDatabase(ctx, tableID, notion.DBQueryReq{
 			StartCursor: cursor,
-			Filter: &notion.DBFilter{
-				Property: table.ParamName,
-				Number: &notion.DBFilterNumber{
-					IsEmpty: true,
-				},
-			},
+			Filter:      filter,
 			Sorts: []notion.DBSort{{
 				Timestamp: notion.DBSortTimestampCreated,
 				Direction: notion.DBSortDirectionAsc,
 			}},
 		})
-		if err != nil {
+		switch {
+		case err == autocounter.ErrIncompatibleTable:
+			return t.Disable(ctx, ws.ID, tableID)
+		case err == autocounter.ErrTableNotFound:
+			return t.Disable(ctx, ws.ID, tableID)
+		case err != nil:
 			return fmt.Errorf("couldn't fetch next batch of pages from db %s: %s", tableID, err)
 		}
 
-		// split into chunks
-		for _, p := range res.Result {
-			wg.Add(1)
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
+		// the pages of every sequence are numbered by the counter of the sequence.
+		seqs, err := splitSequences(table, res.Result)
+		if err != nil {
+			return err
+		}
+
+		// the next batch is fetched only once the workers took all the pages of this one.
+		var reserved, queued int64
+		for _, sp := range seqs {
+			floor, ok := floors[sp.seq]
+			if !ok {
+				floor, _, err = t.floor(ctx, notionCli, ws.ID, table, sp.seq, sp.dates[0])
+				if err != nil {
+					return fmt.Errorf("couldn't seed the counter of sequence %s: %w", sp.seq.key(), err)
+				}
+				floors[sp.seq] = floor
 			}
 
-			counter++
-			go func(num float64, pageID string, done func()) {
-				defer done()
-				_, err := notionCli.PatchPage(ctx, pageID, notion.PatchPageReq{
-					Properties: map[string]notion.PageProperty{
-						table.ParamName: {
-							Type:   "number",
-							Number: &num,
-						},
-					},
+			// reserve the values for the whole sequence at once so the concurrent fills never issue the same value.
+			vals, err := t.s.ReserveCounter(ctx, ws.ID, autocounter.CounterID(tableID, sp.seq.key()), autocounter.Reservation{
+				Count: int64(len(sp.pages)),
+				Step:  table.Step,
+				Floor: floor,
+			})
+			if err != nil {
+				return fmt.Errorf("couldn't reserve the counter values: %w", err)
+			}
+			if key := sp.seq.key(); key == "" {
+				fr.Counter = vals[len(vals)-1]
+			} else {
+				if fr.Sequences == nil {
+					fr.Sequences = map[string]int64{}
+				}
+				fr.Sequences[key] = vals[len(vals)-1]
+			}
+			fr.Attempted += int64(len(vals))
+			reserved += int64(len(vals))
+
+			gt := table.InGroup(sp.seq.label())
+			for i, p := range sp.pages {
+				num, pageID, pt := vals[i], p.ID, gt.InPeriod(sp.dates[i])
+
+				wg.Add(1)
+				err := pool.Go(ctx, func() {
+					defer wg.Done()
+					ctx := logging.WithPage(ctx, pageID)
+					err := patchParam(ctx, notionCli, pt, pageID, num)
+
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						fr.Succeeded++
+						metrics.PagesNumbered.WithLabelValues(tableID).Inc()
+					case errors.Is(err, context.DeadlineExceeded):
+						fr.Skipped++
+					case errors.Is(err, context.Canceled):
+						fr.Skipped++
+					default:
+						slog.ErrorContext(ctx, "couldn't set the page identifier", logging.Err(err), slog.Int64("value", num))
+						fr.Failed = append(fr.Failed, PageError{PageID: pageID, Value: num, Reason: err.Error()})
+					}
 				})
-				switch {
-				case errors.Is(err, context.DeadlineExceeded):
-				case errors.Is(err, context.Canceled):
-				case err != nil:
-					log.Printf("error: %s", err)
+				if err != nil {
+					wg.Done()
+					mu.Lock()
+					fr.Skipped += reserved - queued
+					mu.Unlock()
+					return err
 				}
-			}(float64(counter), p.ID, wg.Done)
+				queued++
+			}
 		}
 		if !res.HasMore {
 			wg.Wait()
@@ -300,6 +731,31 @@ func (t *Table) Fill(ctx context.Context, tableID string, ws autocounter.Workspa
 	}
 }
 
+// floor returns the floor of the reservations from the counter of the Table sequence along with its last issued value,
+// the sequence of the page dated with `at`.
+// The counter that was never reserved from is seeded from the identifiers written into the pages of the sequence before.
+func (t *Table) floor(ctx context.Context, notionCli *notion.Notion, wsID string, table autocounter.Table, s sequence, at time.Time) (int64, int64, error) {
+	// the counter starts right before the start value of the table.
+	floor := table.StartValue - table.Step
+
+	counter, err := t.s.Counter(ctx, wsID, autocounter.CounterID(table.ID, s.key()))
+	switch {
+	case err == autocounter.ErrNoResults:
+		last, err := t.lastValue(ctx, notionCli, table, s, at)
+		if err != nil {
+			return 0, 0, err
+		}
+		if last != nil && *last > floor {
+			floor = *last
+		}
+		return floor, 0, nil
+	case err != nil:
+		return 0, 0, fmt.Errorf("couldn't fetch the counter: %w", err)
+	}
+
+	return floor, counter.Value, nil
+}
+
 // NonActiveDiff returns a list of tables that aren't registered or not active for the autofill.
 // The new tables normally appear if customer decides to observe a new table,
 // or at the first time the workspace is registered.
@@ -345,35 +801,68 @@ func (t *Table) NonActiveDiff(ctx context.Context, ws autocounter.Workspace) ([]
 }
 
 // RegisterConc registers the provided table in a concurrent manner.
-func (t *Table) RegisterConc(ctx context.Context, ts []autocounter.Table) {
+// Returns the amount of tables that were activated.
+func (t *Table) RegisterConc(ctx context.Context, ts []autocounter.Table) int {
+	var activated int32
 	wg := &sync.WaitGroup{}
 	for _, at := range ts {
 		wg.Add(1)
 
 		go func(at autocounter.Table) {
 			defer wg.Done()
-			if err := t.Register(ctx, at.WorkspaceID, at); err != nil {
-				log.Printf("ScanWorker: Workspace %s: Couldn't register a table %s: %s", at.WorkspaceID, at.ID, err)
+			ok, err := t.register(ctx, at.WorkspaceID, at)
+			if err != nil {
+				ctx := logging.WithTable(logging.WithWorkspace(ctx, at.WorkspaceID), at.ID)
+				slog.ErrorContext(ctx, "couldn't register a table", logging.Err(err))
+			}
+			if ok {
+				atomic.AddInt32(&activated, 1)
 			}
 		}(at)
 	}
 	wg.Wait()
+
+	return int(activated)
 }
 
-// ProcWs in concurrent manner.
-func (t *Table) ProcWs(ctx context.Context, ws autocounter.Workspace) (autocounter.Workspace, error) {
+// ProcWs in concurrent manner and return the outcome of the pass.
+// The combined report of the table fills is logged.
+func (t *Table) ProcWs(ctx context.Context, ws autocounter.Workspace) (autocounter.Pass, error) {
+	ctx = logging.WithWorkspace(ctx, ws.ID)
+
+	report, err := t.FillWs(ctx, ws)
+	if err != nil {
+		return autocounter.Pass{}, err
+	}
+
+	level := slog.LevelDebug
+	if report.Failed != 0 || report.FailedTables != 0 {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "workspace processed", slog.Any("report", report))
+
+	return report.Pass(), nil
+}
+
+// FillWs registers the new tables of the Workspace and fills all the active ones concurrently.
+// Returns the combined report of the table fills.
+func (t *Table) FillWs(ctx context.Context, ws autocounter.Workspace) (WorkspaceReport, error) {
+	ctx = logging.WithWorkspace(ctx, ws.ID)
 	ctx, cancel := context.WithTimeout(ctx, defaultProcTO)
 	defer cancel()
 
+	report := WorkspaceReport{WorkspaceID: ws.ID}
+
 	ts, err := t.ListAllActive(ctx, ws.ID)
 	switch {
 	case err == autocounter.ErrNoResults:
 	case err != nil:
-		return autocounter.Workspace{}, fmt.Errorf("workspace %s: couldn't process tables: %w", ws.ID, err)
+		return report, fmt.Errorf("workspace %s: couldn't process tables: %w", ws.ID, err)
 	}
 
 	// parallelize the table fill.
 	wg := &sync.WaitGroup{}
+	mu := &sync.Mutex{}
 
 	wg.Add(1)
 	go func() {
@@ -383,22 +872,43 @@ func (t *Table) ProcWs(ctx context.Context, ws autocounter.Workspace) (autocount
 		case err == autocounter.ErrNoResults:
 			return
 		case err != nil:
-			log.Printf("Table service: workspace %s: couldn't fetch unregistered tables: %s", ws.ID, err)
+			slog.ErrorContext(ctx, "couldn't fetch unregistered tables", logging.Err(err))
 			return
 		}
-		t.RegisterConc(ctx, ts)
+		registered := t.RegisterConc(ctx, ts)
+
+		mu.Lock()
+		report.Registered = registered
+		mu.Unlock()
 	}()
 
+	// the pool is sized by the service concurrency, an error is possible only for the misconfigured one.
+	pool, err := workpool.New(t.tableConc)
+	if err != nil {
+		return report, err
+	}
+
 	for _, tt := range ts {
+		tID := tt.ID
 		wg.Add(1)
-		go func(tID string) {
+		err := pool.Go(ctx, func() {
 			defer wg.Done()
-			if err := t.Fill(ctx, tID, ws); err != nil {
-				log.Printf("RunWorker: workspace %s: couldn't fill the table %s: %s", ws.ID, tID, err)
+			fr, err := t.Fill(ctx, tID, ws)
+			if err != nil {
+				slog.ErrorContext(logging.WithTable(ctx, tID), "couldn't fill the table", logging.Err(err))
 			}
-		}(tt.ID)
+
+			mu.Lock()
+			report.Add(fr)
+			mu.Unlock()
+		})
+		if err != nil {
+			// the tables that are left aren't filled within this pass.
+			wg.Done()
+			break
+		}
 	}
 	wg.Wait()
 
-	return ws, nil
+	return report, nil
 }
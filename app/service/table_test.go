@@ -0,0 +1,775 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/provider/notion/notiontest"
+	"github.com/notionplusid/core/app/service"
+	"github.com/notionplusid/core/app/storage"
+	"github.com/notionplusid/core/app/storage/bolt"
+)
+
+const testToken = "secret_token"
+
+func newStorage(t *testing.T) storage.Storage {
+	s, err := bolt.New(filepath.Join(t.TempDir(), "plusid.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func newNotion(t *testing.T) *notiontest.Server {
+	srv := notiontest.NewServer()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newClients(srv *notiontest.Server) *notion.Clients {
+	return notion.NewClients(
+		notion.WithAPIURL(srv.URL()),
+		notion.WithClient(srv.Client()),
+	)
+}
+
+func newWorkspace(t *testing.T, s storage.Storage, id string) autocounter.Workspace {
+	ws, err := autocounter.NewWorkspace(id, testToken)
+	require.NoError(t, err)
+	ws, err = s.StoreWorkspace(context.Background(), ws)
+	require.NoError(t, err)
+
+	return ws
+}
+
+func registerTable(t *testing.T, svc *service.Table, ws autocounter.Workspace, table autocounter.Table) {
+	ctx := context.Background()
+
+	at, err := autocounter.New(table.ID, ws.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.Register(ctx, ws.ID, at))
+	_, err = svc.Configure(ctx, ws.ID, table)
+	require.NoError(t, err)
+}
+
+func fill(t *testing.T, svc *service.Table, ws autocounter.Workspace, tableID string) service.FillReport {
+	res, err := svc.Fill(context.Background(), tableID, ws)
+	require.NoError(t, err)
+
+	return res
+}
+
+func numbers(t *testing.T, pages []notion.Page, prop string) []float64 {
+	var res []float64
+	for _, p := range pages {
+		n := p.Properties[prop].Number
+		require.NotNil(t, n, "page %s has no number", p.ID)
+		res = append(res, *n)
+	}
+
+	return res
+}
+
+func texts(pages []notion.Page, prop string) []string {
+	var res []string
+	for _, p := range pages {
+		res = append(res, notion.Text(p.Properties[prop].RichText))
+	}
+
+	return res
+}
+
+func TestTableFill(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	t.Run("numbers pages in the order of creation", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{
+			paramName: notion.PropertyTypeNumber,
+			"Name":    notion.PropertyTypeTitle,
+		})
+		for i := 0; i < 5; i++ {
+			srv.AddPage("db", nil)
+		}
+		registerTable(t, svc, ws, autocounter.Table{ID: "db", StartValue: 10, Step: 5})
+
+		fill(t, svc, ws, "db")
+		require.Equal(t, []float64{10, 15, 20, 25, 30}, numbers(t, srv.Pages("db"), paramName))
+
+		srv.AddPage("db", nil)
+		fill(t, svc, ws, "db")
+		require.Equal(t, []float64{10, 15, 20, 25, 30, 35}, numbers(t, srv.Pages("db"), paramName))
+	})
+
+	t.Run("continues after the identifiers written before", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		srv.AddPage("db", map[string]notion.PageProperty{paramName: notiontest.Number(41)})
+		srv.AddPage("db", map[string]notion.PageProperty{paramName: notiontest.Number(7)})
+		srv.AddPage("db", nil)
+		srv.AddPage("db", nil)
+		registerTable(t, svc, ws, autocounter.Table{ID: "db"})
+
+		fill(t, svc, ws, "db")
+		require.Equal(t, []float64{41, 7, 42, 43}, numbers(t, srv.Pages("db"), paramName))
+	})
+
+	t.Run("formats text identifiers", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Invoices", map[string]notion.PropertyType{paramName: notion.PropertyTypeRichText})
+		srv.AddPage("db", map[string]notion.PageProperty{paramName: notiontest.RichText("INV-009")})
+		srv.AddPage("db", map[string]notion.PageProperty{paramName: notiontest.RichText("manual")})
+		srv.AddPage("db", nil)
+		srv.AddPage("db", nil)
+		registerTable(t, svc, ws, autocounter.Table{
+			ID:        "db",
+			ParamType: autocounter.ParamTypeRichText,
+			Prefix:    "INV",
+			Separator: "-",
+			PadWidth:  3,
+		})
+
+		fill(t, svc, ws, "db")
+		require.Equal(t, []string{"INV-009", "manual", "INV-010", "INV-011"}, texts(srv.Pages("db"), paramName))
+	})
+
+	t.Run("never reissues the identifier of the removed page", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		srv.AddPage("db", nil)
+		last := srv.AddPage("db", nil)
+		registerTable(t, svc, ws, autocounter.Table{ID: "db"})
+
+		fill(t, svc, ws, "db")
+		srv.ArchivePage(last.ID)
+		p := srv.AddPage("db", nil)
+		fill(t, svc, ws, "db")
+
+		p, ok := srv.Page(p.ID)
+		require.True(t, ok)
+		require.Equal(t, float64(3), *p.Properties[paramName].Number)
+	})
+
+	t.Run("disables the table that is gone", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		registerTable(t, svc, ws, autocounter.Table{ID: "db"})
+		srv.RemoveDatabase("db")
+
+		fill(t, svc, ws, "db")
+
+		table, err := svc.FetchForWs(ctx, ws.ID, "db")
+		require.NoError(t, err)
+		require.Equal(t, autocounter.StatusDisabled, table.Status)
+	})
+
+	t.Run("disables the table with the incompatible column", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeSelect})
+		srv.AddPage("db", nil)
+		registerTable(t, svc, ws, autocounter.Table{ID: "db"})
+
+		fill(t, svc, ws, "db")
+
+		table, err := svc.FetchForWs(ctx, ws.ID, "db")
+		require.NoError(t, err)
+		require.Equal(t, autocounter.StatusDisabled, table.Status)
+	})
+}
+
+func TestTablePause(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage(t)
+	svc, err := service.NewTable(s, notion.NewClients())
+	require.NoError(t, err)
+	ws := newWorkspace(t, s, "ws")
+	registerTable(t, svc, ws, autocounter.Table{ID: "db", StartValue: 10})
+
+	table, err := svc.Pause(ctx, ws.ID, "db")
+	require.NoError(t, err)
+	require.Equal(t, autocounter.StatusPaused, table.Status)
+
+	// the workspace scan doesn't re-enable the paused table.
+	at, err := autocounter.New("db", ws.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.Register(ctx, ws.ID, at))
+	table, err = svc.FetchForWs(ctx, ws.ID, "db")
+	require.NoError(t, err)
+	require.Equal(t, autocounter.StatusPaused, table.Status)
+
+	table, err = svc.Enable(ctx, ws.ID, "db")
+	require.NoError(t, err)
+	require.Equal(t, autocounter.StatusActive, table.Status)
+	require.Equal(t, int64(10), table.StartValue)
+
+	_, err = svc.Enable(ctx, ws.ID, "unknown")
+	require.Equal(t, autocounter.ErrNoResults, err)
+}
+
+func TestTableRegister(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage(t)
+	svc, err := service.NewTable(s, notion.NewClients())
+	require.NoError(t, err)
+	ws := newWorkspace(t, s, "ws")
+
+	at, err := autocounter.New("db", ws.ID)
+	require.NoError(t, err)
+	require.NoError(t, svc.Register(ctx, ws.ID, at))
+
+	table, err := svc.FetchForWs(ctx, ws.ID, "db")
+	require.NoError(t, err)
+	require.Equal(t, autocounter.StatusActive, table.Status)
+	require.Equal(t, autocounter.DefaultTableStartValue, table.StartValue)
+	require.Equal(t, autocounter.DefaultTableStep, table.Step)
+
+	_, err = svc.FetchForWs(ctx, "another", "db")
+	require.Equal(t, autocounter.ErrNoResults, err, "table of another workspace isn't fetched")
+
+	// the registration of the known table preserves its settings.
+	_, err = svc.Configure(ctx, ws.ID, autocounter.Table{ID: "db", StartValue: 10, Step: 2})
+	require.NoError(t, err)
+	require.NoError(t, svc.Register(ctx, ws.ID, at))
+	table, err = svc.FetchForWs(ctx, ws.ID, "db")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), table.StartValue)
+	require.Equal(t, int64(2), table.Step)
+
+	// the registration re-enables the disabled table.
+	require.NoError(t, svc.Disable(ctx, ws.ID, "db"))
+	require.NoError(t, svc.Register(ctx, ws.ID, at))
+	table, err = svc.FetchForWs(ctx, ws.ID, "db")
+	require.NoError(t, err)
+	require.Equal(t, autocounter.StatusActive, table.Status)
+	require.Equal(t, int64(10), table.StartValue)
+}
+
+func TestTableConfigure(t *testing.T) {
+	ctx := context.Background()
+	s := newStorage(t)
+	svc, err := service.NewTable(s, notion.NewClients())
+	require.NoError(t, err)
+	ws := newWorkspace(t, s, "ws")
+	registerTable(t, svc, ws, autocounter.Table{ID: "db", StartValue: 10})
+
+	_, err = svc.Configure(ctx, ws.ID, autocounter.Table{ID: "unknown"})
+	require.Equal(t, autocounter.ErrNoResults, err)
+
+	table, err := svc.Configure(ctx, ws.ID, autocounter.Table{
+		ID:        "db",
+		ParamName: "Key",
+		ParamType: autocounter.ParamTypeRichText,
+		Prefix:    "ENG",
+		Separator: "-",
+		Step:      5,
+	})
+	require.NoError(t, err)
+	require.Equal(t, autocounter.StatusActive, table.Status, "status isn't configured")
+	require.Equal(t, "Key", table.ParamName)
+	require.Equal(t, "ENG", table.Prefix)
+	require.Equal(t, int64(5), table.Step)
+	require.Equal(t, autocounter.DefaultTableStartValue, table.StartValue, "unset start value is set to the default")
+
+	stored, err := svc.FetchForWs(ctx, ws.ID, "db")
+	require.NoError(t, err)
+	require.Equal(t, table.StartValue, stored.StartValue)
+	require.Equal(t, "ENG", stored.Prefix)
+
+	_, err = svc.Configure(ctx, ws.ID, autocounter.Table{ID: "db", StartValue: -1})
+	require.Error(t, err)
+	_, err = svc.Configure(ctx, ws.ID, autocounter.Table{ID: "db", Prefix: "ENG"})
+	require.Error(t, err, "number columns have no prefix")
+}
+
+func TestTableFillIncremental(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	s, srv := newStorage(t), newNotion(t)
+	svc, err := service.NewTable(s, newClients(srv))
+	require.NoError(t, err)
+	ws := newWorkspace(t, s, "ws")
+
+	srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+	srv.AddPage("db", nil)
+	srv.AddPage("db", nil)
+	registerTable(t, svc, ws, autocounter.Table{ID: "db"})
+
+	res := fill(t, svc, ws, "db")
+	require.True(t, res.FullScan, "the table that was never filled is queried whole")
+	require.Equal(t, int64(2), res.Succeeded)
+
+	table, err := svc.FetchForWs(ctx, ws.ID, "db")
+	require.NoError(t, err)
+	require.Equal(t, res.StartedAt.Unix(), table.Watermark.LastSeenAt.Unix())
+	require.Equal(t, res.StartedAt.Unix(), table.Watermark.FullScanAt.Unix())
+
+	// the page last edited long before the watermark is left for the next full scan.
+	old := srv.AddPageAt("db", time.Now().Add(-time.Hour), nil)
+	srv.AddPage("db", nil)
+
+	res = fill(t, svc, ws, "db")
+	require.False(t, res.FullScan)
+	require.Equal(t, int64(1), res.Succeeded)
+	p, ok := srv.Page(old.ID)
+	require.True(t, ok)
+	require.Nil(t, p.Properties[paramName].Number)
+
+	require.NoError(t, svc.SetFullScanInterval(0))
+	res = fill(t, svc, ws, "db")
+	require.True(t, res.FullScan)
+	require.Equal(t, int64(1), res.Succeeded)
+	p, _ = srv.Page(old.ID)
+	require.NotNil(t, p.Properties[paramName].Number)
+	require.Equal(t, float64(4), *p.Properties[paramName].Number)
+
+	// the new settings reset the watermark, so the whole table is queried again.
+	require.NoError(t, svc.SetFullScanInterval(service.DefaultFullScanInterval))
+	_, err = svc.Configure(ctx, ws.ID, autocounter.Table{ID: "db", ParamName: paramName, ParamType: autocounter.ParamTypeNumber})
+	require.NoError(t, err)
+	res = fill(t, svc, ws, "db")
+	require.True(t, res.FullScan)
+}
+
+func TestTableFillGrouped(t *testing.T) {
+	t.Run("numbers every select option independently", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{
+			"ID":      notion.PropertyTypeRichText,
+			"Project": notion.PropertyTypeSelect,
+		})
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Select("WEB")})
+		// the counter of the group is seeded from the identifiers written before.
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Select("API"), "ID": notiontest.RichText("API-7")})
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Select("API")})
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Select("WEB")})
+		srv.AddPage("db", nil)
+		registerTable(t, svc, ws, autocounter.Table{
+			ID:        "db",
+			ParamName: "ID",
+			ParamType: autocounter.ParamTypeRichText,
+			Separator: "-",
+			GroupBy:   "Project",
+		})
+
+		res := fill(t, svc, ws, "db")
+		require.Equal(t, int64(4), res.Succeeded)
+		require.Equal(t, []string{"WEB-1", "API-7", "API-8", "WEB-2", "1"}, texts(srv.Pages("db"), "ID"))
+		require.Equal(t, map[string]int64{"WEB": 2, "API": 8}, res.Sequences)
+		require.Equal(t, int64(1), res.Counter, "the pages without a group share the counter of the table")
+
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Select("WEB")})
+		fill(t, svc, ws, "db")
+		require.Equal(t, "WEB-3", texts(srv.Pages("db"), "ID")[5])
+	})
+
+	t.Run("numbers the groups of the first related page", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		const paramName = autocounter.DefaultTableParamName
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{
+			paramName: notion.PropertyTypeNumber,
+			"Project": notion.PropertyTypeRelation,
+		})
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Relation("a")})
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Relation("b")})
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Relation("b", "a")})
+		srv.AddPage("db", map[string]notion.PageProperty{"Project": notiontest.Relation("a")})
+		registerTable(t, svc, ws, autocounter.Table{ID: "db", GroupBy: "Project"})
+
+		fill(t, svc, ws, "db")
+		require.Equal(t, []float64{1, 1, 2, 2}, numbers(t, srv.Pages("db"), paramName))
+	})
+
+	t.Run("rejects the group column of unsupported type", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{
+			autocounter.DefaultTableParamName: notion.PropertyTypeNumber,
+			"Project":                         notion.PropertyTypeRichText,
+		})
+		registerTable(t, svc, ws, autocounter.Table{ID: "db", GroupBy: "Project"})
+
+		err = svc.IsFillable(context.Background(), "db", ws)
+		require.ErrorIs(t, err, autocounter.ErrInvalidTableParam)
+	})
+}
+
+func TestTableFillPeriodic(t *testing.T) {
+	t.Run("numbers the pages within the year of their creation", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Invoices", map[string]notion.PropertyType{"No": notion.PropertyTypeRichText})
+		srv.AddPageAt("db", time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC), map[string]notion.PageProperty{
+			"No": notiontest.RichText("2025-0041"),
+		})
+		// the page created right before the new year is numbered within the previous one, whenever it's filled.
+		srv.AddPageAt("db", time.Date(2025, time.December, 31, 23, 59, 30, 0, time.UTC), nil)
+		srv.AddPageAt("db", time.Date(2026, time.January, 1, 0, 0, 30, 0, time.UTC), nil)
+		srv.AddPageAt("db", time.Date(2026, time.January, 2, 0, 0, 0, 0, time.UTC), nil)
+		registerTable(t, svc, ws, autocounter.Table{
+			ID:        "db",
+			ParamName: "No",
+			ParamType: autocounter.ParamTypeRichText,
+			PadWidth:  4,
+			Reset:     autocounter.PeriodYearly,
+			Format:    "{yyyy}-{n}",
+		})
+
+		res := fill(t, svc, ws, "db")
+		require.Equal(t, int64(3), res.Succeeded)
+		require.Equal(t, []string{"2025-0041", "2025-0042", "2026-0001", "2026-0002"}, texts(srv.Pages("db"), "No"))
+		require.Equal(t, map[string]int64{"@2025": 42, "@2026": 2}, res.Sequences)
+	})
+
+	t.Run("dates the pages with the date column in the table time zone", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Contracts", map[string]notion.PropertyType{
+			"No":      notion.PropertyTypeRichText,
+			"Signed":  notion.PropertyTypeDate,
+			"Project": notion.PropertyTypeSelect,
+		})
+		// the date without time stands for the same date in any time zone.
+		srv.AddPageAt("db", time.Date(2026, time.February, 28, 10, 0, 0, 0, time.UTC), map[string]notion.PageProperty{
+			"Signed": notiontest.Date(time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC)),
+		})
+		// the page without the date is dated with its creation time, which is March already in Berlin.
+		srv.AddPageAt("db", time.Date(2026, time.February, 28, 23, 30, 0, 0, time.UTC), nil)
+		srv.AddPageAt("db", time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC), map[string]notion.PageProperty{
+			"Signed": notiontest.Date(time.Date(2026, time.March, 1, 10, 0, 0, 0, time.FixedZone("CET", 3600))),
+		})
+		srv.AddPageAt("db", time.Date(2026, time.March, 1, 13, 0, 0, 0, time.UTC), map[string]notion.PageProperty{
+			"Signed":  notiontest.Date(time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)),
+			"Project": notiontest.Select("WEB"),
+		})
+		registerTable(t, svc, ws, autocounter.Table{
+			ID:        "db",
+			ParamName: "No",
+			ParamType: autocounter.ParamTypeRichText,
+			Separator: "-",
+			GroupBy:   "Project",
+			Reset:     autocounter.PeriodMonthly,
+			DateParam: "Signed",
+			TimeZone:  "Europe/Berlin",
+			Format:    "{yy}{mm}-{n}",
+		})
+
+		fill(t, svc, ws, "db")
+		require.Equal(t, []string{"2602-1", "2603-1", "2603-2", "WEB-2603-1"}, texts(srv.Pages("db"), "No"))
+	})
+}
+
+func TestTableFillNow(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	s, srv := newStorage(t), newNotion(t)
+	svc, err := service.NewTable(s, newClients(srv))
+	require.NoError(t, err)
+	ws := newWorkspace(t, s, "ws")
+
+	srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+	srv.AddPage("db", nil)
+	failing := srv.AddPage("db", nil)
+	srv.AddPage("db", nil)
+	registerTable(t, svc, ws, autocounter.Table{ID: "db"})
+
+	srv.Fail(http.MethodPatch, "/v1/pages/"+failing.ID, http.StatusBadRequest, 1)
+
+	res, err := svc.FillNow(ctx, "db", ws)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), res.Attempted)
+	require.Equal(t, int64(2), res.Succeeded)
+	require.Zero(t, res.Skipped)
+	require.Equal(t, int64(3), res.Counter)
+	require.Len(t, res.Failed, 1)
+	require.Equal(t, failing.ID, res.Failed[0].PageID)
+	require.Equal(t, int64(2), res.Failed[0].Value)
+	require.NotEmpty(t, res.Failed[0].Reason)
+
+	last, ok := svc.LastFill(ws.ID, "db")
+	require.True(t, ok)
+	require.Equal(t, res, last)
+
+	// the failed page is numbered with the next value.
+	res, err = svc.FillNow(ctx, "db", ws)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), res.Succeeded)
+	require.Equal(t, int64(4), res.Counter)
+	require.Empty(t, res.Failed)
+}
+
+func TestTableFillWs(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	s, srv := newStorage(t), newNotion(t)
+	svc, err := service.NewTable(s, newClients(srv))
+	require.NoError(t, err)
+	ws := newWorkspace(t, s, "ws")
+
+	schema := map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber}
+	srv.AddDatabase("tasks", "Tasks", schema)
+	srv.AddDatabase("bugs", "Bugs", schema)
+	srv.AddPage("tasks", nil)
+	srv.AddPage("tasks", nil)
+	failing := srv.AddPage("bugs", nil)
+	registerTable(t, svc, ws, autocounter.Table{ID: "tasks"})
+	registerTable(t, svc, ws, autocounter.Table{ID: "bugs"})
+
+	srv.Fail(http.MethodPatch, "/v1/pages/"+failing.ID, http.StatusBadRequest, 1)
+
+	report, err := svc.FillWs(ctx, ws)
+	require.NoError(t, err)
+	require.Equal(t, ws.ID, report.WorkspaceID)
+	require.Equal(t, 2, report.Tables)
+	require.Zero(t, report.FailedTables)
+	require.Equal(t, int64(3), report.Attempted)
+	require.Equal(t, int64(2), report.Succeeded)
+	require.Equal(t, int64(1), report.Failed)
+	require.Len(t, report.Fills, 2)
+
+	for _, fr := range report.Fills {
+		switch fr.TableID {
+		case "tasks":
+			require.Empty(t, fr.Failed)
+		case "bugs":
+			require.Len(t, fr.Failed, 1)
+			require.Equal(t, failing.ID, fr.Failed[0].PageID)
+		default:
+			t.Fatalf("unexpected table %s", fr.TableID)
+		}
+	}
+}
+
+func TestTableFillGapFree(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	setup := func(t *testing.T) (storage.Storage, *notiontest.Server, *service.Table, autocounter.Workspace) {
+		s, srv := newStorage(t), newNotion(t)
+		svc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("db", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		registerTable(t, svc, ws, autocounter.Table{ID: "db", GapFree: true})
+
+		return s, srv, svc, ws
+	}
+
+	t.Run("reassigns the value of the failed page", func(t *testing.T) {
+		_, srv, svc, ws := setup(t)
+		srv.AddPage("db", nil)
+		failing := srv.AddPage("db", nil)
+		srv.AddPage("db", nil)
+		srv.Fail(http.MethodPatch, "/v1/pages/"+failing.ID, http.StatusBadRequest, 1)
+
+		res := fill(t, svc, ws, "db")
+		require.Equal(t, []float64{1, 3, 2}, numbers(t, srv.Pages("db"), paramName))
+		require.Equal(t, int64(3), res.Succeeded)
+		require.Empty(t, res.Failed)
+		require.Equal(t, int64(3), res.Counter)
+	})
+
+	t.Run("releases the values that weren't used", func(t *testing.T) {
+		_, srv, svc, ws := setup(t)
+		srv.AddPage("db", nil)
+		failing := srv.AddPage("db", nil)
+		srv.AddPage("db", nil)
+		srv.Fail(http.MethodPatch, "/v1/pages/"+failing.ID, http.StatusBadRequest, 2)
+
+		res := fill(t, svc, ws, "db")
+		require.Equal(t, int64(2), res.Succeeded)
+		require.Len(t, res.Failed, 1)
+		require.Equal(t, failing.ID, res.Failed[0].PageID)
+		require.Equal(t, int64(2), res.Counter)
+		require.Empty(t, res.Holes)
+
+		res = fill(t, svc, ws, "db")
+		require.Equal(t, int64(1), res.Succeeded)
+		require.Equal(t, int64(3), res.Counter)
+		require.Equal(t, []float64{1, 3, 2}, numbers(t, srv.Pages("db"), paramName))
+	})
+
+	t.Run("fills the holes first", func(t *testing.T) {
+		s, srv, svc, ws := setup(t)
+		srv.AddPage("db", map[string]notion.PageProperty{paramName: notiontest.Number(1)})
+		srv.AddPage("db", map[string]notion.PageProperty{paramName: notiontest.Number(3)})
+		_, err := s.ReserveCounter(ctx, ws.ID, "db", autocounter.Reservation{Count: 5, Step: 1})
+		require.NoError(t, err)
+
+		rec, err := svc.Reconcile(ctx, "db", ws)
+		require.NoError(t, err)
+		require.Equal(t, int64(5), rec.Counter)
+		require.Equal(t, []int64{2, 4, 5}, rec.Holes)
+
+		srv.AddPage("db", nil)
+		srv.AddPage("db", nil)
+		res := fill(t, svc, ws, "db")
+		require.Equal(t, []float64{1, 3, 2, 4}, numbers(t, srv.Pages("db"), paramName))
+		require.Equal(t, []int64{5}, res.Holes)
+		require.Equal(t, int64(5), res.Counter)
+
+		srv.AddPage("db", nil)
+		srv.AddPage("db", nil)
+		res = fill(t, svc, ws, "db")
+		require.Equal(t, []float64{1, 3, 2, 4, 5, 6}, numbers(t, srv.Pages("db"), paramName))
+		require.Empty(t, res.Holes)
+		require.Equal(t, int64(6), res.Counter)
+
+		rec, err = svc.Reconcile(ctx, "db", ws)
+		require.NoError(t, err)
+		require.Empty(t, rec.Holes)
+	})
+
+	t.Run("keeps the holes till the next fill", func(t *testing.T) {
+		s, srv, svc, ws := setup(t)
+		srv.AddPage("db", map[string]notion.PageProperty{paramName: notiontest.Number(1)})
+		_, err := s.ReserveCounter(ctx, ws.ID, "db", autocounter.Reservation{Count: 3, Step: 1})
+		require.NoError(t, err)
+
+		srv.AddPage("db", nil)
+		res := fill(t, svc, ws, "db")
+		require.True(t, res.FullScan)
+		require.Equal(t, []int64{3}, res.Holes)
+
+		table, err := s.Table(ctx, ws.ID, "db")
+		require.NoError(t, err)
+		require.Equal(t, []int64{3}, table.Watermark.Holes)
+
+		// the values reserved since aren't looked up till the next full scan.
+		_, err = s.ReserveCounter(ctx, ws.ID, "db", autocounter.Reservation{Count: 1, Step: 1})
+		require.NoError(t, err)
+
+		srv.AddPage("db", nil)
+		srv.AddPage("db", nil)
+		res = fill(t, svc, ws, "db")
+		require.False(t, res.FullScan)
+		require.Equal(t, []float64{1, 2, 3, 5}, numbers(t, srv.Pages("db"), paramName))
+		require.Empty(t, res.Holes)
+
+		rec, err := svc.Reconcile(ctx, "db", ws)
+		require.NoError(t, err)
+		require.Equal(t, []int64{4}, rec.Holes)
+	})
+}
+
+// inFlight tracks the peak amount of the concurrent requests of the method.
+type inFlight struct {
+	method string
+	rt     http.RoundTripper
+
+	mu           sync.Mutex
+	cur, highest int
+}
+
+func (f *inFlight) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != f.method {
+		return f.rt.RoundTrip(r)
+	}
+
+	f.mu.Lock()
+	f.cur++
+	if f.cur > f.highest {
+		f.highest = f.cur
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.cur--
+		f.mu.Unlock()
+	}()
+
+	// let the concurrent requests overlap.
+	time.Sleep(2 * time.Millisecond)
+	return f.rt.RoundTrip(r)
+}
+
+func (f *inFlight) peak() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.highest
+}
+
+func TestTableConcurrency(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	s, srv := newStorage(t), newNotion(t)
+	patches := &inFlight{method: http.MethodPatch, rt: srv.Client().Transport}
+	svc, err := service.NewTable(s, notion.NewClients(
+		notion.WithAPIURL(srv.URL()),
+		notion.WithClient(&http.Client{Transport: patches}),
+	))
+	require.NoError(t, err)
+	require.Error(t, svc.SetConcurrency(service.Concurrency{Workspaces: 1, Tables: 2}))
+	require.NoError(t, svc.SetConcurrency(service.Concurrency{Workspaces: 1, Tables: 2, PageWorkers: 2}))
+	ws := newWorkspace(t, s, "ws")
+
+	schema := map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber}
+	for _, id := range []string{"tasks", "bugs"} {
+		srv.AddDatabase(id, id, schema)
+		for i := 0; i < 10; i++ {
+			srv.AddPage(id, nil)
+		}
+		registerTable(t, svc, ws, autocounter.Table{ID: id})
+	}
+
+	report, err := svc.FillWs(ctx, ws)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), report.Succeeded)
+	require.Equal(t, 2, patches.peak(), "pages of the same token are patched by the shared workers")
+}
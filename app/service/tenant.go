@@ -4,43 +4,101 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
+	"time"
 
 	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/internal/metrics"
+	"github.com/notionplusid/core/app/internal/workpool"
 	"github.com/notionplusid/core/app/provider/notion"
 	"github.com/notionplusid/core/app/storage"
 )
 
-// ProcWsFunc is the expected handler for the workspace processing.
-type ProcWsFunc func(ctx context.Context, ws autocounter.Workspace) (autocounter.Workspace, error)
+// ProcWsFunc is the expected handler for the workspace processing, returns the outcome of the pass.
+type ProcWsFunc func(ctx context.Context, ws autocounter.Workspace) (autocounter.Pass, error)
 
 // Tenant service.
 type Tenant struct {
-	s  storage.Storage
-	nc notion.ExtConfig
+	s       storage.Storage
+	nc      notion.ExtConfig
+	clients *notion.Clients
+
+	// workspaces processed concurrently.
+	wsConc int
+	// holder of the workspace leases, so the workspace is never processed by several instances at once.
+	holder string
+	// schedule of the workspace passes.
+	schedule autocounter.Schedule
 }
 
 // NewTenant constructor.
-func NewTenant(s storage.Storage, nc notion.ExtConfig) (*Tenant, error) {
+// The Notion API clients are expected to be shared with the Table service,
+// so both of them talk to the workspace through the same client.
+func NewTenant(s storage.Storage, nc notion.ExtConfig, clients *notion.Clients) (*Tenant, error) {
 	if err := nc.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 	if s == nil {
 		return nil, errors.New("storage is required")
 	}
+	if clients == nil {
+		return nil, errors.New("notion clients are required")
+	}
 
 	return &Tenant{
-		s:  s,
-		nc: nc,
+		s:       s,
+		nc:      nc,
+		clients: clients,
+		wsConc:  DefaultConcurrency.Workspaces,
+		holder:  newHolder(),
+
+		schedule: autocounter.DefaultSchedule,
 	}, nil
 }
 
+// SetConcurrency limits of the service.
+// Expected to be called before the service is used.
+func (t *Tenant) SetConcurrency(c Concurrency) error {
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("invalid concurrency: %w", err)
+	}
+	t.wsConc = c.Workspaces
+
+	return nil
+}
+
+// SetSchedule of the workspace passes.
+// Expected to be called before the service is used.
+func (t *Tenant) SetSchedule(s autocounter.Schedule) error {
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	t.schedule = s
+
+	return nil
+}
+
 // Workspace returns the configuration for the provided tenant ID.
 func (t *Tenant) Workspace(ctx context.Context, tenantID string) (autocounter.Workspace, error) {
 	return t.s.Workspace(ctx, tenantID)
 }
 
+// Workspaces returns all the registered workspaces.
+func (t *Tenant) Workspaces(ctx context.Context) ([]autocounter.Workspace, error) {
+	wss, err := t.s.Workspaces(ctx)
+	if err == autocounter.ErrNoResults {
+		return nil, nil
+	}
+	return wss, err
+}
+
+// AuthorizeURL returns the URL of the Notion page where the user authorises the extension with the provided state.
+func (t *Tenant) AuthorizeURL(state string) string {
+	return notion.AuthorizeURL(t.nc, state)
+}
+
 // AuthWorkspace by the provided code from the Notion redirect.
 func (t *Tenant) AuthWorkspace(ctx context.Context, code string) (autocounter.Workspace, error) {
 	res, err := notion.OAuth2(ctx, code, t.nc)
@@ -48,21 +106,61 @@ func (t *Tenant) AuthWorkspace(ctx context.Context, code string) (autocounter.Wo
 		return autocounter.Workspace{}, err
 	}
 
-	return autocounter.NewWorkspace(res.WorkspaceID, res.AccessToken)
+	ws, err := autocounter.NewWorkspace(res.WorkspaceID, res.AccessToken)
+	if err != nil {
+		return autocounter.Workspace{}, err
+	}
+	ws.Name = res.WorkspaceName
+	ws.IconURL = res.WorkspaceIconURL
+	ws.BotID = res.BotID
+	ws.DuplicatedTemplateID = res.DuplicatedTemplateID
+	ws.Owner = workspaceOwner(res.Owner)
+
+	return ws, nil
+}
+
+func workspaceOwner(o notion.OAuth2Owner) autocounter.WorkspaceOwner {
+	if o.Workspace || o.User == nil {
+		return autocounter.WorkspaceOwner{Type: o.Type}
+	}
+
+	owner := autocounter.WorkspaceOwner{
+		Type:     o.Type,
+		UserID:   o.User.ID,
+		UserName: o.User.Name,
+	}
+	if o.User.Person != nil {
+		owner.UserEmail = o.User.Person.Email
+	}
+
+	return owner
 }
 
 // RegisterWorkspace and persist it to the database.
+// In case if the Workspace was registered before - it's updated with the latest token and metadata.
 func (t *Tenant) RegisterWorkspace(ctx context.Context, ws autocounter.Workspace) (autocounter.Workspace, error) {
+	existing, err := t.s.Workspace(ctx, ws.ID)
+	switch {
+	case err == autocounter.ErrNoResults:
+	case err != nil:
+		return autocounter.Workspace{}, err
+	default:
+		ws.CreatedAt = existing.CreatedAt
+		// the template is duplicated only with the first installation.
+		if ws.DuplicatedTemplateID == "" {
+			ws.DuplicatedTemplateID = existing.DuplicatedTemplateID
+		}
+	}
+
 	return t.s.StoreWorkspace(ctx, ws)
 }
 
 // IsAvailable returns error if there's a way to reach out the workspace.
 func (t *Tenant) IsAvailable(ctx context.Context, ws autocounter.Workspace) error {
-	n, err := notion.NewFromWorkspace(ws)
+	n, err := t.clients.ForWorkspace(ctx, ws)
 	if err != nil {
 		return err
 	}
-	defer n.Close()
 
 	_, err = n.Me(ctx)
 	return err
@@ -73,35 +171,109 @@ func (t *Tenant) UnregisterWorkspace(ctx context.Context, wsID string) error {
 	if err := t.s.RemoveWorkspace(ctx, wsID); err != nil {
 		return err
 	}
+	t.clients.Forget(wsID)
 	return t.s.RemoveTablesFromWS(ctx, wsID)
 }
 
-// ProcOldestUpdated consistently processes the tenants that were processed the longest ago.
+// ProcOldestUpdated consistently processes the tenants that are due according to the schedule, the most overdue first.
+// The outcome of every pass schedules the next one.
+// The workspaces being processed by other instances at the moment are skipped.
+// Returns autocounter.ErrNoResults in case if no workspace is due.
 func (t *Tenant) ProcOldestUpdated(ctx context.Context, count int64, procWs ProcWsFunc) error {
 	return t.s.ProcOldestUpdatedWss(ctx, count, func(ctx context.Context, wss ...autocounter.Workspace) error {
+		if len(wss) == 0 {
+			return autocounter.ErrNoResults
+		}
+		metrics.WorkspaceLag.Set(time.Since(earliestDue(wss)).Seconds())
+
+		pool, err := workpool.New(t.wsConc)
+		if err != nil {
+			return err
+		}
+
 		wg := &sync.WaitGroup{}
+		defer wg.Wait()
 		for _, ws := range wss {
+			ws := ws
 			wg.Add(1)
-			go func(ws autocounter.Workspace) {
+			err := pool.Go(ctx, func() {
 				defer wg.Done()
+				ctx := logging.WithWorkspace(ctx, ws.ID)
 
-				err := t.IsAvailable(ctx, ws)
+				err := withLease(ctx, t.s, workspaceLease(ws.ID), t.holder, func(ctx context.Context) error {
+					t.procWs(ctx, ws, procWs)
+					return nil
+				})
 				switch {
-				case err == autocounter.ErrUnauthorized:
-					if err = t.UnregisterWorkspace(ctx, ws.ID); err != nil {
-						log.Printf("Tenant: ProcOldestUpdated: ProcOldestUpdatedWss: couldn't unregister the workspace %s: %s", ws.ID, err)
-					}
+				case err == autocounter.ErrLeaseHeld:
+					slog.DebugContext(ctx, "workspace is being processed by another instance: skipping")
 				case err != nil:
-					log.Printf("Tenant: ProcOldestUpdated: ProcOldestUpdatedWss: couldn't check the availability of the workspace %s: %s", ws.ID, err)
+					slog.ErrorContext(ctx, "couldn't lease the workspace", logging.Err(err))
 				}
-
-				_, err = procWs(ctx, ws)
-				if err != nil {
-					log.Printf("Tenant: ProcOldestUpdated: ProcOldestUpdatedWss: couldn't process workspace %s: %s", ws.ID, err)
-				}
-			}(ws)
+			})
+			if err != nil {
+				wg.Done()
+				return err
+			}
 		}
-		wg.Wait()
 		return nil
 	})
 }
+
+func (t *Tenant) procWs(ctx context.Context, ws autocounter.Workspace, procWs ProcWsFunc) {
+	err := t.IsAvailable(ctx, ws)
+	switch {
+	case err == autocounter.ErrUnauthorized:
+		slog.InfoContext(ctx, "workspace access was revoked: unregistering")
+		if err = t.UnregisterWorkspace(ctx, ws.ID); err != nil {
+			slog.ErrorContext(ctx, "couldn't unregister the workspace", logging.Err(err))
+		}
+		return
+	case err != nil:
+		slog.ErrorContext(ctx, "couldn't check the availability of the workspace", logging.Err(err))
+		t.scheduleWs(ctx, ws, autocounter.Pass{Error: err.Error()})
+		return
+	}
+
+	pass, err := procWs(ctx, ws)
+	if err != nil {
+		slog.ErrorContext(ctx, "couldn't process the workspace", logging.Err(err))
+		pass = autocounter.Pass{Error: err.Error()}
+	}
+	t.scheduleWs(ctx, ws, pass)
+}
+
+// scheduleWs records the outcome of the pass and schedules the next one.
+func (t *Tenant) scheduleWs(ctx context.Context, ws autocounter.Workspace, pass autocounter.Pass) {
+	ws = t.schedule.Next(ws, pass, time.Now())
+	if t.schedule.Quarantined(ws.LastPass) {
+		if ws.LastPass.Failures == t.schedule.QuarantineAfter {
+			metrics.WorkspacesQuarantined.Inc()
+		}
+		slog.WarnContext(ctx, "workspace keeps failing: quarantined",
+			slog.Int("failures", ws.LastPass.Failures),
+			slog.Time("next_run_at", ws.NextRunAt),
+			slog.String("error", ws.LastPass.Error),
+		)
+	}
+
+	err := t.s.StoreWorkspaceSchedule(ctx, ws.ID, ws.NextRunAt, ws.LastPass)
+	switch {
+	case err == autocounter.ErrNoResults:
+		// the workspace was removed while processed.
+	case err != nil:
+		slog.ErrorContext(ctx, "couldn't store the workspace schedule", logging.Err(err))
+	}
+}
+
+// earliestDue returns the earliest time the provided workspaces were due to be processed at.
+func earliestDue(wss []autocounter.Workspace) time.Time {
+	earliest := wss[0].DueAt()
+	for _, ws := range wss[1:] {
+		if ws.DueAt().Before(earliest) {
+			earliest = ws.DueAt()
+		}
+	}
+
+	return earliest
+}
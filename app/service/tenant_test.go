@@ -0,0 +1,200 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/service"
+)
+
+var testExtConfig = notion.ExtConfig{
+	ClientID:     "client",
+	ClientSecret: "secret",
+	RedirectURI:  "http://localhost/auth",
+}
+
+func TestTenantProcOldestUpdated(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	t.Run("registers and fills the tables", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		tenantSvc, err := service.NewTenant(s, testExtConfig, newClients(srv))
+		require.NoError(t, err)
+		tableSvc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("tasks", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		srv.AddDatabase("docs", "Docs", map[string]notion.PropertyType{"Name": notion.PropertyTypeTitle})
+		srv.AddPage("tasks", nil)
+		srv.AddPage("tasks", nil)
+
+		// the first pass discovers the tables.
+		require.NoError(t, tenantSvc.ProcOldestUpdated(ctx, 10, tableSvc.ProcWs))
+		tables, err := tableSvc.ListAllActive(ctx, ws.ID)
+		require.NoError(t, err)
+		require.Len(t, tables, 1)
+		require.Equal(t, "tasks", tables[0].ID)
+
+		// the second pass fills them.
+		require.NoError(t, tenantSvc.ProcOldestUpdated(ctx, 10, tableSvc.ProcWs))
+		require.Equal(t, []float64{1, 2}, numbers(t, srv.Pages("tasks"), paramName))
+	})
+
+	t.Run("unregisters the revoked workspace", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		tenantSvc, err := service.NewTenant(s, testExtConfig, newClients(srv))
+		require.NoError(t, err)
+		tableSvc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("tasks", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		require.NoError(t, tenantSvc.ProcOldestUpdated(ctx, 10, tableSvc.ProcWs))
+
+		srv.Revoke(testToken)
+		require.NoError(t, tenantSvc.ProcOldestUpdated(ctx, 10, tableSvc.ProcWs))
+
+		_, err = tenantSvc.Workspace(ctx, ws.ID)
+		require.Equal(t, autocounter.ErrNoResults, err)
+		_, err = tableSvc.FetchForWs(ctx, ws.ID, "tasks")
+		require.Equal(t, autocounter.ErrNoResults, err)
+	})
+}
+
+func TestTenantSchedule(t *testing.T) {
+	ctx := context.Background()
+	const paramName = autocounter.DefaultTableParamName
+
+	t.Run("idle workspace is backed off", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		tenantSvc, err := service.NewTenant(s, testExtConfig, newClients(srv))
+		require.NoError(t, err)
+		require.NoError(t, tenantSvc.SetSchedule(autocounter.Schedule{Idle: time.Hour, MaxIdle: time.Hour}))
+		tableSvc, err := service.NewTable(s, newClients(srv))
+		require.NoError(t, err)
+		ws := newWorkspace(t, s, "ws")
+
+		srv.AddDatabase("tasks", "Tasks", map[string]notion.PropertyType{paramName: notion.PropertyTypeNumber})
+		srv.AddPage("tasks", nil)
+
+		var passes []autocounter.Pass
+		procWs := func(ctx context.Context, ws autocounter.Workspace) (autocounter.Pass, error) {
+			pass, err := tableSvc.ProcWs(ctx, ws)
+			passes = append(passes, pass)
+			return pass, err
+		}
+
+		// the busy passes register the table and fill it, the idle one backs the workspace off.
+		for i := 0; i < 3; i++ {
+			require.NoError(t, tenantSvc.ProcOldestUpdated(ctx, 10, procWs))
+		}
+		require.Equal(t, autocounter.ErrNoResults, tenantSvc.ProcOldestUpdated(ctx, 10, procWs))
+		require.Equal(t, []autocounter.Pass{{Registered: 1}, {Numbered: 1}, {}}, passes)
+
+		ws, err = tenantSvc.Workspace(ctx, ws.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, ws.LastPass.Idle)
+		require.WithinDuration(t, time.Now().Add(time.Hour), ws.NextRunAt, time.Minute)
+	})
+
+	t.Run("repeatedly failing workspace is quarantined", func(t *testing.T) {
+		s, srv := newStorage(t), newNotion(t)
+		tenantSvc, err := service.NewTenant(s, testExtConfig, newClients(srv))
+		require.NoError(t, err)
+		require.NoError(t, tenantSvc.SetSchedule(autocounter.Schedule{QuarantineAfter: 2, Quarantine: time.Hour}))
+		ws := newWorkspace(t, s, "ws")
+
+		var calls int
+		procWs := func(ctx context.Context, ws autocounter.Workspace) (autocounter.Pass, error) {
+			calls++
+			return autocounter.Pass{}, errors.New("boom")
+		}
+		for i := 0; i < 2; i++ {
+			require.NoError(t, tenantSvc.ProcOldestUpdated(ctx, 10, procWs))
+		}
+		err = tenantSvc.ProcOldestUpdated(ctx, 10, procWs)
+		require.Equal(t, autocounter.ErrNoResults, err, "the quarantined workspace isn't processed")
+		require.Equal(t, 2, calls)
+
+		ws, err = tenantSvc.Workspace(ctx, ws.ID)
+		require.NoError(t, err)
+		require.Equal(t, 2, ws.LastPass.Failures)
+		require.Equal(t, "boom", ws.LastPass.Error)
+		require.WithinDuration(t, time.Now().Add(time.Hour), ws.NextRunAt, time.Minute)
+	})
+}
+
+func TestTenantAuthWorkspace(t *testing.T) {
+	ctx := context.Background()
+	s, srv := newStorage(t), newNotion(t)
+	nc := testExtConfig
+	nc.APIURL = srv.URL()
+	tenantSvc, err := service.NewTenant(s, nc, newClients(srv))
+	require.NoError(t, err)
+
+	srv.SetOAuthClient(nc.ClientID, nc.ClientSecret)
+	owner := notion.OAuth2Owner{
+		Type: autocounter.OwnerTypeUser,
+		User: &notion.User{Object: "user", ID: "user", Name: "User"},
+	}
+	srv.AddOAuthCode("code", notion.OAuth2Res{
+		AccessToken:          testToken,
+		BotID:                "bot",
+		DuplicatedTemplateID: "template",
+		Owner:                owner,
+		WorkspaceID:          "ws",
+		WorkspaceName:        "Workspace",
+		WorkspaceIconURL:     "https://example.com/icon.png",
+	})
+
+	ws, err := tenantSvc.AuthWorkspace(ctx, "code")
+	require.NoError(t, err)
+	require.Equal(t, "ws", ws.ID)
+	require.Equal(t, testToken, ws.Token)
+
+	// the code can't be exchanged twice.
+	_, err = tenantSvc.AuthWorkspace(ctx, "code")
+	require.Error(t, err)
+
+	registered, err := tenantSvc.RegisterWorkspace(ctx, ws)
+	require.NoError(t, err)
+
+	stored, err := tenantSvc.Workspace(ctx, "ws")
+	require.NoError(t, err)
+	require.Equal(t, "Workspace", stored.Name)
+	require.Equal(t, "https://example.com/icon.png", stored.IconURL)
+	require.Equal(t, "bot", stored.BotID)
+	require.Equal(t, "template", stored.DuplicatedTemplateID)
+	require.Equal(t, autocounter.WorkspaceOwner{Type: autocounter.OwnerTypeUser, UserID: "user", UserName: "User"}, stored.Owner)
+
+	t.Run("updates the metadata on re-authorization", func(t *testing.T) {
+		srv.AddOAuthCode("again", notion.OAuth2Res{
+			AccessToken:   "new_token",
+			BotID:         "bot",
+			Owner:         owner,
+			WorkspaceID:   "ws",
+			WorkspaceName: "Renamed",
+		})
+
+		ws, err := tenantSvc.AuthWorkspace(ctx, "again")
+		require.NoError(t, err)
+		_, err = tenantSvc.RegisterWorkspace(ctx, ws)
+		require.NoError(t, err)
+
+		stored, err := tenantSvc.Workspace(ctx, "ws")
+		require.NoError(t, err)
+		require.Equal(t, "new_token", stored.Token)
+		require.Equal(t, "Renamed", stored.Name)
+		require.Empty(t, stored.IconURL)
+		require.Equal(t, "template", stored.DuplicatedTemplateID)
+		require.True(t, registered.CreatedAt.Equal(stored.CreatedAt))
+	})
+}
@@ -0,0 +1,633 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/storage"
+)
+
+const defaultOpenTO = 5 * time.Second
+
+var (
+	workspaceBucket = []byte("workspaces")
+	tableBucket     = []byte("tables")
+	counterBucket   = []byte("counters")
+	leaseBucket     = []byte("leases")
+	rateBucket      = []byte("rate_limits")
+)
+
+// Client for the embedded bbolt database stored in a local file.
+// Meant for the single instance setups that don't need an external database.
+type Client struct {
+	db *bbolt.DB
+}
+
+// Make sure bolt client is compatible.
+var _ storage.Storage = (*Client)(nil)
+
+// New bolt Client constructor.
+// The database file is created in case if it doesn't exist yet.
+func New(path string) (*Client, error) {
+	if path == "" {
+		return nil, errors.New("path is required")
+	}
+
+	// the file is locked by the process, so the second instance would fail instead of waiting forever.
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: defaultOpenTO})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, b := range [][]byte{workspaceBucket, tableBucket, counterBucket, leaseBucket, rateBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return fmt.Errorf("couldn't create bucket %s: %w", b, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Client{db: db}, nil
+}
+
+// Close the Client.
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+func get(b *bbolt.Bucket, id string, v interface{}) error {
+	raw := b.Get([]byte(id))
+	if raw == nil {
+		return autocounter.ErrNoResults
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+func put(b *bbolt.Bucket, id string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(id), raw)
+}
+
+func (c *Client) workspaces(tx *bbolt.Tx) ([]autocounter.Workspace, error) {
+	var res []autocounter.Workspace
+	err := tx.Bucket(workspaceBucket).ForEach(func(_, raw []byte) error {
+		var ws autocounter.Workspace
+		if err := json.Unmarshal(raw, &ws); err != nil {
+			return err
+		}
+		res = append(res, ws)
+		return nil
+	})
+
+	return res, err
+}
+
+// tables returns the stored tables that match the filter.
+func (c *Client) tables(tx *bbolt.Tx, match func(t autocounter.Table) bool) ([]autocounter.Table, error) {
+	var res []autocounter.Table
+	err := tx.Bucket(tableBucket).ForEach(func(_, raw []byte) error {
+		var t autocounter.Table
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return err
+		}
+		if match(t) {
+			res = append(res, t)
+		}
+		return nil
+	})
+
+	return res, err
+}
+
+// Workspace returns the instance by the requested ID.
+func (c *Client) Workspace(ctx context.Context, id string) (autocounter.Workspace, error) {
+	if id == "" {
+		return autocounter.Workspace{}, errors.New("id is required")
+	}
+
+	var ws autocounter.Workspace
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return get(tx.Bucket(workspaceBucket), id, &ws)
+	})
+	if err != nil {
+		return autocounter.Workspace{}, err
+	}
+
+	return ws, nil
+}
+
+// Workspaces returns all the available instances.
+func (c *Client) Workspaces(ctx context.Context) ([]autocounter.Workspace, error) {
+	var res []autocounter.Workspace
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		res, err = c.workspaces(tx)
+		return err
+	})
+
+	return res, err
+}
+
+// StoreWorkspace for future usage along with the access token.
+func (c *Client) StoreWorkspace(ctx context.Context, ws autocounter.Workspace) (autocounter.Workspace, error) {
+	if err := ws.Validate(); err != nil {
+		return autocounter.Workspace{}, err
+	}
+
+	now := time.Now()
+	if ws.CreatedAt.IsZero() {
+		ws.CreatedAt = now
+	}
+	ws.UpdatedAt = now
+
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx.Bucket(workspaceBucket), ws.ID, &ws)
+	})
+	if err != nil {
+		return autocounter.Workspace{}, fmt.Errorf("couldn't put the workspace: %s", err)
+	}
+
+	return ws, nil
+}
+
+// ProcOldestUpdatedWss fetches the amount of workspaces that are due to be processed and applies the `procWss`.
+// The workspaces are claimed within a transaction by moving their next run forward by storage.ClaimTTL,
+// so they aren't due again till the processing schedules their next run.
+// The claim is released before the processing, so `procWss` is free to modify or remove the workspaces.
+func (c *Client) ProcOldestUpdatedWss(ctx context.Context, count int64, procWss storage.ProcWssFunc) error {
+	var wss []autocounter.Workspace
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		all, err := c.workspaces(tx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var due []autocounter.Workspace
+		for _, ws := range all {
+			if !ws.NextRunAt.After(now) {
+				due = append(due, ws)
+			}
+		}
+
+		sort.Slice(due, func(i, j int) bool {
+			if !due[i].NextRunAt.Equal(due[j].NextRunAt) {
+				return due[i].NextRunAt.Before(due[j].NextRunAt)
+			}
+			return due[i].ProcessedAt.Before(due[j].ProcessedAt)
+		})
+		if int64(len(due)) > count {
+			due = due[:count]
+		}
+		wss = due
+
+		// claim the workspaces.
+		b := tx.Bucket(workspaceBucket)
+		for _, ws := range wss {
+			ws.ProcessedAt = now
+			ws.NextRunAt = now.Add(storage.ClaimTTL)
+			if err := put(b, ws.ID, &ws); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// process every workspace.
+	if err := procWss(ctx, wss...); err != nil {
+		return err
+	}
+
+	// update the processed timestamp of the workspaces that are still there.
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		now := time.Now()
+		b := tx.Bucket(workspaceBucket)
+		for _, item := range wss {
+			var ws autocounter.Workspace
+			err := get(b, item.ID, &ws)
+			switch {
+			case err == autocounter.ErrNoResults:
+				continue
+			case err != nil:
+				return err
+			}
+
+			ws.ProcessedAt = now
+			if err := put(b, ws.ID, &ws); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// StoreWorkspaceSchedule within a transaction.
+func (c *Client) StoreWorkspaceSchedule(ctx context.Context, wsID string, nextRunAt time.Time, pass autocounter.Pass) error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(workspaceBucket)
+
+		var ws autocounter.Workspace
+		if err := get(b, wsID, &ws); err != nil {
+			return err
+		}
+
+		ws.NextRunAt = nextRunAt
+		ws.LastPass = pass
+		return put(b, ws.ID, &ws)
+	})
+}
+
+// RemoveWorkspace for the Client.
+func (c *Client) RemoveWorkspace(ctx context.Context, wsID string) error {
+	if wsID == "" {
+		return errors.New("workspace id is required")
+	}
+
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(workspaceBucket).Delete([]byte(wsID))
+	})
+}
+
+// Tables returns all the available instances.
+func (c *Client) Tables(ctx context.Context) ([]autocounter.Table, error) {
+	var res []autocounter.Table
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		res, err = c.tables(tx, func(autocounter.Table) bool { return true })
+		return err
+	})
+
+	return res, err
+}
+
+// Table returns the instance by the requested ID within the workspace.
+func (c *Client) Table(ctx context.Context, wsID, tableID string) (autocounter.Table, error) {
+	switch {
+	case wsID == "":
+		return autocounter.Table{}, errors.New("workspace id is required")
+	case tableID == "":
+		return autocounter.Table{}, errors.New("table id is required")
+	}
+
+	var t autocounter.Table
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return get(tx.Bucket(tableBucket), tableID, &t)
+	})
+	if err != nil {
+		return autocounter.Table{}, err
+	}
+
+	// in case if such ID is registered with another workspace.
+	if t.WorkspaceID != wsID {
+		return autocounter.Table{}, autocounter.ErrNoResults
+	}
+
+	return t, nil
+}
+
+// StoreTable instance.
+func (c *Client) StoreTable(ctx context.Context, workspaceID string, table autocounter.Table) (autocounter.Table, error) {
+	table.WorkspaceID = workspaceID
+	if err := table.Validate(); err != nil {
+		return autocounter.Table{}, err
+	}
+
+	now := time.Now()
+	table.CreatedAt = now
+	table.UpdatedAt = now
+
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx.Bucket(tableBucket), table.ID, &table)
+	})
+	if err != nil {
+		return autocounter.Table{}, err
+	}
+
+	return table, nil
+}
+
+// DisableTable instance.
+func (c *Client) DisableTable(ctx context.Context, wsID, tableID string) (autocounter.Table, error) {
+	var t autocounter.Table
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(tableBucket)
+		if err := get(b, tableID, &t); err != nil {
+			return err
+		}
+
+		if t.WorkspaceID != wsID {
+			return autocounter.ErrNoResults
+		}
+
+		t.Status = autocounter.StatusDisabled
+		return put(b, t.ID, &t)
+	})
+	if err != nil {
+		return autocounter.Table{}, err
+	}
+
+	return t, nil
+}
+
+// StoreTableWatermark within a transaction.
+func (c *Client) StoreTableWatermark(ctx context.Context, wsID, tableID string, w autocounter.Watermark) error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(tableBucket)
+
+		var t autocounter.Table
+		if err := get(b, tableID, &t); err != nil {
+			return err
+		}
+		if t.WorkspaceID != wsID {
+			return autocounter.ErrNoResults
+		}
+
+		t.Watermark = w
+		return put(b, t.ID, &t)
+	})
+}
+
+// ActiveTables returns the subset of table IDs from the provided list that are active.
+func (c *Client) ActiveTables(ctx context.Context, workspaceID string, tableIDs []string) ([]string, error) {
+	var ids []string
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(tableBucket)
+		for _, id := range tableIDs {
+			var t autocounter.Table
+			err := get(b, id, &t)
+			switch {
+			case err == autocounter.ErrNoResults:
+				continue
+			case err != nil:
+				return err
+			}
+
+			// in case if such ID is registered with another workspace.
+			if t.WorkspaceID != workspaceID {
+				continue
+			}
+
+			if t.Status != autocounter.StatusActive {
+				continue
+			}
+
+			ids = append(ids, t.ID)
+		}
+		return nil
+	})
+
+	return ids, err
+}
+
+// ListAllActiveTables stored in the database for the workspace.
+func (c *Client) ListAllActiveTables(ctx context.Context, workspaceID string) ([]autocounter.Table, error) {
+	if workspaceID == "" {
+		return nil, errors.New("workspace id is required")
+	}
+
+	var res []autocounter.Table
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		res, err = c.tables(tx, func(t autocounter.Table) bool {
+			return t.WorkspaceID == workspaceID && t.Status == autocounter.StatusActive
+		})
+		return err
+	})
+
+	return res, err
+}
+
+// RemoveTablesFromWS clears up all the tables associated with the workspace ID along with their counters.
+func (c *Client) RemoveTablesFromWS(ctx context.Context, wsID string) error {
+	if wsID == "" {
+		return errors.New("workspace id is required")
+	}
+
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		ts, err := c.tables(tx, func(t autocounter.Table) bool {
+			return t.WorkspaceID == wsID
+		})
+		if err != nil {
+			return err
+		}
+
+		tb := tx.Bucket(tableBucket)
+		for _, t := range ts {
+			if err := tb.Delete([]byte(t.ID)); err != nil {
+				return err
+			}
+		}
+
+		var cids [][]byte
+		cb := tx.Bucket(counterBucket)
+		err = cb.ForEach(func(id, raw []byte) error {
+			var cnt autocounter.Counter
+			if err := json.Unmarshal(raw, &cnt); err != nil {
+				return err
+			}
+			if cnt.WorkspaceID == wsID {
+				cids = append(cids, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// keys can't be deleted while iterating over the bucket.
+		for _, id := range cids {
+			if err := cb.Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Counter returns the last issued value of the Table.
+func (c *Client) Counter(ctx context.Context, wsID, tableID string) (autocounter.Counter, error) {
+	switch {
+	case wsID == "":
+		return autocounter.Counter{}, errors.New("workspace id is required")
+	case tableID == "":
+		return autocounter.Counter{}, errors.New("table id is required")
+	}
+
+	var cnt autocounter.Counter
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return get(tx.Bucket(counterBucket), tableID, &cnt)
+	})
+	if err != nil {
+		return autocounter.Counter{}, err
+	}
+
+	// in case if such ID is registered with another workspace.
+	if cnt.WorkspaceID != wsID {
+		return autocounter.Counter{}, autocounter.ErrNoResults
+	}
+
+	return cnt, nil
+}
+
+// ReserveCounter advances the counter of the Table within a transaction and returns the reserved values.
+func (c *Client) ReserveCounter(ctx context.Context, wsID, tableID string, r autocounter.Reservation) ([]int64, error) {
+	switch {
+	case wsID == "":
+		return nil, errors.New("workspace id is required")
+	case tableID == "":
+		return nil, errors.New("table id is required")
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	var vals []int64
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(counterBucket)
+
+		var cnt autocounter.Counter
+		err := get(b, tableID, &cnt)
+		switch {
+		case err == autocounter.ErrNoResults:
+			cnt = autocounter.NewCounter(wsID, tableID, r)
+		case err != nil:
+			return err
+		case cnt.WorkspaceID != wsID:
+			return autocounter.ErrNoResults
+		}
+
+		cnt, vals = cnt.Reserve(r)
+		return put(b, tableID, &cnt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vals, nil
+}
+
+// ReleaseCounter moves the counter back within a transaction in case if it wasn't moved since.
+func (c *Client) ReleaseCounter(ctx context.Context, wsID, tableID string, last, to int64) error {
+	switch {
+	case wsID == "":
+		return errors.New("workspace id is required")
+	case tableID == "":
+		return errors.New("table id is required")
+	}
+
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(counterBucket)
+
+		var cnt autocounter.Counter
+		if err := get(b, tableID, &cnt); err != nil {
+			return err
+		}
+		if cnt.WorkspaceID != wsID {
+			return autocounter.ErrNoResults
+		}
+
+		cnt, err := cnt.Release(last, to)
+		if err != nil {
+			return err
+		}
+		return put(b, tableID, &cnt)
+	})
+}
+
+// AcquireLease within a transaction.
+func (c *Client) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (autocounter.Lease, error) {
+	if name == "" {
+		return autocounter.Lease{}, errors.New("name is required")
+	}
+
+	var res autocounter.Lease
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(leaseBucket)
+
+		l := autocounter.Lease{Name: name}
+		if err := get(b, name, &l); err != nil && err != autocounter.ErrNoResults {
+			return err
+		}
+
+		l, err := l.Acquire(holder, ttl, time.Now())
+		if err != nil {
+			return err
+		}
+		res = l
+		return put(b, name, &l)
+	})
+	if err != nil {
+		return autocounter.Lease{}, err
+	}
+
+	return res, nil
+}
+
+// ReleaseLease within a transaction.
+func (c *Client) ReleaseLease(ctx context.Context, name, holder string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(leaseBucket)
+
+		var l autocounter.Lease
+		err := get(b, name, &l)
+		switch {
+		case err == autocounter.ErrNoResults:
+			return nil
+		case err != nil:
+			return err
+		case l.Holder != holder:
+			return nil
+		}
+
+		return b.Delete([]byte(name))
+	})
+}
+
+// ReserveRate within a transaction.
+func (c *Client) ReserveRate(ctx context.Context, key string, interval time.Duration, burst int) (time.Time, error) {
+	if key == "" {
+		return time.Time{}, errors.New("key is required")
+	}
+
+	var at time.Time
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(rateBucket)
+
+		r := autocounter.RateLimit{Key: key}
+		if err := get(b, key, &r); err != nil && err != autocounter.ErrNoResults {
+			return err
+		}
+
+		r, at = r.Reserve(interval, burst, time.Now())
+		return put(b, key, &r)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return at, nil
+}
@@ -0,0 +1,21 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/notionplusid/core/app/storage"
+	"github.com/notionplusid/core/app/storage/storagetest"
+)
+
+func TestClient(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		c, err := New(filepath.Join(t.TempDir(), "plusid.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+
+		return c
+	})
+}
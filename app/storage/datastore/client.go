@@ -4,20 +4,27 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	datastoresdk "cloud.google.com/go/datastore"
 
 	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
 	"github.com/notionplusid/core/app/storage"
 )
 
 const (
 	workspaceKey = "Workspace"
 	tableKey     = "Table"
+	counterKey   = "Counter"
+	leaseKey     = "Lease"
+	rateKey      = "RateLimit"
 )
 
+// defaultReserveAttempts of the counter transaction in case of the concurrent reservations.
+const defaultReserveAttempts = 10
+
 // Client for Datastore.
 type Client struct {
 	ds *datastoresdk.Client
@@ -61,7 +68,9 @@ func (c *Client) StoreWorkspace(ctx context.Context, ws autocounter.Workspace) (
 	}
 
 	now := time.Now()
-	ws.CreatedAt = now
+	if ws.CreatedAt.IsZero() {
+		ws.CreatedAt = now
+	}
 	ws.UpdatedAt = now
 
 	_, err := c.ds.Put(ctx, datastoresdk.NameKey(workspaceKey, ws.ID, nil), &ws)
@@ -123,6 +132,32 @@ func (c *Client) Tables(ctx context.Context) ([]autocounter.Table, error) {
 	return res, nil
 }
 
+// Table returns the instance by the requested ID within the workspace.
+func (c *Client) Table(ctx context.Context, wsID, tableID string) (autocounter.Table, error) {
+	switch {
+	case wsID == "":
+		return autocounter.Table{}, errors.New("workspace id is required")
+	case tableID == "":
+		return autocounter.Table{}, errors.New("table id is required")
+	}
+
+	var t autocounter.Table
+	err := c.ds.Get(ctx, datastoresdk.NameKey(tableKey, tableID, nil), &t)
+	switch {
+	case err == datastoresdk.ErrNoSuchEntity:
+		return autocounter.Table{}, autocounter.ErrNoResults
+	case err != nil:
+		return autocounter.Table{}, err
+	}
+
+	// in case if such ID is registered with another workspace.
+	if t.WorkspaceID != wsID {
+		return autocounter.Table{}, autocounter.ErrNoResults
+	}
+
+	return t, nil
+}
+
 // DisableTable instance.
 func (c *Client) DisableTable(ctx context.Context, wsID, tID string) (autocounter.Table, error) {
 	key := datastoresdk.NameKey(tableKey, tID, nil)
@@ -146,6 +181,29 @@ func (c *Client) DisableTable(ctx context.Context, wsID, tID string) (autocounte
 	return t, err
 }
 
+// StoreTableWatermark within a transaction.
+func (c *Client) StoreTableWatermark(ctx context.Context, wsID, tableID string, w autocounter.Watermark) error {
+	key := datastoresdk.NameKey(tableKey, tableID, nil)
+
+	_, err := c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
+		var t autocounter.Table
+		err := tx.Get(key, &t)
+		switch {
+		case err == datastoresdk.ErrNoSuchEntity:
+			return autocounter.ErrNoResults
+		case err != nil:
+			return err
+		case t.WorkspaceID != wsID:
+			return autocounter.ErrNoResults
+		}
+
+		t.Watermark = w
+		_, err = tx.Put(key, &t)
+		return err
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
+	return err
+}
+
 // StoreTable instance.
 func (c *Client) StoreTable(ctx context.Context, workspaceID string, table autocounter.Table) (autocounter.Table, error) {
 	table.WorkspaceID = workspaceID
@@ -166,6 +224,10 @@ func (c *Client) StoreTable(ctx context.Context, workspaceID string, table autoc
 
 // ActiveTables returns the subset of table IDs from the provided list that are active.
 func (c *Client) ActiveTables(ctx context.Context, workspaceID string, tableIDs []string) ([]string, error) {
+	if len(tableIDs) == 0 {
+		return nil, nil
+	}
+
 	var keys []*datastoresdk.Key
 	for _, t := range tableIDs {
 		keys = append(keys, datastoresdk.NameKey(tableKey, t, nil))
@@ -180,7 +242,7 @@ func (c *Client) ActiveTables(ctx context.Context, workspaceID string, tableIDs
 				continue
 			}
 
-			log.Printf("Datastore: RegisteredTables: couldn't fetch item: %s", err)
+			slog.ErrorContext(ctx, "couldn't fetch the active table", logging.Err(err))
 		}
 	}
 	switch {
@@ -209,38 +271,131 @@ func (c *Client) ActiveTables(ctx context.Context, workspaceID string, tableIDs
 	return ids, nil
 }
 
-// ProcOldestUpdatedWss fetches the amount of workspaces that were processed the longest ago and applies the `procWss`
-// at the returned workspaces set in a transactional manner.
+// ProcOldestUpdatedWss fetches the amount of workspaces that are due to be processed and applies the `procWss`.
+// The workspaces are claimed within a transaction by moving their next run forward by storage.ClaimTTL,
+// so they aren't due again till the processing schedules their next run.
+// The claim is released before the processing, so `procWss` is free to modify or remove the workspaces.
 func (c *Client) ProcOldestUpdatedWss(ctx context.Context, count int64, procWss storage.ProcWssFunc) error {
+	var wss []autocounter.Workspace
 	_, err := c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
-		// lock items into transaction.
-		q := datastoresdk.NewQuery(workspaceKey).
-			Transaction(tx).
-			Order("ProcessedAt").
-			Limit(int(count))
-
-		// fetch all the items that needs to be updated.
-		var wss []autocounter.Workspace
-		ks, err := c.ds.GetAll(ctx, q, &wss)
+		now := time.Now()
+		due, ks, err := c.dueWorkspaces(ctx, tx, now, count)
 		if err != nil {
 			return err
 		}
 
-		// process every workspace.
-		if err := procWss(ctx, wss...); err != nil {
+		// claim the workspaces.
+		claimed := make([]autocounter.Workspace, len(due))
+		for i, ws := range due {
+			ws.ProcessedAt = now
+			ws.NextRunAt = now.Add(storage.ClaimTTL)
+			claimed[i] = ws
+		}
+		if _, err := tx.PutMulti(ks, claimed); err != nil {
 			return err
 		}
+		wss = due
+		return nil
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
+	if err != nil {
+		return err
+	}
 
-		// update the processed timestamp.
+	// process every workspace.
+	if err := procWss(ctx, wss...); err != nil {
+		return err
+	}
+
+	// update the processed timestamp of the workspaces that are still there.
+	_, err = c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
 		now := time.Now()
-		for i := range wss {
-			wss[i].ProcessedAt = now
+		for _, item := range wss {
+			key := datastoresdk.NameKey(workspaceKey, item.ID, nil)
+
+			var ws autocounter.Workspace
+			err := tx.Get(key, &ws)
+			switch {
+			case err == datastoresdk.ErrNoSuchEntity:
+				continue
+			case err != nil:
+				return err
+			}
+
+			ws.ProcessedAt = now
+			if _, err := tx.Put(key, &ws); err != nil {
+				return err
+			}
 		}
+		return nil
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
+	return err
+}
+
+// dueWorkspaces returns the amount of workspaces due to be processed at `now`, the most overdue first.
+func (c *Client) dueWorkspaces(
+	ctx context.Context,
+	tx *datastoresdk.Transaction,
+	now time.Time,
+	count int64,
+) ([]autocounter.Workspace, []*datastoresdk.Key, error) {
+	var wss []autocounter.Workspace
+	ks, err := c.ds.GetAll(ctx, datastoresdk.NewQuery(workspaceKey).
+		Transaction(tx).
+		FilterField("NextRunAt", "<=", now).
+		Order("NextRunAt").
+		Limit(int(count)), &wss)
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(wss)) >= count {
+		return wss, ks, nil
+	}
+
+	// the workspaces stored before the scheduling was introduced have no next run to be filtered by,
+	// they are picked by the processed timestamp until claimed once.
+	var legacy []autocounter.Workspace
+	lks, err := c.ds.GetAll(ctx, datastoresdk.NewQuery(workspaceKey).
+		Transaction(tx).
+		Order("ProcessedAt").
+		Limit(int(count)), &legacy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	picked := map[string]struct{}{}
+	for _, ws := range wss {
+		picked[ws.ID] = struct{}{}
+	}
+	for i, ws := range legacy {
+		if _, ok := picked[ws.ID]; ok || !ws.NextRunAt.IsZero() || int64(len(wss)) >= count {
+			continue
+		}
+		wss = append(wss, ws)
+		ks = append(ks, lks[i])
+	}
+
+	return wss, ks, nil
+}
+
+// StoreWorkspaceSchedule within a transaction.
+func (c *Client) StoreWorkspaceSchedule(ctx context.Context, wsID string, nextRunAt time.Time, pass autocounter.Pass) error {
+	key := datastoresdk.NameKey(workspaceKey, wsID, nil)
 
-		// write the new state back into the database.
-		_, err = tx.PutMulti(ks, wss)
+	_, err := c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
+		var ws autocounter.Workspace
+		err := tx.Get(key, &ws)
+		switch {
+		case err == datastoresdk.ErrNoSuchEntity:
+			return autocounter.ErrNoResults
+		case err != nil:
+			return err
+		}
+
+		ws.NextRunAt = nextRunAt
+		ws.LastPass = pass
+		_, err = tx.Put(key, &ws)
 		return err
-	})
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
 	return err
 }
 
@@ -277,5 +432,197 @@ func (c *Client) RemoveTablesFromWS(ctx context.Context, wsID string) error {
 		keys = append(keys, datastoresdk.NameKey(tableKey, t.ID, nil))
 	}
 
-	return c.ds.DeleteMulti(ctx, keys)
+	if err := c.ds.DeleteMulti(ctx, keys); err != nil {
+		return err
+	}
+
+	// counters of the removed tables are not needed anymore.
+	ckeys, err := c.ds.GetAll(ctx, datastoresdk.NewQuery(counterKey).FilterField("WorkspaceID", "=", wsID).KeysOnly(), nil)
+	if err != nil {
+		return err
+	}
+
+	return c.ds.DeleteMulti(ctx, ckeys)
+}
+
+// Counter returns the last issued value of the Table.
+func (c *Client) Counter(ctx context.Context, wsID, tableID string) (autocounter.Counter, error) {
+	switch {
+	case wsID == "":
+		return autocounter.Counter{}, errors.New("workspace id is required")
+	case tableID == "":
+		return autocounter.Counter{}, errors.New("table id is required")
+	}
+
+	var res autocounter.Counter
+	err := c.ds.Get(ctx, datastoresdk.NameKey(counterKey, tableID, nil), &res)
+	switch {
+	case err == datastoresdk.ErrNoSuchEntity:
+		return autocounter.Counter{}, autocounter.ErrNoResults
+	case err != nil:
+		return autocounter.Counter{}, err
+	}
+
+	// in case if such ID is registered with another workspace.
+	if res.WorkspaceID != wsID {
+		return autocounter.Counter{}, autocounter.ErrNoResults
+	}
+
+	return res, nil
+}
+
+// ReserveCounter advances the counter of the Table within a transaction and returns the reserved values.
+func (c *Client) ReserveCounter(ctx context.Context, wsID, tableID string, r autocounter.Reservation) ([]int64, error) {
+	switch {
+	case wsID == "":
+		return nil, errors.New("workspace id is required")
+	case tableID == "":
+		return nil, errors.New("table id is required")
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := datastoresdk.NameKey(counterKey, tableID, nil)
+
+	var vals []int64
+	_, err := c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
+		var cnt autocounter.Counter
+		err := tx.Get(key, &cnt)
+		switch {
+		case err == datastoresdk.ErrNoSuchEntity:
+			cnt = autocounter.NewCounter(wsID, tableID, r)
+		case err != nil:
+			return err
+		case cnt.WorkspaceID != wsID:
+			return autocounter.ErrNoResults
+		}
+
+		cnt, vals = cnt.Reserve(r)
+
+		_, err = tx.Put(key, &cnt)
+		return err
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
+	if err != nil {
+		return nil, err
+	}
+
+	return vals, nil
+}
+
+// ReleaseCounter moves the counter back within a transaction in case if it wasn't moved since.
+func (c *Client) ReleaseCounter(ctx context.Context, wsID, tableID string, last, to int64) error {
+	switch {
+	case wsID == "":
+		return errors.New("workspace id is required")
+	case tableID == "":
+		return errors.New("table id is required")
+	}
+
+	key := datastoresdk.NameKey(counterKey, tableID, nil)
+
+	_, err := c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
+		var cnt autocounter.Counter
+		err := tx.Get(key, &cnt)
+		switch {
+		case err == datastoresdk.ErrNoSuchEntity:
+			return autocounter.ErrNoResults
+		case err != nil:
+			return err
+		case cnt.WorkspaceID != wsID:
+			return autocounter.ErrNoResults
+		}
+
+		cnt, err = cnt.Release(last, to)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Put(key, &cnt)
+		return err
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
+	return err
+}
+
+// AcquireLease within a transaction.
+func (c *Client) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (autocounter.Lease, error) {
+	if name == "" {
+		return autocounter.Lease{}, errors.New("name is required")
+	}
+
+	key := datastoresdk.NameKey(leaseKey, name, nil)
+
+	var res autocounter.Lease
+	_, err := c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
+		l := autocounter.Lease{Name: name}
+		if err := tx.Get(key, &l); err != nil && err != datastoresdk.ErrNoSuchEntity {
+			return err
+		}
+
+		l, err := l.Acquire(holder, ttl, time.Now())
+		if err != nil {
+			return err
+		}
+		res = l
+
+		_, err = tx.Put(key, &l)
+		return err
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
+	if err != nil {
+		return autocounter.Lease{}, err
+	}
+
+	return res, nil
+}
+
+// ReleaseLease within a transaction.
+func (c *Client) ReleaseLease(ctx context.Context, name, holder string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+
+	key := datastoresdk.NameKey(leaseKey, name, nil)
+
+	_, err := c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
+		var l autocounter.Lease
+		err := tx.Get(key, &l)
+		switch {
+		case err == datastoresdk.ErrNoSuchEntity:
+			return nil
+		case err != nil:
+			return err
+		case l.Holder != holder:
+			return nil
+		}
+
+		return tx.Delete(key)
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
+	return err
+}
+
+// ReserveRate within a transaction.
+func (c *Client) ReserveRate(ctx context.Context, key string, interval time.Duration, burst int) (time.Time, error) {
+	if key == "" {
+		return time.Time{}, errors.New("key is required")
+	}
+
+	dsKey := datastoresdk.NameKey(rateKey, key, nil)
+
+	var at time.Time
+	_, err := c.ds.RunInTransaction(ctx, func(tx *datastoresdk.Transaction) error {
+		r := autocounter.RateLimit{Key: key}
+		if err := tx.Get(dsKey, &r); err != nil && err != datastoresdk.ErrNoSuchEntity {
+			return err
+		}
+
+		r, at = r.Reserve(interval, burst, time.Now())
+
+		_, err := tx.Put(dsKey, &r)
+		return err
+	}, datastoresdk.MaxAttempts(defaultReserveAttempts))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return at, nil
 }
@@ -0,0 +1,32 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/notionplusid/core/app/storage"
+	"github.com/notionplusid/core/app/storage/storagetest"
+)
+
+// TestClient runs against the Datastore emulator available at DATASTORE_EMULATOR_HOST, e.g.
+//
+//	gcloud beta emulators datastore start --no-store-on-disk --consistency=1.0
+func TestClient(t *testing.T) {
+	if os.Getenv("DATASTORE_EMULATOR_HOST") == "" {
+		t.Skip("DATASTORE_EMULATOR_HOST is not set")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		// every test gets its own project, so the data never overlaps.
+		c, err := New(context.Background(), fmt.Sprintf("storagetest-%d", time.Now().UnixNano()))
+		require.NoError(t, err)
+		t.Cleanup(func() { c.ds.Close() })
+
+		return c
+	})
+}
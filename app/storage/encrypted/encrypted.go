@@ -0,0 +1,105 @@
+// Package encrypted wraps a storage implementation so the workspace tokens are encrypted at rest.
+//
+// The tokens are never decrypted by the storage:
+// the workspaces are returned with the encrypted tokens which are decrypted only by the Notion API client.
+package encrypted
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/envelope"
+	"github.com/notionplusid/core/app/storage"
+)
+
+// Instance wraps a specific storage implementation
+// and encrypts the workspace tokens before they are written.
+type Instance struct {
+	storage.Storage
+	e *envelope.Envelope
+}
+
+var _ storage.Storage = (*Instance)(nil)
+
+// New Instance constructor.
+func New(s storage.Storage, e *envelope.Envelope) (*Instance, error) {
+	switch {
+	case s == nil:
+		return nil, errors.New("storage instance wasn't provided: nothing to wrap")
+	case e == nil:
+		return nil, errors.New("envelope is required")
+	}
+
+	return &Instance{
+		Storage: s,
+		e:       e,
+	}, nil
+}
+
+// StoreWorkspace with the token encrypted.
+// Tokens that are already encrypted are stored as is.
+func (i *Instance) StoreWorkspace(ctx context.Context, ws autocounter.Workspace) (autocounter.Workspace, error) {
+	if !envelope.IsEncrypted(ws.Token) {
+		token, err := i.e.Encrypt(ctx, ws.Token)
+		if err != nil {
+			return autocounter.Workspace{}, fmt.Errorf("couldn't encrypt the token: %w", err)
+		}
+		ws.Token = token
+	}
+
+	return i.Storage.StoreWorkspace(ctx, ws)
+}
+
+// Rotate re-encrypts the tokens that are stored in plaintext or encrypted with the key that isn't current.
+// Returns the amount of the re-encrypted workspaces.
+func (i *Instance) Rotate(ctx context.Context) (int, error) {
+	wss, err := i.Storage.Workspaces(ctx)
+	switch {
+	case err == autocounter.ErrNoResults:
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+
+	var count int
+	for _, ws := range wss {
+		if !i.e.NeedsRotation(ws.Token) {
+			continue
+		}
+
+		token, err := i.e.Decrypt(ctx, ws.Token)
+		if err != nil {
+			return count, fmt.Errorf("workspace %s: couldn't decrypt the token: %w", ws.ID, err)
+		}
+		if ws.Token, err = i.e.Encrypt(ctx, token); err != nil {
+			return count, fmt.Errorf("workspace %s: couldn't encrypt the token: %w", ws.ID, err)
+		}
+		if _, err := i.Storage.StoreWorkspace(ctx, ws); err != nil {
+			return count, fmt.Errorf("workspace %s: couldn't store the token: %w", ws.ID, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// HasEncrypted returns true in case if any of the stored workspaces has the encrypted token.
+func HasEncrypted(ctx context.Context, s storage.Storage) (bool, error) {
+	wss, err := s.Workspaces(ctx)
+	switch {
+	case err == autocounter.ErrNoResults:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	for _, ws := range wss {
+		if envelope.IsEncrypted(ws.Token) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
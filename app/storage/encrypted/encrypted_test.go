@@ -0,0 +1,107 @@
+package encrypted
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/envelope"
+	"github.com/notionplusid/core/app/provider/notion"
+	"github.com/notionplusid/core/app/provider/notion/notiontest"
+	"github.com/notionplusid/core/app/storage/bolt"
+)
+
+func newEnvelope(t *testing.T, keys ...[]byte) *envelope.Envelope {
+	kp, err := envelope.NewLocalKeyProvider(keys[0], keys[1:]...)
+	require.NoError(t, err)
+	e, err := envelope.New(kp)
+	require.NoError(t, err)
+
+	return e
+}
+
+func TestInstance(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+
+	s, err := bolt.New(filepath.Join(t.TempDir(), "plusid.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// the workspace stored before the encryption was enabled.
+	legacy, err := autocounter.NewWorkspace("legacy", "legacy_token")
+	require.NoError(t, err)
+	_, err = s.StoreWorkspace(ctx, legacy)
+	require.NoError(t, err)
+
+	has, err := HasEncrypted(ctx, s)
+	require.NoError(t, err)
+	require.False(t, has)
+
+	oldE := newEnvelope(t, oldKey)
+	i, err := New(s, oldE)
+	require.NoError(t, err)
+
+	ws, err := autocounter.NewWorkspace("ws", "secret_token")
+	require.NoError(t, err)
+	_, err = i.StoreWorkspace(ctx, ws)
+	require.NoError(t, err)
+
+	stored, err := s.Workspace(ctx, "ws")
+	require.NoError(t, err)
+	require.True(t, envelope.IsEncrypted(stored.Token))
+
+	has, err = HasEncrypted(ctx, s)
+	require.NoError(t, err)
+	require.True(t, has)
+
+	t.Run("rotates the keys", func(t *testing.T) {
+		newE := newEnvelope(t, newKey, oldKey)
+		i, err := New(s, newE)
+		require.NoError(t, err)
+
+		n, err := i.Rotate(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 2, n)
+
+		n, err = i.Rotate(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, n)
+
+		// the old key isn't needed anymore.
+		newOnly := newEnvelope(t, newKey)
+		for id, token := range map[string]string{"ws": "secret_token", "legacy": "legacy_token"} {
+			stored, err := s.Workspace(ctx, id)
+			require.NoError(t, err)
+			require.False(t, newOnly.NeedsRotation(stored.Token))
+
+			v, err := newOnly.Decrypt(ctx, stored.Token)
+			require.NoError(t, err)
+			require.Equal(t, token, v)
+		}
+	})
+
+	t.Run("is decrypted by the notion client", func(t *testing.T) {
+		stored, err := s.Workspace(ctx, "ws")
+		require.NoError(t, err)
+
+		// the encrypted token is never accepted by Notion.
+		srv := notiontest.NewServer()
+		defer srv.Close()
+		srv.Revoke(stored.Token)
+
+		n, err := notion.NewFromWorkspace(ctx, stored,
+			notion.WithAPIURL(srv.URL()),
+			notion.WithClient(srv.Client()),
+			notion.WithTokenDecrypter(newEnvelope(t, newKey)),
+		)
+		require.NoError(t, err)
+		_, err = n.Me(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, srv.Requests("GET", "/v1/users/me"))
+	})
+}
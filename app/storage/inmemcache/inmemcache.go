@@ -4,20 +4,26 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
 	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/internal/logging"
+	"github.com/notionplusid/core/app/internal/metrics"
 	"github.com/notionplusid/core/app/storage"
 )
 
 const defaultCacheSyncTimeout = 5 * time.Minute
 
+// cache of the workspaces.
+// The tables aren't cached: they are configured and filled by any instance, so they're always read from the storage.
 type cache struct {
 	wss []autocounter.Workspace
-	ts  []autocounter.Table
 	mu  *sync.RWMutex
+
+	// synced is the time of the latest successful sync with the storage.
+	synced time.Time
 }
 
 // Instance wraps a specific storage implementation
@@ -48,6 +54,13 @@ func New(s storage.Storage) (*Instance, error) {
 
 // Sync all the Workspaces.
 func (i *Instance) Sync(ctx context.Context) error {
+	err := i.sync(ctx)
+	metrics.CacheSyncs.WithLabelValues(metrics.Result(err)).Inc()
+
+	return err
+}
+
+func (i *Instance) sync(ctx context.Context) error {
 	i.c.mu.Lock()
 	defer i.c.mu.Unlock()
 
@@ -56,12 +69,7 @@ func (i *Instance) Sync(ctx context.Context) error {
 		return err
 	}
 	i.c.wss = wss
-
-	ts, err := i.s.Tables(ctx)
-	if err != nil {
-		return err
-	}
-	i.c.ts = ts
+	i.c.synced = time.Now()
 
 	return nil
 }
@@ -92,12 +100,14 @@ func (i *Instance) Workspaces(ctx context.Context) ([]autocounter.Workspace, err
 	return append([]autocounter.Workspace{}, i.c.wss...), nil
 }
 
-// Tables values from the cache.
+// Tables are always read from the storage.
 func (i *Instance) Tables(ctx context.Context) ([]autocounter.Table, error) {
-	i.c.mu.RLock()
-	defer i.c.mu.RUnlock()
+	return i.s.Tables(ctx)
+}
 
-	return append([]autocounter.Table{}, i.c.ts...), nil
+// Table is always read from the storage.
+func (i *Instance) Table(ctx context.Context, wsID, tableID string) (autocounter.Table, error) {
+	return i.s.Table(ctx, wsID, tableID)
 }
 
 // StoreWorkspace writes the value to the storage first and then also duplicates it into the memory cache.
@@ -123,30 +133,50 @@ func (i *Instance) StoreWorkspace(ctx context.Context, ws autocounter.Workspace)
 	return ws, nil
 }
 
-// ProcOldestUpdatedWss works mostly with in-memory values
-// and once a minute processes the values from the database
-// while updating the cache.
+// ProcOldestUpdatedWss claims the due workspaces within the storage
+// as the claims have to be seen by all the instances,
+// and updates the cache with the processed ones.
+// Once in a while it also syncs the whole cache with the storage.
 func (i *Instance) ProcOldestUpdatedWss(ctx context.Context, count int64, procWss storage.ProcWssFunc) error {
-	// if cache has some old items instances - we run proper sync until we have cache in the most recent state.
-	if i.c.oldestProcessedWs().Add(defaultCacheSyncTimeout).Before(time.Now()) {
-		defer i.Sync(ctx) // nolint: errcheck
-		return i.s.ProcOldestUpdatedWss(ctx, count, func(ctx context.Context, wss ...autocounter.Workspace) error {
-			defer func() {
-				for _, ws := range wss {
-					if err := i.c.updateWs(ws); err != nil {
-						log.Printf("In-mem cache: couldn't update workspace %s: %s", ws.ID, err)
-					}
+	if i.c.syncedAt().Add(defaultCacheSyncTimeout).Before(time.Now()) {
+		defer func() {
+			if err := i.Sync(ctx); err != nil {
+				slog.ErrorContext(ctx, "couldn't sync the in-mem cache", logging.Err(err))
+			}
+		}()
+	}
+
+	return i.s.ProcOldestUpdatedWss(ctx, count, func(ctx context.Context, wss ...autocounter.Workspace) error {
+		defer func() {
+			for _, ws := range wss {
+				if err := i.c.updateWs(ws); err != nil {
+					slog.ErrorContext(logging.WithWorkspace(ctx, ws.ID), "couldn't update the cached workspace", logging.Err(err))
 				}
-			}()
-			return procWss(ctx, wss...)
-		})
+			}
+		}()
+		return procWss(ctx, wss...)
+	})
+}
+
+// StoreWorkspaceSchedule into the database and the cache.
+// The cached schedule is informational only as the other instances keep scheduling the workspace too:
+// the due workspaces are always picked by the storage.
+func (i *Instance) StoreWorkspaceSchedule(ctx context.Context, wsID string, nextRunAt time.Time, pass autocounter.Pass) error {
+	if err := i.s.StoreWorkspaceSchedule(ctx, wsID, nextRunAt, pass); err != nil {
+		return err
 	}
 
-	i.c.mu.RLock()
-	wss := append([]autocounter.Workspace{}, i.c.wss...)
-	i.c.mu.RUnlock()
+	i.c.mu.Lock()
+	defer i.c.mu.Unlock()
 
-	return procWss(ctx, wss...)
+	for n, item := range i.c.wss {
+		if item.ID == wsID {
+			i.c.wss[n].NextRunAt = nextRunAt
+			i.c.wss[n].LastPass = pass
+		}
+	}
+
+	return nil
 }
 
 func (c *cache) updateWs(ws autocounter.Workspace) error {
@@ -158,7 +188,9 @@ func (c *cache) updateWs(ws autocounter.Workspace) error {
 			continue
 		}
 
+		// the schedule of the workspace is stored by the processing itself.
 		ws.ProcessedAt = time.Now()
+		ws.NextRunAt, ws.LastPass = item.NextRunAt, item.LastPass
 		c.wss[i] = ws
 		return nil
 	}
@@ -166,40 +198,11 @@ func (c *cache) updateWs(ws autocounter.Workspace) error {
 	return fmt.Errorf("no cached workspace with id %s", ws.ID)
 }
 
-func (c *cache) updateTable(t autocounter.Table) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	for i, item := range c.ts {
-		if item.ID != t.ID && item.WorkspaceID != t.WorkspaceID {
-			continue
-		}
-
-		c.ts[i] = t
-		return nil
-	}
-
-	return fmt.Errorf("no cached table with id %s", t.ID)
-}
-
-func (c *cache) oldestProcessedWs() time.Time {
+func (c *cache) syncedAt() time.Time {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if len(c.wss) == 0 {
-		return time.Time{}
-	}
-
-	date := c.wss[0].ProcessedAt
-	for _, ws := range c.wss {
-		if ws.ProcessedAt.After(date) {
-			continue
-		}
-
-		date = ws.ProcessedAt
-	}
-
-	return date
+	return c.synced
 }
 
 func (c *cache) removeWs(wsID string) {
@@ -221,109 +224,62 @@ func (i *Instance) RemoveWorkspace(ctx context.Context, wsID string) error {
 	return i.s.RemoveWorkspace(ctx, wsID)
 }
 
-// StoreTable into the cache and the database.
+// StoreTable into the storage.
 func (i *Instance) StoreTable(ctx context.Context, workspaceID string, table autocounter.Table) (autocounter.Table, error) {
-	table, err := i.s.StoreTable(ctx, workspaceID, table)
-	if err != nil {
-		return autocounter.Table{}, err
-	}
-
-	i.c.mu.Lock()
-	defer i.c.mu.Unlock()
-
-	// find and override the workspace.
-	for n, item := range i.c.ts {
-		if item.ID == table.ID && item.WorkspaceID == table.WorkspaceID {
-			i.c.ts[n] = table
-			return table, nil
-		}
-	}
-	// if doesn't exist yet - save it to cache.
-	i.c.ts = append(i.c.ts, table)
-
-	return table, nil
+	return i.s.StoreTable(ctx, workspaceID, table)
 }
 
+// DisableTable within the storage.
 func (i *Instance) DisableTable(ctx context.Context, wsID, tableID string) (autocounter.Table, error) {
-	t, err := i.s.DisableTable(ctx, wsID, tableID)
-	if err != nil {
-		return autocounter.Table{}, err
-	}
-
-	return t, i.c.updateTable(t)
+	return i.s.DisableTable(ctx, wsID, tableID)
 }
 
+// ActiveTables are always read from the storage.
 func (i *Instance) ActiveTables(ctx context.Context, workspaceID string, tableIDs []string) ([]string, error) {
-	ids := map[string]struct{}{}
-	for _, id := range tableIDs {
-		ids[id] = struct{}{}
-	}
-
-	i.c.mu.RLock()
-	defer i.c.mu.RUnlock()
-
-	var activeIDs []string
-	for _, t := range i.c.ts {
-		// in case if such ID is registered with another workspace.
-		if t.WorkspaceID != workspaceID {
-			continue
-		}
-
-		if t.Status != autocounter.StatusActive {
-			continue
-		}
-
-		_, ok := ids[t.ID]
-		if !ok {
-			continue
-		}
-
-		activeIDs = append(activeIDs, t.ID)
-	}
-
-	return activeIDs, nil
+	return i.s.ActiveTables(ctx, workspaceID, tableIDs)
 }
 
+// ListAllActiveTables are always read from the storage.
 func (i *Instance) ListAllActiveTables(ctx context.Context, workspaceID string) ([]autocounter.Table, error) {
-	i.c.mu.RLock()
-	defer i.c.mu.RUnlock()
+	return i.s.ListAllActiveTables(ctx, workspaceID)
+}
 
-	var activeTables []autocounter.Table
-	for _, t := range i.c.ts {
-		// in case if such ID is registered with another workspace.
-		if t.WorkspaceID != workspaceID {
-			continue
-		}
+// RemoveTablesFromWS within the storage.
+func (i *Instance) RemoveTablesFromWS(ctx context.Context, wsID string) error {
+	return i.s.RemoveTablesFromWS(ctx, wsID)
+}
 
-		if t.Status != autocounter.StatusActive {
-			continue
-		}
+// StoreTableWatermark into the storage.
+func (i *Instance) StoreTableWatermark(ctx context.Context, wsID, tableID string, w autocounter.Watermark) error {
+	return i.s.StoreTableWatermark(ctx, wsID, tableID, w)
+}
 
-		tl := t
-		activeTables = append(activeTables, tl)
-	}
+// Counter is always read from the storage as it has to be consistent across the instances.
+func (i *Instance) Counter(ctx context.Context, wsID, tableID string) (autocounter.Counter, error) {
+	return i.s.Counter(ctx, wsID, tableID)
+}
 
-	return activeTables, nil
+// ReserveCounter is always done within the storage as it has to be atomic across the instances.
+func (i *Instance) ReserveCounter(ctx context.Context, wsID, tableID string, r autocounter.Reservation) ([]int64, error) {
+	return i.s.ReserveCounter(ctx, wsID, tableID, r)
 }
 
-func (i *Instance) RemoveTablesFromWS(ctx context.Context, wsID string) error {
-	err := i.s.RemoveTablesFromWS(ctx, wsID)
-	if err != nil {
-		return err
-	}
+// ReleaseCounter is always done within the storage as it has to be atomic across the instances.
+func (i *Instance) ReleaseCounter(ctx context.Context, wsID, tableID string, last, to int64) error {
+	return i.s.ReleaseCounter(ctx, wsID, tableID, last, to)
+}
 
-	i.c.mu.Lock()
-	defer i.c.mu.Unlock()
+// AcquireLease is always done within the storage as the leases are shared by the instances.
+func (i *Instance) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (autocounter.Lease, error) {
+	return i.s.AcquireLease(ctx, name, holder, ttl)
+}
 
-	var ts []autocounter.Table
-	for _, t := range i.c.ts {
-		if t.WorkspaceID == wsID {
-			continue
-		}
+// ReleaseLease is always done within the storage as the leases are shared by the instances.
+func (i *Instance) ReleaseLease(ctx context.Context, name, holder string) error {
+	return i.s.ReleaseLease(ctx, name, holder)
+}
 
-		tl := t
-		ts = append(ts, tl)
-	}
-	i.c.ts = ts
-	return nil
+// ReserveRate is always done within the storage as the rate limits are shared by the instances.
+func (i *Instance) ReserveRate(ctx context.Context, key string, interval time.Duration, burst int) (time.Time, error) {
+	return i.s.ReserveRate(ctx, key, interval, burst)
 }
@@ -45,27 +45,6 @@ func TestClient(t *testing.T) {
 
 			s.On("Workspaces", ctx).Return(mws, nil)
 
-			mt := []autocounter.Table{
-				{
-					ID:          "1",
-					WorkspaceID: "1",
-					Status:      autocounter.StatusActive,
-					ParamName:   "PlusID",
-					CreatedAt:   now,
-					UpdatedAt:   now,
-				},
-				{
-					ID:          "2",
-					WorkspaceID: "2",
-					Status:      autocounter.StatusActive,
-					ParamName:   "PlusID",
-					CreatedAt:   now,
-					UpdatedAt:   now,
-				},
-			}
-
-			s.On("Tables", ctx).Return(mt, nil)
-
 			t.Log("starting with sync")
 			err := client.Sync(ctx)
 			assert.NoError(t, err)
@@ -96,5 +75,20 @@ func TestClient(t *testing.T) {
 			assert.NoError(t, err)
 			assert.ElementsMatch(t, wss, append(mws, storedWs))
 		})
+
+		t.Run("reads tables from the storage", func(t *testing.T) {
+			ctx := context.TODO()
+
+			// the table might be configured by another instance at any time.
+			for _, status := range []autocounter.Status{autocounter.StatusActive, autocounter.StatusPaused} {
+				table := autocounter.Table{ID: "1", WorkspaceID: "1", Status: status, ParamName: "PlusID"}
+				s.On("Table", ctx, "1", "1").Return(table, nil).Once()
+
+				got, err := client.Table(ctx, "1", "1")
+				assert.NoError(t, err)
+				assert.Equal(t, table, got)
+			}
+			s.AssertExpectations(t)
+		})
 	})
 }
@@ -0,0 +1,648 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/storage"
+)
+
+const (
+	workspaceColumns = `id, token, name, icon_url, bot_id, duplicated_template_id, owner_type, owner_user_id, owner_user_name, owner_user_email, next_run_at, last_pass_numbered, last_pass_registered, last_pass_error, idle_passes, failed_passes, processed_at, created_at, updated_at`
+	tableColumns     = `id, workspace_id, status, param_name, param_type, prefix, separator, pad_width, start_value, step, gap_free, group_by, reset, date_param, time_zone, format, last_seen_at, full_scan_at, holes, created_at, updated_at`
+	counterColumns   = `table_id, workspace_id, value, updated_at`
+)
+
+// Client for PostgreSQL.
+type Client struct {
+	db *sql.DB
+}
+
+// Make sure PostgreSQL client is compatible.
+var _ storage.Storage = (*Client)(nil)
+
+// New PostgreSQL Client constructor.
+// The database schema is migrated to the latest version.
+func New(ctx context.Context, dsn string) (*Client, error) {
+	if dsn == "" {
+		return nil, errors.New("dsn is required")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	c := &Client{db: db}
+	if err := c.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("couldn't migrate: %w", err)
+	}
+
+	return c, nil
+}
+
+// Close the Client.
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWorkspace(s scanner) (autocounter.Workspace, error) {
+	var ws autocounter.Workspace
+	err := s.Scan(
+		&ws.ID, &ws.Token,
+		&ws.Name, &ws.IconURL, &ws.BotID, &ws.DuplicatedTemplateID,
+		&ws.Owner.Type, &ws.Owner.UserID, &ws.Owner.UserName, &ws.Owner.UserEmail,
+		&ws.NextRunAt, &ws.LastPass.Numbered, &ws.LastPass.Registered, &ws.LastPass.Error, &ws.LastPass.Idle, &ws.LastPass.Failures,
+		&ws.ProcessedAt, &ws.CreatedAt, &ws.UpdatedAt,
+	)
+	return ws, err
+}
+
+func scanTable(s scanner) (autocounter.Table, error) {
+	var (
+		t     autocounter.Table
+		holes pq.Int64Array
+	)
+	err := s.Scan(
+		&t.ID, &t.WorkspaceID, &t.Status,
+		&t.ParamName, &t.ParamType, &t.Prefix, &t.Separator, &t.PadWidth, &t.StartValue, &t.Step, &t.GapFree, &t.GroupBy,
+		&t.Reset, &t.DateParam, &t.TimeZone, &t.Format,
+		&t.Watermark.LastSeenAt, &t.Watermark.FullScanAt, &holes,
+		&t.CreatedAt, &t.UpdatedAt,
+	)
+	if len(holes) != 0 {
+		t.Watermark.Holes = holes
+	}
+	return t, err
+}
+
+// holesArray returns the holes as the array column value, the column is never null.
+func holesArray(holes []int64) pq.Int64Array {
+	if holes == nil {
+		return pq.Int64Array{}
+	}
+
+	return holes
+}
+
+func (c *Client) queryWorkspaces(ctx context.Context, q string, args ...interface{}) ([]autocounter.Workspace, error) {
+	rows, err := c.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []autocounter.Workspace
+	for rows.Next() {
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ws)
+	}
+
+	return res, rows.Err()
+}
+
+func (c *Client) queryTables(ctx context.Context, q string, args ...interface{}) ([]autocounter.Table, error) {
+	rows, err := c.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []autocounter.Table
+	for rows.Next() {
+		t, err := scanTable(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+
+	return res, rows.Err()
+}
+
+// Workspace returns the instance by the requested ID.
+func (c *Client) Workspace(ctx context.Context, id string) (autocounter.Workspace, error) {
+	if id == "" {
+		return autocounter.Workspace{}, errors.New("id is required")
+	}
+
+	ws, err := scanWorkspace(c.db.QueryRowContext(ctx, `SELECT `+workspaceColumns+` FROM workspaces WHERE id = $1`, id))
+	switch {
+	case err == sql.ErrNoRows:
+		return autocounter.Workspace{}, autocounter.ErrNoResults
+	case err != nil:
+		return autocounter.Workspace{}, err
+	}
+
+	return ws, nil
+}
+
+// Workspaces returns all the available instances.
+func (c *Client) Workspaces(ctx context.Context) ([]autocounter.Workspace, error) {
+	return c.queryWorkspaces(ctx, `SELECT `+workspaceColumns+` FROM workspaces`)
+}
+
+// StoreWorkspace for future usage along with the access token.
+func (c *Client) StoreWorkspace(ctx context.Context, ws autocounter.Workspace) (autocounter.Workspace, error) {
+	if err := ws.Validate(); err != nil {
+		return autocounter.Workspace{}, err
+	}
+
+	now := time.Now()
+	if ws.CreatedAt.IsZero() {
+		ws.CreatedAt = now
+	}
+	ws.UpdatedAt = now
+
+	_, err := c.db.ExecContext(ctx, `
+		INSERT INTO workspaces (`+workspaceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		ON CONFLICT (id) DO UPDATE SET
+			token = EXCLUDED.token,
+			name = EXCLUDED.name,
+			icon_url = EXCLUDED.icon_url,
+			bot_id = EXCLUDED.bot_id,
+			duplicated_template_id = EXCLUDED.duplicated_template_id,
+			owner_type = EXCLUDED.owner_type,
+			owner_user_id = EXCLUDED.owner_user_id,
+			owner_user_name = EXCLUDED.owner_user_name,
+			owner_user_email = EXCLUDED.owner_user_email,
+			next_run_at = EXCLUDED.next_run_at,
+			last_pass_numbered = EXCLUDED.last_pass_numbered,
+			last_pass_registered = EXCLUDED.last_pass_registered,
+			last_pass_error = EXCLUDED.last_pass_error,
+			idle_passes = EXCLUDED.idle_passes,
+			failed_passes = EXCLUDED.failed_passes,
+			processed_at = EXCLUDED.processed_at,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at`,
+		ws.ID, ws.Token,
+		ws.Name, ws.IconURL, ws.BotID, ws.DuplicatedTemplateID,
+		ws.Owner.Type, ws.Owner.UserID, ws.Owner.UserName, ws.Owner.UserEmail,
+		ws.NextRunAt, ws.LastPass.Numbered, ws.LastPass.Registered, ws.LastPass.Error, ws.LastPass.Idle, ws.LastPass.Failures,
+		ws.ProcessedAt, ws.CreatedAt, ws.UpdatedAt,
+	)
+	if err != nil {
+		return autocounter.Workspace{}, fmt.Errorf("couldn't upsert the workspace: %s", err)
+	}
+
+	return ws, nil
+}
+
+// ProcOldestUpdatedWss fetches the amount of workspaces that are due to be processed and applies the `procWss`.
+// The workspaces are claimed within a transaction by moving their next run forward by storage.ClaimTTL,
+// so they aren't due again till the processing schedules their next run, while the ones that are being claimed by other instances are skipped.
+// The claim is released before the processing, so `procWss` is free to modify or remove the workspaces.
+func (c *Client) ProcOldestUpdatedWss(ctx context.Context, count int64, procWss storage.ProcWssFunc) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	now := time.Now()
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+workspaceColumns+` FROM workspaces
+		WHERE next_run_at <= $2
+		ORDER BY next_run_at, processed_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, count, now)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wss []autocounter.Workspace
+		ids []string
+	)
+	for rows.Next() {
+		ws, err := scanWorkspace(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		wss = append(wss, ws)
+		ids = append(ids, ws.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// claim the workspaces.
+	if _, err := tx.ExecContext(ctx, `UPDATE workspaces SET processed_at = $1, next_run_at = $2 WHERE id = ANY($3)`,
+		now, now.Add(storage.ClaimTTL), pq.Array(ids)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// process every workspace.
+	if err := procWss(ctx, wss...); err != nil {
+		return err
+	}
+
+	// update the processed timestamp of the workspaces that are still there.
+	_, err = c.db.ExecContext(ctx, `UPDATE workspaces SET processed_at = $1 WHERE id = ANY($2)`, time.Now(), pq.Array(ids))
+	return err
+}
+
+// StoreWorkspaceSchedule with a single update.
+func (c *Client) StoreWorkspaceSchedule(ctx context.Context, wsID string, nextRunAt time.Time, pass autocounter.Pass) error {
+	res, err := c.db.ExecContext(ctx, `
+		UPDATE workspaces SET
+			next_run_at = $2,
+			last_pass_numbered = $3,
+			last_pass_registered = $4,
+			last_pass_error = $5,
+			idle_passes = $6,
+			failed_passes = $7
+		WHERE id = $1`,
+		wsID, nextRunAt, pass.Numbered, pass.Registered, pass.Error, pass.Idle, pass.Failures,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return autocounter.ErrNoResults
+	}
+
+	return nil
+}
+
+// RemoveWorkspace for the Client.
+func (c *Client) RemoveWorkspace(ctx context.Context, wsID string) error {
+	if wsID == "" {
+		return errors.New("workspace id is required")
+	}
+
+	_, err := c.db.ExecContext(ctx, `DELETE FROM workspaces WHERE id = $1`, wsID)
+	return err
+}
+
+// Tables returns all the available instances.
+func (c *Client) Tables(ctx context.Context) ([]autocounter.Table, error) {
+	return c.queryTables(ctx, `SELECT `+tableColumns+` FROM tables`)
+}
+
+// Table returns the instance by the requested ID within the workspace.
+func (c *Client) Table(ctx context.Context, wsID, tableID string) (autocounter.Table, error) {
+	switch {
+	case wsID == "":
+		return autocounter.Table{}, errors.New("workspace id is required")
+	case tableID == "":
+		return autocounter.Table{}, errors.New("table id is required")
+	}
+
+	t, err := scanTable(c.db.QueryRowContext(ctx, `SELECT `+tableColumns+` FROM tables WHERE id = $1 AND workspace_id = $2`, tableID, wsID))
+	switch {
+	case err == sql.ErrNoRows:
+		return autocounter.Table{}, autocounter.ErrNoResults
+	case err != nil:
+		return autocounter.Table{}, err
+	}
+
+	return t, nil
+}
+
+// StoreTable instance.
+func (c *Client) StoreTable(ctx context.Context, workspaceID string, table autocounter.Table) (autocounter.Table, error) {
+	table.WorkspaceID = workspaceID
+	if err := table.Validate(); err != nil {
+		return autocounter.Table{}, err
+	}
+
+	now := time.Now()
+	table.CreatedAt = now
+	table.UpdatedAt = now
+
+	_, err := c.db.ExecContext(ctx, `
+		INSERT INTO tables (`+tableColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		ON CONFLICT (id) DO UPDATE SET
+			workspace_id = EXCLUDED.workspace_id,
+			status = EXCLUDED.status,
+			param_name = EXCLUDED.param_name,
+			param_type = EXCLUDED.param_type,
+			prefix = EXCLUDED.prefix,
+			separator = EXCLUDED.separator,
+			pad_width = EXCLUDED.pad_width,
+			start_value = EXCLUDED.start_value,
+			step = EXCLUDED.step,
+			gap_free = EXCLUDED.gap_free,
+			group_by = EXCLUDED.group_by,
+			reset = EXCLUDED.reset,
+			date_param = EXCLUDED.date_param,
+			time_zone = EXCLUDED.time_zone,
+			format = EXCLUDED.format,
+			last_seen_at = EXCLUDED.last_seen_at,
+			full_scan_at = EXCLUDED.full_scan_at,
+			holes = EXCLUDED.holes,
+			created_at = EXCLUDED.created_at,
+			updated_at = EXCLUDED.updated_at`,
+		table.ID, table.WorkspaceID, table.Status,
+		table.ParamName, table.ParamType, table.Prefix, table.Separator, table.PadWidth, table.StartValue, table.Step, table.GapFree, table.GroupBy,
+		table.Reset, table.DateParam, table.TimeZone, table.Format,
+		table.Watermark.LastSeenAt, table.Watermark.FullScanAt, holesArray(table.Watermark.Holes),
+		table.CreatedAt, table.UpdatedAt,
+	)
+	if err != nil {
+		return autocounter.Table{}, err
+	}
+
+	return table, nil
+}
+
+// DisableTable instance.
+func (c *Client) DisableTable(ctx context.Context, wsID, tableID string) (autocounter.Table, error) {
+	t, err := scanTable(c.db.QueryRowContext(ctx, `
+		UPDATE tables SET status = $1
+		WHERE id = $2 AND workspace_id = $3
+		RETURNING `+tableColumns,
+		autocounter.StatusDisabled, tableID, wsID,
+	))
+	switch {
+	case err == sql.ErrNoRows:
+		return autocounter.Table{}, autocounter.ErrNoResults
+	case err != nil:
+		return autocounter.Table{}, err
+	}
+
+	return t, nil
+}
+
+// StoreTableWatermark with a single update.
+func (c *Client) StoreTableWatermark(ctx context.Context, wsID, tableID string, w autocounter.Watermark) error {
+	res, err := c.db.ExecContext(ctx, `
+		UPDATE tables SET last_seen_at = $3, full_scan_at = $4, holes = $5
+		WHERE id = $1 AND workspace_id = $2`,
+		tableID, wsID, w.LastSeenAt, w.FullScanAt, holesArray(w.Holes),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return autocounter.ErrNoResults
+	}
+
+	return nil
+}
+
+// ActiveTables returns the subset of table IDs from the provided list that are active.
+func (c *Client) ActiveTables(ctx context.Context, workspaceID string, tableIDs []string) ([]string, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id FROM tables
+		WHERE workspace_id = $1 AND status = $2 AND id = ANY($3)`,
+		workspaceID, autocounter.StatusActive, pq.Array(tableIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ListAllActiveTables stored in the database for the workspace.
+func (c *Client) ListAllActiveTables(ctx context.Context, workspaceID string) ([]autocounter.Table, error) {
+	if workspaceID == "" {
+		return nil, errors.New("workspace id is required")
+	}
+
+	return c.queryTables(ctx, `SELECT `+tableColumns+` FROM tables WHERE workspace_id = $1 AND status = $2`, workspaceID, autocounter.StatusActive)
+}
+
+// RemoveTablesFromWS clears up all the tables associated with the workspace ID along with their counters.
+func (c *Client) RemoveTablesFromWS(ctx context.Context, wsID string) error {
+	if wsID == "" {
+		return errors.New("workspace id is required")
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tables WHERE workspace_id = $1`, wsID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM counters WHERE workspace_id = $1`, wsID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Counter returns the last issued value of the Table.
+func (c *Client) Counter(ctx context.Context, wsID, tableID string) (autocounter.Counter, error) {
+	switch {
+	case wsID == "":
+		return autocounter.Counter{}, errors.New("workspace id is required")
+	case tableID == "":
+		return autocounter.Counter{}, errors.New("table id is required")
+	}
+
+	var cnt autocounter.Counter
+	err := c.db.QueryRowContext(ctx, `SELECT `+counterColumns+` FROM counters WHERE table_id = $1 AND workspace_id = $2`, tableID, wsID).
+		Scan(&cnt.TableID, &cnt.WorkspaceID, &cnt.Value, &cnt.UpdatedAt)
+	switch {
+	case err == sql.ErrNoRows:
+		return autocounter.Counter{}, autocounter.ErrNoResults
+	case err != nil:
+		return autocounter.Counter{}, err
+	}
+
+	return cnt, nil
+}
+
+// ReserveCounter advances the counter of the Table with a single atomic upsert and returns the reserved values.
+func (c *Client) ReserveCounter(ctx context.Context, wsID, tableID string, r autocounter.Reservation) ([]int64, error) {
+	switch {
+	case wsID == "":
+		return nil, errors.New("workspace id is required")
+	case tableID == "":
+		return nil, errors.New("table id is required")
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	// the new counter starts right after the floor, the existing one is never moved backwards.
+	var last int64
+	err := c.db.QueryRowContext(ctx, `
+		INSERT INTO counters (`+counterColumns+`)
+		VALUES ($1, $2, $3::BIGINT + $4::BIGINT * $5::BIGINT, now())
+		ON CONFLICT (table_id) DO UPDATE SET
+			value = GREATEST(counters.value, $3::BIGINT) + $4::BIGINT * $5::BIGINT,
+			updated_at = now()
+		WHERE counters.workspace_id = $2
+		RETURNING value`,
+		tableID, wsID, r.Floor, r.Count, r.Step,
+	).Scan(&last)
+	switch {
+	case err == sql.ErrNoRows:
+		// in case if such ID is registered with another workspace.
+		return nil, autocounter.ErrNoResults
+	case err != nil:
+		return nil, err
+	}
+
+	return r.Values(last), nil
+}
+
+// ReleaseCounter moves the counter back with a single conditional update in case if it wasn't moved since.
+func (c *Client) ReleaseCounter(ctx context.Context, wsID, tableID string, last, to int64) error {
+	switch {
+	case wsID == "":
+		return errors.New("workspace id is required")
+	case tableID == "":
+		return errors.New("table id is required")
+	case to > last:
+		return errors.New("counter can't be released forward")
+	}
+
+	res, err := c.db.ExecContext(ctx, `
+		UPDATE counters SET value = $4, updated_at = now()
+		WHERE table_id = $1 AND workspace_id = $2 AND value = $3`,
+		tableID, wsID, last, to,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 0 {
+		return nil
+	}
+
+	// tell the missing counter apart from the moved one.
+	if _, err := c.Counter(ctx, wsID, tableID); err != nil {
+		return err
+	}
+	return autocounter.ErrCounterMoved
+}
+
+// AcquireLease within a transaction, the lease row is locked till the transaction ends.
+func (c *Client) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (autocounter.Lease, error) {
+	if name == "" {
+		return autocounter.Lease{}, errors.New("name is required")
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return autocounter.Lease{}, err
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	// make sure there's the row to lock.
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO leases (name, holder, expires_at) VALUES ($1, '', 'epoch')
+		ON CONFLICT (name) DO NOTHING`, name); err != nil {
+		return autocounter.Lease{}, err
+	}
+
+	l := autocounter.Lease{Name: name}
+	err = tx.QueryRowContext(ctx, `SELECT holder, expires_at FROM leases WHERE name = $1 FOR UPDATE`, name).
+		Scan(&l.Holder, &l.ExpiresAt)
+	if err != nil {
+		return autocounter.Lease{}, err
+	}
+
+	l, err = l.Acquire(holder, ttl, time.Now())
+	if err != nil {
+		return autocounter.Lease{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE leases SET holder = $2, expires_at = $3 WHERE name = $1`, name, l.Holder, l.ExpiresAt); err != nil {
+		return autocounter.Lease{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return autocounter.Lease{}, err
+	}
+
+	return l, nil
+}
+
+// ReleaseLease with a single conditional delete.
+func (c *Client) ReleaseLease(ctx context.Context, name, holder string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+
+	_, err := c.db.ExecContext(ctx, `DELETE FROM leases WHERE name = $1 AND holder = $2`, name, holder)
+	return err
+}
+
+// ReserveRate within a transaction, the rate limit row is locked till the transaction ends.
+func (c *Client) ReserveRate(ctx context.Context, key string, interval time.Duration, burst int) (time.Time, error) {
+	if key == "" {
+		return time.Time{}, errors.New("key is required")
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	// make sure there's the row to lock.
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO rate_limits (key, next_at) VALUES ($1, 'epoch')
+		ON CONFLICT (key) DO NOTHING`, key); err != nil {
+		return time.Time{}, err
+	}
+
+	r := autocounter.RateLimit{Key: key}
+	if err := tx.QueryRowContext(ctx, `SELECT next_at FROM rate_limits WHERE key = $1 FOR UPDATE`, key).Scan(&r.NextAt); err != nil {
+		return time.Time{}, err
+	}
+
+	r, at := r.Reserve(interval, burst, time.Now())
+	if _, err := tx.ExecContext(ctx, `UPDATE rate_limits SET next_at = $2 WHERE key = $1`, key, r.NextAt); err != nil {
+		return time.Time{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+
+	return at, nil
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/notionplusid/core/app/storage"
+	"github.com/notionplusid/core/app/storage/storagetest"
+)
+
+// testDatabase returns the DSN of the database provided by POSTGRES_TEST_DSN (or STORAGE_POSTGRES_TEST_DSN).
+func testDatabase(t *testing.T) string {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		dsn = os.Getenv("STORAGE_POSTGRES_TEST_DSN")
+	}
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	return dsn
+}
+
+// testDSN returns the DSN of the fresh schema within the database,
+// so every test migrates the schema from scratch. The schema is dropped once the test is over.
+func testDSN(t *testing.T, dsn string) string {
+	ctx := context.Background()
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	schema := fmt.Sprintf("plusid_test_%d", time.Now().UnixNano())
+	_, err = db.ExecContext(ctx, `CREATE SCHEMA `+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := db.ExecContext(ctx, `DROP SCHEMA `+schema+` CASCADE`)
+		require.NoError(t, err)
+	})
+
+	return withSearchPath(t, dsn, schema)
+}
+
+// withSearchPath adds the search_path run-time parameter to both the URL and the key/value DSNs.
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn + " search_path=" + schema
+	}
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// TestClient runs against the database provided by POSTGRES_TEST_DSN.
+func TestClient(t *testing.T) {
+	dsn := testDatabase(t)
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		c, err := New(context.Background(), testDSN(t, dsn))
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+
+		return c
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := New(ctx, testDSN(t, testDatabase(t)))
+	require.NoError(t, err)
+	defer c.Close()
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+
+	applied := func() []string {
+		rows, err := c.db.QueryContext(ctx, `SELECT version FROM schema_migrations ORDER BY version`)
+		require.NoError(t, err)
+		defer rows.Close()
+
+		var versions []string
+		for rows.Next() {
+			var v string
+			require.NoError(t, rows.Scan(&v))
+			versions = append(versions, v)
+		}
+		require.NoError(t, rows.Err())
+
+		return versions
+	}
+
+	var want []string
+	for _, name := range names {
+		want = append(want, strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql"))
+	}
+	require.Equal(t, want, applied(), "every migration is applied")
+
+	require.NoError(t, c.Migrate(ctx), "migrations are applied once")
+	require.Equal(t, want, applied())
+}
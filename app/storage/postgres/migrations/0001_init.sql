@@ -0,0 +1,35 @@
+CREATE TABLE workspaces (
+    id           TEXT PRIMARY KEY,
+    token        TEXT NOT NULL,
+    processed_at TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX workspaces_processed_at_idx ON workspaces (processed_at);
+
+CREATE TABLE tables (
+    id           TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL,
+    status       TEXT NOT NULL,
+    param_name   TEXT NOT NULL,
+    param_type   TEXT NOT NULL DEFAULT '',
+    prefix       TEXT NOT NULL DEFAULT '',
+    separator    TEXT NOT NULL DEFAULT '',
+    pad_width    INTEGER NOT NULL DEFAULT 0,
+    start_value  BIGINT NOT NULL DEFAULT 0,
+    step         BIGINT NOT NULL DEFAULT 0,
+    created_at   TIMESTAMPTZ NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX tables_workspace_id_status_idx ON tables (workspace_id, status);
+
+CREATE TABLE counters (
+    table_id     TEXT PRIMARY KEY,
+    workspace_id TEXT NOT NULL,
+    value        BIGINT NOT NULL,
+    updated_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX counters_workspace_id_idx ON counters (workspace_id);
@@ -0,0 +1,9 @@
+ALTER TABLE workspaces
+    ADD COLUMN name                   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN icon_url               TEXT NOT NULL DEFAULT '',
+    ADD COLUMN bot_id                 TEXT NOT NULL DEFAULT '',
+    ADD COLUMN duplicated_template_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN owner_type             TEXT NOT NULL DEFAULT '',
+    ADD COLUMN owner_user_id          TEXT NOT NULL DEFAULT '',
+    ADD COLUMN owner_user_name        TEXT NOT NULL DEFAULT '',
+    ADD COLUMN owner_user_email       TEXT NOT NULL DEFAULT '';
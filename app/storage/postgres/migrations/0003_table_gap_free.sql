@@ -0,0 +1,2 @@
+ALTER TABLE tables
+    ADD COLUMN gap_free BOOLEAN NOT NULL DEFAULT FALSE;
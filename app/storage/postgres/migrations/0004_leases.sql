@@ -0,0 +1,10 @@
+CREATE TABLE leases (
+    name       TEXT PRIMARY KEY,
+    holder     TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE rate_limits (
+    key     TEXT PRIMARY KEY,
+    next_at TIMESTAMPTZ NOT NULL
+);
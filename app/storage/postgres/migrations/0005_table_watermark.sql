@@ -0,0 +1,3 @@
+ALTER TABLE tables
+    ADD COLUMN last_seen_at TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+    ADD COLUMN full_scan_at TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00';
@@ -0,0 +1,9 @@
+ALTER TABLE workspaces
+    ADD COLUMN next_run_at          TIMESTAMPTZ NOT NULL DEFAULT '0001-01-01 00:00:00+00',
+    ADD COLUMN last_pass_numbered   BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN last_pass_registered INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_pass_error      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN idle_passes          INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN failed_passes        INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX workspaces_next_run_at_idx ON workspaces (next_run_at, processed_at);
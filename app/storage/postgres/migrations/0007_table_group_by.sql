@@ -0,0 +1,2 @@
+ALTER TABLE tables
+    ADD COLUMN group_by TEXT NOT NULL DEFAULT '';
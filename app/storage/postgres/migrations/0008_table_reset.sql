@@ -0,0 +1,5 @@
+ALTER TABLE tables
+    ADD COLUMN reset      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN date_param TEXT NOT NULL DEFAULT '',
+    ADD COLUMN time_zone  TEXT NOT NULL DEFAULT '',
+    ADD COLUMN format     TEXT NOT NULL DEFAULT '';
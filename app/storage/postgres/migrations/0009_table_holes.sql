@@ -0,0 +1,2 @@
+ALTER TABLE tables
+    ADD COLUMN holes BIGINT[] NOT NULL DEFAULT '{}';
@@ -2,10 +2,16 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	autocounter "github.com/notionplusid/core/app"
 )
 
+// ClaimTTL is the time the claimed workspaces aren't due for, so the same workspace isn't claimed twice
+// till the processing schedules its next run. It matches the workspace lease, so the workspaces
+// claimed by the crashed instance are picked up again once its leases expire.
+const ClaimTTL = time.Minute
+
 // ProcWssFunc is the processing handler of the set of workspaces returned from the database.
 type ProcWssFunc func(ctx context.Context, wss ...autocounter.Workspace) error
 
@@ -14,13 +20,35 @@ type Storage interface {
 	Workspace(ctx context.Context, id string) (autocounter.Workspace, error)
 	Workspaces(ctx context.Context) ([]autocounter.Workspace, error)
 	StoreWorkspace(ctx context.Context, ws autocounter.Workspace) (autocounter.Workspace, error)
+	// ProcOldestUpdatedWss applies the procWss to the amount of workspaces that are due to be processed, the most overdue first.
+	// The workspaces are claimed by moving their next run forward by ClaimTTL, the processing is expected to schedule the next one.
 	ProcOldestUpdatedWss(ctx context.Context, count int64, procWss ProcWssFunc) error
+	// StoreWorkspaceSchedule updates the next run and the latest pass of the workspace only, the rest of it is left intact.
+	StoreWorkspaceSchedule(ctx context.Context, wsID string, nextRunAt time.Time, pass autocounter.Pass) error
 	RemoveWorkspace(ctx context.Context, wsID string) error
 
 	Tables(ctx context.Context) ([]autocounter.Table, error)
+	Table(ctx context.Context, wsID, tableID string) (autocounter.Table, error)
 	StoreTable(ctx context.Context, workspaceID string, table autocounter.Table) (autocounter.Table, error)
 	DisableTable(ctx context.Context, wsID, tableID string) (autocounter.Table, error)
 	ActiveTables(ctx context.Context, workspaceID string, tableIDs []string) ([]string, error)
 	ListAllActiveTables(ctx context.Context, workspaceID string) ([]autocounter.Table, error)
 	RemoveTablesFromWS(ctx context.Context, wsID string) error
+	// StoreTableWatermark updates the watermark of the table only, the rest of the table is left intact.
+	StoreTableWatermark(ctx context.Context, wsID, tableID string, w autocounter.Watermark) error
+
+	Counter(ctx context.Context, wsID, tableID string) (autocounter.Counter, error)
+	ReserveCounter(ctx context.Context, wsID, tableID string, r autocounter.Reservation) ([]int64, error)
+	// ReleaseCounter moves the counter back to `to` in case if its last issued value is still `last`.
+	// Returns autocounter.ErrCounterMoved otherwise.
+	ReleaseCounter(ctx context.Context, wsID, tableID string, last, to int64) error
+
+	// AcquireLease claims the named lease for the holder till the ttl expires, the holder is free to prolong its own lease.
+	// Returns autocounter.ErrLeaseHeld in case if the lease is held by another holder.
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (autocounter.Lease, error)
+	// ReleaseLease held by the holder, the lease held by another holder is left intact.
+	ReleaseLease(ctx context.Context, name, holder string) error
+	// ReserveRate reserves the request of the rate limit with the provided key
+	// and returns the time the request is allowed to be sent at.
+	ReserveRate(ctx context.Context, key string, interval time.Duration, burst int) (time.Time, error)
 }
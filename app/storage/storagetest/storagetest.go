@@ -0,0 +1,595 @@
+// Package storagetest provides the conformance suite of the storage.Storage contract
+// that every storage implementation is expected to pass.
+package storagetest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	autocounter "github.com/notionplusid/core/app"
+	"github.com/notionplusid/core/app/storage"
+)
+
+// NewStorageFunc returns the empty storage instance for the test.
+type NewStorageFunc func(t *testing.T) storage.Storage
+
+// Run the conformance suite against the storage implementation.
+// Every test case gets its own storage instance returned by newStorage.
+func Run(t *testing.T, newStorage NewStorageFunc) {
+	t.Run("workspaces", func(t *testing.T) { testWorkspaces(t, newStorage(t)) })
+	t.Run("tables", func(t *testing.T) { testTables(t, newStorage(t)) })
+	t.Run("active tables", func(t *testing.T) { testActiveTables(t, newStorage(t)) })
+	t.Run("disable table", func(t *testing.T) { testDisableTable(t, newStorage(t)) })
+	t.Run("table watermark", func(t *testing.T) { testTableWatermark(t, newStorage(t)) })
+	t.Run("counters", func(t *testing.T) { testCounters(t, newStorage(t)) })
+	t.Run("remove tables from workspace", func(t *testing.T) { testRemoveTablesFromWS(t, newStorage(t)) })
+	t.Run("process oldest updated workspaces", func(t *testing.T) { testProcOldestUpdatedWss(t, newStorage(t)) })
+	t.Run("claimed workspaces", func(t *testing.T) { testClaimedWss(t, newStorage(t)) })
+	t.Run("workspace schedule", func(t *testing.T) { testWorkspaceSchedule(t, newStorage(t)) })
+	t.Run("leases", func(t *testing.T) { testLeases(t, newStorage(t)) })
+	t.Run("rate limits", func(t *testing.T) { testRateLimits(t, newStorage(t)) })
+}
+
+func newWorkspace(t *testing.T, id string, processedAt time.Time) autocounter.Workspace {
+	ws, err := autocounter.NewWorkspace(id, "token-"+id)
+	require.NoError(t, err)
+	ws.ProcessedAt = processedAt
+
+	return ws
+}
+
+func newTable(t *testing.T, id, wsID string) autocounter.Table {
+	table, err := autocounter.New(id, wsID)
+	require.NoError(t, err)
+
+	return table
+}
+
+func ids[T any](items []T, id func(T) string) []string {
+	res := []string{}
+	for _, item := range items {
+		res = append(res, id(item))
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func wsIDs(wss []autocounter.Workspace) []string {
+	return ids(wss, func(ws autocounter.Workspace) string { return ws.ID })
+}
+
+func tableIDs(ts []autocounter.Table) []string {
+	return ids(ts, func(t autocounter.Table) string { return t.ID })
+}
+
+func testWorkspaces(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.Workspace(ctx, "ws-1")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults)
+
+	_, err = s.StoreWorkspace(ctx, autocounter.Workspace{ID: "ws-1"})
+	assert.Error(t, err, "invalid workspace is rejected")
+
+	for _, id := range []string{"ws-1", "ws-2"} {
+		_, err := s.StoreWorkspace(ctx, newWorkspace(t, id, time.Now()))
+		require.NoError(t, err)
+	}
+
+	ws, err := s.Workspace(ctx, "ws-1")
+	require.NoError(t, err)
+	assert.Equal(t, "ws-1", ws.ID)
+	assert.Equal(t, "token-ws-1", ws.Token)
+
+	// storing the same workspace overrides it while the creation time is kept.
+	createdAt := ws.CreatedAt
+	ws.Token = "rotated"
+	ws.Name = "Workspace"
+	ws.IconURL = "https://example.com/icon.png"
+	ws.BotID = "bot"
+	ws.DuplicatedTemplateID = "template"
+	ws.Owner = autocounter.WorkspaceOwner{
+		Type:      autocounter.OwnerTypeUser,
+		UserID:    "user",
+		UserName:  "User",
+		UserEmail: "user@example.com",
+	}
+	_, err = s.StoreWorkspace(ctx, ws)
+	require.NoError(t, err)
+
+	stored, err := s.Workspace(ctx, "ws-1")
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", stored.Token)
+	assert.Equal(t, ws.Name, stored.Name)
+	assert.Equal(t, ws.IconURL, stored.IconURL)
+	assert.Equal(t, ws.BotID, stored.BotID)
+	assert.Equal(t, ws.DuplicatedTemplateID, stored.DuplicatedTemplateID)
+	assert.Equal(t, ws.Owner, stored.Owner)
+	assert.True(t, createdAt.Equal(stored.CreatedAt), "creation time is kept: %s != %s", createdAt, stored.CreatedAt)
+
+	wss, err := s.Workspaces(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ws-1", "ws-2"}, wsIDs(wss))
+
+	require.NoError(t, s.RemoveWorkspace(ctx, "ws-1"))
+
+	_, err = s.Workspace(ctx, "ws-1")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults)
+
+	wss, err = s.Workspaces(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ws-2"}, wsIDs(wss))
+}
+
+func testTables(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.Table(ctx, "ws-1", "t-1")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults)
+
+	table := newTable(t, "t-1", "ws-1")
+	table.ParamName = "Ticket #"
+	table.ParamType = autocounter.ParamTypeRichText
+	table.Prefix = "ENG"
+	table.Separator = "-"
+	table.PadWidth = 6
+	table.StartValue = 100
+	table.Step = 10
+	table.GapFree = true
+
+	// the workspace of the table is always the provided one.
+	stored, err := s.StoreTable(ctx, "ws-1", table)
+	require.NoError(t, err)
+	assert.Equal(t, "ws-1", stored.WorkspaceID)
+
+	got, err := s.Table(ctx, "ws-1", "t-1")
+	require.NoError(t, err)
+	assert.Equal(t, table.ID, got.ID)
+	assert.Equal(t, table.WorkspaceID, got.WorkspaceID)
+	assert.Equal(t, table.Status, got.Status)
+	assert.Equal(t, table.ParamName, got.ParamName)
+	assert.Equal(t, table.ParamType, got.ParamType)
+	assert.Equal(t, table.Prefix, got.Prefix)
+	assert.Equal(t, table.Separator, got.Separator)
+	assert.Equal(t, table.PadWidth, got.PadWidth)
+	assert.Equal(t, table.StartValue, got.StartValue)
+	assert.Equal(t, table.Step, got.Step)
+	assert.Equal(t, table.GapFree, got.GapFree)
+
+	_, err = s.Table(ctx, "ws-2", "t-1")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults, "table is isolated within its workspace")
+
+	_, err = s.StoreTable(ctx, "ws-1", autocounter.Table{ID: "t-2"})
+	assert.Error(t, err, "invalid table is rejected")
+
+	grouped := newTable(t, "t-3", "ws-2")
+	grouped.ParamType = autocounter.ParamTypeRichText
+	grouped.GroupBy = "Project"
+	grouped.Reset = autocounter.PeriodMonthly
+	grouped.DateParam = "Issued"
+	grouped.TimeZone = "Europe/Berlin"
+	grouped.Format = "{yyyy}-{mm}-{n}"
+	_, err = s.StoreTable(ctx, "ws-2", grouped)
+	require.NoError(t, err)
+
+	got, err = s.Table(ctx, "ws-2", "t-3")
+	require.NoError(t, err)
+	assert.Equal(t, grouped.GroupBy, got.GroupBy)
+	assert.Equal(t, grouped.Reset, got.Reset)
+	assert.Equal(t, grouped.DateParam, got.DateParam)
+	assert.Equal(t, grouped.TimeZone, got.TimeZone)
+	assert.Equal(t, grouped.Format, got.Format)
+
+	ts, err := s.Tables(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t-1", "t-3"}, tableIDs(ts))
+}
+
+func testActiveTables(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	for _, table := range []autocounter.Table{
+		newTable(t, "t-1", "ws-1"),
+		newTable(t, "t-2", "ws-1"),
+		newTable(t, "t-3", "ws-1"),
+		newTable(t, "t-4", "ws-2"),
+	} {
+		_, err := s.StoreTable(ctx, table.WorkspaceID, table)
+		require.NoError(t, err)
+	}
+	_, err := s.DisableTable(ctx, "ws-1", "t-3")
+	require.NoError(t, err)
+
+	active, err := s.ActiveTables(ctx, "ws-1", []string{"t-1", "t-3", "t-4", "unknown"})
+	require.NoError(t, err)
+	sort.Strings(active)
+	assert.Equal(t, []string{"t-1"}, active, "disabled, unknown and other workspace tables are skipped")
+
+	active, err = s.ActiveTables(ctx, "ws-1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, active)
+
+	ts, err := s.ListAllActiveTables(ctx, "ws-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t-1", "t-2"}, tableIDs(ts))
+
+	ts, err = s.ListAllActiveTables(ctx, "ws-3")
+	require.NoError(t, err)
+	assert.Empty(t, ts)
+}
+
+func testDisableTable(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.StoreTable(ctx, "ws-1", newTable(t, "t-1", "ws-1"))
+	require.NoError(t, err)
+
+	_, err = s.DisableTable(ctx, "ws-1", "unknown")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults)
+
+	_, err = s.DisableTable(ctx, "ws-2", "t-1")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults, "table of another workspace can't be disabled")
+
+	table, err := s.Table(ctx, "ws-1", "t-1")
+	require.NoError(t, err)
+	assert.Equal(t, autocounter.StatusActive, table.Status)
+
+	table, err = s.DisableTable(ctx, "ws-1", "t-1")
+	require.NoError(t, err)
+	assert.Equal(t, autocounter.StatusDisabled, table.Status)
+
+	table, err = s.Table(ctx, "ws-1", "t-1")
+	require.NoError(t, err)
+	assert.Equal(t, autocounter.StatusDisabled, table.Status)
+}
+
+func testTableWatermark(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	table := newTable(t, "t-1", "ws-1")
+	table.Prefix = "ENG"
+	table.ParamType = autocounter.ParamTypeRichText
+	_, err := s.StoreTable(ctx, "ws-1", table)
+	require.NoError(t, err)
+
+	got, err := s.Table(ctx, "ws-1", "t-1")
+	require.NoError(t, err)
+	assert.True(t, got.Watermark.LastSeenAt.IsZero(), "table is never filled yet")
+	assert.True(t, got.Watermark.FullScanAt.IsZero(), "table is never filled yet")
+
+	w := autocounter.Watermark{
+		LastSeenAt: time.Now().UTC().Truncate(time.Second),
+		FullScanAt: time.Now().Add(-time.Hour).UTC().Truncate(time.Second),
+		Holes:      []int64{3, 5},
+	}
+
+	err = s.StoreTableWatermark(ctx, "ws-1", "unknown", w)
+	assert.ErrorIs(t, err, autocounter.ErrNoResults)
+
+	err = s.StoreTableWatermark(ctx, "ws-2", "t-1", w)
+	assert.ErrorIs(t, err, autocounter.ErrNoResults, "watermark of another workspace table can't be stored")
+
+	require.NoError(t, s.StoreTableWatermark(ctx, "ws-1", "t-1", w))
+
+	got, err = s.Table(ctx, "ws-1", "t-1")
+	require.NoError(t, err)
+	assert.True(t, w.LastSeenAt.Equal(got.Watermark.LastSeenAt))
+	assert.True(t, w.FullScanAt.Equal(got.Watermark.FullScanAt))
+	assert.Equal(t, w.Holes, got.Watermark.Holes)
+	assert.Equal(t, "ENG", got.Prefix, "the rest of the table is left intact")
+
+	// the whole table carries the watermark along.
+	got.Status = autocounter.StatusPaused
+	_, err = s.StoreTable(ctx, "ws-1", got)
+	require.NoError(t, err)
+
+	got, err = s.Table(ctx, "ws-1", "t-1")
+	require.NoError(t, err)
+	assert.Equal(t, autocounter.StatusPaused, got.Status)
+	assert.True(t, w.LastSeenAt.Equal(got.Watermark.LastSeenAt))
+	assert.Equal(t, w.Holes, got.Watermark.Holes)
+}
+
+func testCounters(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	_, err := s.Counter(ctx, "ws-1", "t-1")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults)
+
+	_, err = s.ReserveCounter(ctx, "ws-1", "t-1", autocounter.Reservation{Count: 0, Step: 1})
+	assert.Error(t, err, "invalid reservation is rejected")
+
+	vals, err := s.ReserveCounter(ctx, "ws-1", "t-1", autocounter.Reservation{Count: 3, Step: 1, Floor: -4})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-3, -2, -1}, vals, "new counter starts right after the floor")
+
+	vals, err = s.ReserveCounter(ctx, "ws-1", "t-1", autocounter.Reservation{Count: 2, Step: 5, Floor: -10})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 9}, vals, "counter never goes backwards")
+
+	vals, err = s.ReserveCounter(ctx, "ws-1", "t-1", autocounter.Reservation{Count: 1, Step: 1, Floor: 100})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{101}, vals, "counter is moved up to the floor")
+
+	cnt, err := s.Counter(ctx, "ws-1", "t-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(101), cnt.Value)
+
+	_, err = s.Counter(ctx, "ws-2", "t-1")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults, "counter is isolated within its workspace")
+
+	err = s.ReleaseCounter(ctx, "ws-1", "t-1", 100, 99)
+	assert.ErrorIs(t, err, autocounter.ErrCounterMoved, "counter is released only from its last issued value")
+	err = s.ReleaseCounter(ctx, "ws-2", "t-1", 101, 100)
+	assert.ErrorIs(t, err, autocounter.ErrNoResults, "counter of another workspace can't be released")
+	require.NoError(t, s.ReleaseCounter(ctx, "ws-1", "t-1", 101, 9))
+
+	vals, err = s.ReserveCounter(ctx, "ws-1", "t-1", autocounter.Reservation{Count: 1, Step: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10}, vals, "released values are issued again")
+
+	_, err = s.ReserveCounter(ctx, "ws-2", "t-1", autocounter.Reservation{Count: 1, Step: 1})
+	assert.ErrorIs(t, err, autocounter.ErrNoResults, "counter of another workspace can't be reserved from")
+
+	t.Run("concurrent reservations never overlap", func(t *testing.T) {
+		const workers, reservations = 4, 3
+
+		mu := &sync.Mutex{}
+		seen := map[int64]struct{}{}
+		wg := &sync.WaitGroup{}
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for r := 0; r < reservations; r++ {
+					vals, err := s.ReserveCounter(ctx, "ws-1", "t-2", autocounter.Reservation{Count: 5, Step: 1})
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					mu.Lock()
+					for _, v := range vals {
+						seen[v] = struct{}{}
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, seen, workers*reservations*5)
+	})
+}
+
+func testRemoveTablesFromWS(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	for _, table := range []autocounter.Table{
+		newTable(t, "t-1", "ws-1"),
+		newTable(t, "t-2", "ws-1"),
+		newTable(t, "t-3", "ws-2"),
+	} {
+		_, err := s.StoreTable(ctx, table.WorkspaceID, table)
+		require.NoError(t, err)
+
+		_, err = s.ReserveCounter(ctx, table.WorkspaceID, table.ID, autocounter.Reservation{Count: 1, Step: 1})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, s.RemoveTablesFromWS(ctx, "ws-1"))
+
+	ts, err := s.Tables(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t-3"}, tableIDs(ts))
+
+	_, err = s.Counter(ctx, "ws-1", "t-1")
+	assert.ErrorIs(t, err, autocounter.ErrNoResults, "counters are removed along with the tables")
+
+	_, err = s.Counter(ctx, "ws-2", "t-3")
+	assert.NoError(t, err, "counters of other workspaces are kept")
+
+	assert.NoError(t, s.RemoveTablesFromWS(ctx, "ws-1"), "removal of nothing is not an error")
+}
+
+func testProcOldestUpdatedWss(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	now := time.Now()
+	for i, id := range []string{"ws-3", "ws-1", "ws-2"} {
+		_, err := s.StoreWorkspace(ctx, newWorkspace(t, id, now.Add(-time.Hour*time.Duration(i+1))))
+		require.NoError(t, err)
+	}
+
+	proc := func(count int64) []string {
+		var res []string
+		err := s.ProcOldestUpdatedWss(ctx, count, func(ctx context.Context, wss ...autocounter.Workspace) error {
+			// processing order is not guaranteed, but the oldest ones have to be picked.
+			res = wsIDs(wss)
+			// the processing schedules the workspaces to be due right away.
+			for _, ws := range wss {
+				require.NoError(t, s.StoreWorkspaceSchedule(ctx, ws.ID, now.Add(-time.Minute), autocounter.Pass{}))
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		return res
+	}
+
+	assert.Equal(t, []string{"ws-1", "ws-2"}, proc(2), "the oldest processed workspaces are picked")
+	assert.Equal(t, []string{"ws-3"}, proc(1), "processed workspaces are moved to the end of the queue")
+	assert.Equal(t, []string{"ws-1", "ws-2", "ws-3"}, proc(10))
+
+	ws, err := s.Workspace(ctx, "ws-3")
+	require.NoError(t, err)
+	assert.True(t, ws.ProcessedAt.After(now.Add(-time.Minute)), "processed timestamp is updated")
+}
+
+func testClaimedWss(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	now := time.Now()
+	for i, id := range []string{"ws-1", "ws-2"} {
+		_, err := s.StoreWorkspace(ctx, newWorkspace(t, id, now.Add(-time.Hour*time.Duration(i+1))))
+		require.NoError(t, err)
+	}
+
+	// the processing doesn't schedule the workspaces, as if it's still running or crashed.
+	claim := func(count int64) []string {
+		var res []string
+		err := s.ProcOldestUpdatedWss(ctx, count, func(ctx context.Context, wss ...autocounter.Workspace) error {
+			res = wsIDs(wss)
+			return nil
+		})
+		require.NoError(t, err)
+
+		return res
+	}
+
+	first, second := claim(1), claim(1)
+	require.Len(t, first, 1)
+	require.Len(t, second, 1)
+	assert.NotEqual(t, first, second, "claimed workspace isn't claimed again")
+	assert.Empty(t, claim(10), "claimed workspaces aren't due till the claim expires")
+
+	ws, err := s.Workspace(ctx, first[0])
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(storage.ClaimTTL), ws.NextRunAt, 5*time.Second)
+}
+
+func testWorkspaceSchedule(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	now := time.Now()
+	for i, id := range []string{"ws-1", "ws-2", "ws-3", "ws-4"} {
+		_, err := s.StoreWorkspace(ctx, newWorkspace(t, id, now.Add(-time.Hour*time.Duration(i+1))))
+		require.NoError(t, err)
+	}
+
+	pass := autocounter.Pass{Error: "boom", Failures: 3}
+	err := s.StoreWorkspaceSchedule(ctx, "unknown", now, pass)
+	assert.ErrorIs(t, err, autocounter.ErrNoResults)
+
+	// ws-1 and ws-2 are due in the past, ws-3 is scheduled for later, ws-4 was never scheduled.
+	require.NoError(t, s.StoreWorkspaceSchedule(ctx, "ws-1", now.Add(-time.Minute), autocounter.Pass{}))
+	require.NoError(t, s.StoreWorkspaceSchedule(ctx, "ws-2", now.Add(-2*time.Minute), autocounter.Pass{Numbered: 5}))
+	require.NoError(t, s.StoreWorkspaceSchedule(ctx, "ws-3", now.Add(time.Hour), pass))
+
+	ws, err := s.Workspace(ctx, "ws-3")
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Hour).Truncate(time.Millisecond).Equal(ws.NextRunAt.Truncate(time.Millisecond)))
+	assert.Equal(t, pass, ws.LastPass)
+	assert.Equal(t, "token-ws-3", ws.Token, "the rest of the workspace is left intact")
+
+	var order []string
+	err = s.ProcOldestUpdatedWss(ctx, 10, func(ctx context.Context, wss ...autocounter.Workspace) error {
+		order = wsIDs(wss)
+		// the processing schedules the workspaces to be due right away.
+		for _, ws := range wss {
+			require.NoError(t, s.StoreWorkspaceSchedule(ctx, ws.ID, now.Add(-time.Minute), autocounter.Pass{}))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ws-1", "ws-2", "ws-4"}, order, "the workspaces scheduled for later are skipped")
+
+	var picked []string
+	err = s.ProcOldestUpdatedWss(ctx, 2, func(ctx context.Context, wss ...autocounter.Workspace) error {
+		picked = wsIDs(wss)
+		// the processing schedules the workspace for later.
+		for _, ws := range wss {
+			require.NoError(t, s.StoreWorkspaceSchedule(ctx, ws.ID, time.Now().Add(time.Hour), autocounter.Pass{}))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, picked, 2)
+
+	err = s.ProcOldestUpdatedWss(ctx, 10, func(ctx context.Context, wss ...autocounter.Workspace) error {
+		assert.Len(t, wss, 1, "the schedule stored by the processing is kept")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func testLeases(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	l, err := s.AcquireLease(ctx, "ws-1", "a", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "a", l.Holder)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), l.ExpiresAt, 5*time.Second)
+
+	_, err = s.AcquireLease(ctx, "ws-1", "b", time.Minute)
+	assert.ErrorIs(t, err, autocounter.ErrLeaseHeld, "lease is exclusive")
+	_, err = s.AcquireLease(ctx, "ws-2", "b", time.Minute)
+	assert.NoError(t, err, "leases are independent by name")
+	_, err = s.AcquireLease(ctx, "ws-1", "a", time.Minute)
+	assert.NoError(t, err, "lease is prolonged by the holder")
+
+	require.NoError(t, s.ReleaseLease(ctx, "ws-1", "b"))
+	_, err = s.AcquireLease(ctx, "ws-1", "b", time.Minute)
+	assert.ErrorIs(t, err, autocounter.ErrLeaseHeld, "lease is released only by the holder")
+
+	require.NoError(t, s.ReleaseLease(ctx, "ws-1", "a"))
+	_, err = s.AcquireLease(ctx, "ws-1", "b", time.Millisecond)
+	assert.NoError(t, err, "released lease is free to acquire")
+
+	time.Sleep(10 * time.Millisecond)
+	_, err = s.AcquireLease(ctx, "ws-1", "a", time.Minute)
+	assert.NoError(t, err, "expired lease is free to acquire")
+
+	t.Run("concurrent acquisitions have a single winner", func(t *testing.T) {
+		const holders = 4
+
+		mu := &sync.Mutex{}
+		var winners []string
+		wg := &sync.WaitGroup{}
+		for h := 0; h < holders; h++ {
+			holder := string(rune('a' + h))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.AcquireLease(ctx, "ws-3", holder, time.Minute)
+				if err == autocounter.ErrLeaseHeld {
+					return
+				}
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				mu.Lock()
+				winners = append(winners, holder)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, winners, 1)
+	})
+}
+
+func testRateLimits(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	start := time.Now()
+
+	var last time.Time
+	for i := 0; i < 5; i++ {
+		at, err := s.ReserveRate(ctx, "token", time.Second, 3)
+		require.NoError(t, err)
+		last = at
+	}
+	// the burst of 3 is allowed right away, the other 2 are spread by the interval.
+	assert.WithinDuration(t, start.Add(2*time.Second), last, time.Second)
+
+	at, err := s.ReserveRate(ctx, "another", time.Second, 3)
+	require.NoError(t, err)
+	assert.WithinDuration(t, start, at, time.Second, "rate limits are independent by key")
+}
@@ -3,10 +3,17 @@ package autocounter
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
-const DefaultTableParamName = "PlusID"
+// Default numbering settings of the Table.
+const (
+	DefaultTableParamName        = "PlusID"
+	DefaultTableStartValue int64 = 1
+	DefaultTableStep       int64 = 1
+)
 
 // Status of the Table observation.
 type Status = string
@@ -15,11 +22,14 @@ type Status = string
 const (
 	StatusActive   Status = "active"
 	StatusDisabled Status = "disabled"
+	// StatusPaused is set by the admin, such Table is never re-enabled automatically.
+	StatusPaused Status = "paused"
 )
 
 var validStatuses = []Status{
 	StatusActive,
 	StatusDisabled,
+	StatusPaused,
 }
 
 // ValidateStatus and return error if provided status is invalid.
@@ -37,14 +47,71 @@ func ValidateStatus(s Status) error {
 	return fmt.Errorf("unknown status: %s", s)
 }
 
+// ParamType is the type of the Table column the identifiers are written into.
+type ParamType = string
+
+// Known param types.
+const (
+	ParamTypeNumber   ParamType = "number"
+	ParamTypeRichText ParamType = "rich_text"
+	ParamTypeTitle    ParamType = "title"
+)
+
+var validParamTypes = []ParamType{
+	ParamTypeNumber,
+	ParamTypeRichText,
+	ParamTypeTitle,
+}
+
+// ValidateParamType and return error if provided param type is invalid.
+func ValidateParamType(pt ParamType) error {
+	if pt == "" {
+		return errors.New("param type is required")
+	}
+
+	for _, vpt := range validParamTypes {
+		if pt == vpt {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown param type: %s", pt)
+}
+
+// maxPadWidth is the amount of digits in the max int64 value.
+const maxPadWidth = 19
+
 // Table domain structure.
 type Table struct {
 	ID          string    `json:"id"`
 	WorkspaceID string    `json:"workspaceId"`
 	Status      Status    `json:"status"`
 	ParamName   string    `json:"paramName,omitempty"`
-	CreatedAt   time.Time `json:"createdAt,omitempty"`
-	UpdatedAt   time.Time `json:"updatedAt,omitempty"`
+	ParamType   ParamType `json:"paramType,omitempty"`
+	Prefix      string    `json:"prefix,omitempty"`
+	Separator   string    `json:"separator,omitempty"`
+	PadWidth    int       `json:"padWidth,omitempty"`
+	// StartValue is the first value issued for the Table, it must be positive:
+	// zero stands for the unset one and is replaced by the DefaultTableStartValue,
+	// so the numbering can't start at zero or below.
+	StartValue int64 `json:"startValue,omitempty"`
+	Step       int64 `json:"step,omitempty"`
+	// GapFree numbering never leaves the issued values unused at the cost of the fill throughput.
+	GapFree bool `json:"gapFree,omitempty"`
+	// GroupBy is the select, multi-select or relation column that splits the Table into the groups numbered independently.
+	GroupBy string `json:"groupBy,omitempty"`
+	// Reset is the period the counters start again at, the pages are numbered within the period of their date.
+	Reset Period `json:"reset,omitempty"`
+	// DateParam is the date column the page is dated with, the pages without it are dated with their creation time.
+	DateParam string `json:"dateParam,omitempty"`
+	// TimeZone the pages are dated in, UTC if not set.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Format of the text identifiers following the prefix, e.g. "{yyyy}-{n}".
+	Format string `json:"format,omitempty"`
+	// Watermark of the fills, maintained by the fills only.
+	Watermark Watermark `json:"watermark"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
 }
 
 // New Table constructor.
@@ -61,6 +128,10 @@ func New(id, workspaceID string) (Table, error) {
 		WorkspaceID: workspaceID,
 		Status:      StatusActive,
 		ParamName:   DefaultTableParamName,
+		ParamType:   ParamTypeNumber,
+		Reset:       PeriodNever,
+		StartValue:  DefaultTableStartValue,
+		Step:        DefaultTableStep,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}, nil
@@ -77,9 +148,172 @@ func (t *Table) Validate() error {
 		return errors.New("status id is required")
 	case t.ParamName == "":
 		return errors.New("param name is required")
+	case t.StartValue < 1:
+		return fmt.Errorf("start value must be positive: zero stands for the default of %d", DefaultTableStartValue)
+	case t.Step < 0:
+		return errors.New("step can't be negative")
+	case t.PadWidth < 0 || t.PadWidth > maxPadWidth:
+		return fmt.Errorf("pad width must be between 0 and %d", maxPadWidth)
+	case t.GapFree && (t.GroupBy != "" || t.IsDated()):
+		return errors.New("gap-free numbering isn't supported by the grouped and dated tables")
+	case t.GroupBy != "" && t.GroupBy == t.ParamName:
+		return errors.New("group column can't be the param column")
+	case t.DateParam != "" && t.DateParam == t.ParamName:
+		return errors.New("date column can't be the param column")
+	}
+
+	// empty period stands for the tables registered before the periodic resets were introduced.
+	if t.Reset != "" {
+		if err := ValidatePeriod(t.Reset); err != nil {
+			return err
+		}
+	}
+	if _, err := time.LoadLocation(t.TimeZone); err != nil {
+		return fmt.Errorf("unknown time zone: %s", t.TimeZone)
+	}
+
+	// empty param type stands for the tables registered before the text identifiers were introduced.
+	if t.ParamType == "" || t.ParamType == ParamTypeNumber {
+		if t.Prefix != "" || t.Separator != "" || t.PadWidth != 0 || t.Format != "" {
+			return errors.New("prefix, separator, pad width and format are supported only by the text columns")
+		}
+		return nil
+	}
+
+	if t.Format != "" {
+		if err := validateFormat(t.Format); err != nil {
+			return err
+		}
+	}
+
+	return ValidateParamType(t.ParamType)
+}
+
+// IsText returns true if the identifiers of the Table are written as text.
+func (t Table) IsText() bool {
+	return t.ParamType == ParamTypeRichText || t.ParamType == ParamTypeTitle
+}
+
+// FormatID returns the text identifier of the provided value, e.g. "ENG-000123".
+// The Table with the dated format is expected to be put InPeriod of the page first.
+func (t Table) FormatID(v int64) string {
+	num := strconv.FormatInt(v, 10)
+	if pad := t.PadWidth - len(num); pad > 0 {
+		num = strings.Repeat("0", pad) + num
+	}
+	if t.Format != "" {
+		num = strings.Replace(t.Format, FormatValue, num, 1)
+	}
+
+	return t.IDPrefix() + num
+}
+
+// ParseID returns the value of the text identifier.
+// Returns false in case if the identifier wasn't issued with the current Table settings.
+func (t Table) ParseID(id string) (int64, bool) {
+	id = strings.TrimSpace(id)
+
+	prefix := t.IDPrefix()
+	if !strings.HasPrefix(id, prefix) {
+		return 0, false
+	}
+
+	num := strings.TrimPrefix(id, prefix)
+	if t.Format != "" {
+		before, after, _ := strings.Cut(t.Format, FormatValue)
+		if !strings.HasPrefix(num, before) || !strings.HasSuffix(num, after) || len(num) < len(before)+len(after) {
+			return 0, false
+		}
+		num = num[len(before) : len(num)-len(after)]
+	}
+
+	v, err := strconv.ParseInt(num, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return v, true
+}
+
+// InGroup returns the Table that numbers the pages of the group.
+// The label of the group is appended to the prefix of the text identifiers, e.g. "ENG-WEB-12",
+// the groups without label share the prefix of the Table.
+func (t Table) InGroup(label string) Table {
+	switch {
+	case label == "":
+	case t.Prefix == "":
+		t.Prefix = label
+	default:
+		t.Prefix += t.Separator + label
+	}
+
+	return t
+}
+
+// IDPrefix returns the part of the text identifier that precedes the value.
+func (t Table) IDPrefix() string {
+	if t.Prefix == "" {
+		return ""
+	}
+
+	return t.Prefix + t.Separator
+}
+
+// WithDefaults returns the Table with the unset numbering settings replaced by the default values.
+// Tables registered before the numbering settings were introduced have them empty.
+func (t Table) WithDefaults() Table {
+	if t.ParamName == "" {
+		t.ParamName = DefaultTableParamName
+	}
+	if t.ParamType == "" {
+		t.ParamType = ParamTypeNumber
+	}
+	if t.Reset == "" {
+		t.Reset = PeriodNever
+	}
+	if t.StartValue == 0 {
+		t.StartValue = DefaultTableStartValue
+	}
+	if t.Step == 0 {
+		t.Step = DefaultTableStep
+	}
+
+	return t
+}
+
+// WatermarkMargin is subtracted from the Watermark when the pages are queried,
+// as Notion rounds the edit times down to the minute and the clocks of the app and Notion might drift.
+const WatermarkMargin = 2 * time.Minute
+
+// Watermark of the Table fills, so the fills query only the pages edited since the previous one.
+type Watermark struct {
+	// LastSeenAt is the start of the latest complete fill: the pages edited before were all seen.
+	LastSeenAt time.Time `json:"lastSeenAt,omitempty"`
+	// FullScanAt is the start of the latest complete fill that queried the whole Table.
+	FullScanAt time.Time `json:"fullScanAt,omitempty"`
+	// Holes are the values the latest fill of the gap-free Table left unused, the next fill issues them first.
+	Holes []int64 `json:"holes,omitempty"`
+}
+
+// Since returns the edit time the pages have to be queried from by the fill started at `now`.
+// Returns false in case if the whole Table has to be queried:
+// it was never filled completely or its latest full scan is at least fullScanEvery old.
+func (w Watermark) Since(now time.Time, fullScanEvery time.Duration) (time.Time, bool) {
+	if w.LastSeenAt.IsZero() || w.FullScanAt.IsZero() || now.Sub(w.FullScanAt) >= fullScanEvery {
+		return time.Time{}, false
+	}
+
+	return w.LastSeenAt.Add(-WatermarkMargin), true
+}
+
+// Advance returns the Watermark of the complete fill started at `at`.
+func (w Watermark) Advance(at time.Time, full bool) Watermark {
+	w.LastSeenAt = at
+	if full {
+		w.FullScanAt = at
 	}
 
-	return nil
+	return w
 }
 
 // Diff returns elements that are present in the set and are not present in the subset.
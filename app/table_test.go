@@ -0,0 +1,102 @@
+package autocounter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableID(t *testing.T) {
+	table := Table{
+		ParamType: ParamTypeRichText,
+		Prefix:    "ENG",
+		Separator: "-",
+		PadWidth:  6,
+	}
+
+	t.Run("formats the value with prefix and padding", func(t *testing.T) {
+		assert.Equal(t, "ENG-000123", table.FormatID(123))
+		assert.Equal(t, "ENG-1234567", table.FormatID(1234567))
+	})
+
+	t.Run("parses the identifiers issued with the table settings", func(t *testing.T) {
+		v, ok := table.ParseID("ENG-000123")
+		assert.True(t, ok)
+		assert.Equal(t, int64(123), v)
+
+		_, ok = table.ParseID("OPS-000123")
+		assert.False(t, ok)
+
+		_, ok = table.ParseID("ENG-draft")
+		assert.False(t, ok)
+	})
+
+	t.Run("rejects text settings for the number columns", func(t *testing.T) {
+		nt := Table{ID: "1", WorkspaceID: "1", Status: StatusActive, ParamName: "ID", Prefix: "ENG"}
+		assert.Error(t, nt.Validate())
+	})
+
+	t.Run("appends the group label to the prefix", func(t *testing.T) {
+		assert.Equal(t, "ENG-WEB-000012", table.InGroup("WEB").FormatID(12))
+		assert.Equal(t, "ENG-000012", table.InGroup("").FormatID(12))
+
+		v, ok := table.InGroup("WEB").ParseID("ENG-WEB-000012")
+		assert.True(t, ok)
+		assert.Equal(t, int64(12), v)
+
+		_, ok = table.InGroup("API").ParseID("ENG-WEB-000012")
+		assert.False(t, ok)
+
+		bare := Table{ParamType: ParamTypeRichText, Separator: "-"}
+		assert.Equal(t, "WEB-12", bare.InGroup("WEB").FormatID(12))
+	})
+
+	t.Run("sets the default for the unset start value only", func(t *testing.T) {
+		st := Table{ID: "1", WorkspaceID: "1", Status: StatusActive, ParamName: "ID"}
+		assert.EqualError(t, st.Validate(), "start value must be positive: zero stands for the default of 1")
+
+		dt := st.WithDefaults()
+		assert.NoError(t, dt.Validate())
+		assert.Equal(t, DefaultTableStartValue, dt.StartValue)
+
+		st.StartValue = -1
+		dt = st.WithDefaults()
+		assert.Error(t, dt.Validate())
+
+		st.StartValue = 100
+		assert.Equal(t, int64(100), st.WithDefaults().StartValue)
+	})
+
+	t.Run("rejects gap-free numbering of the grouped tables", func(t *testing.T) {
+		gt := Table{ID: "1", WorkspaceID: "1", Status: StatusActive, ParamName: "ID", StartValue: 1, GroupBy: "Project", GapFree: true}
+		assert.Error(t, gt.Validate())
+
+		gt.GapFree = false
+		assert.NoError(t, gt.Validate())
+	})
+}
+
+func TestWatermark(t *testing.T) {
+	start := time.Now()
+
+	var w Watermark
+	_, ok := w.Since(start, time.Hour)
+	assert.False(t, ok, "the table that was never filled is scanned fully")
+
+	w = w.Advance(start, true)
+	since, ok := w.Since(start.Add(time.Minute), time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(-WatermarkMargin), since)
+
+	w = w.Advance(start.Add(time.Minute), false)
+	assert.Equal(t, start, w.FullScanAt, "incremental fill keeps the full scan time")
+	since, ok = w.Since(start.Add(2*time.Minute), time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(time.Minute-WatermarkMargin), since)
+
+	_, ok = w.Since(start.Add(time.Hour), time.Hour)
+	assert.False(t, ok, "the table is scanned fully once in a while")
+	_, ok = w.Since(start.Add(time.Minute), 0)
+	assert.False(t, ok, "zero interval scans the table fully every time")
+}
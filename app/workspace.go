@@ -2,18 +2,51 @@ package autocounter
 
 import (
 	"errors"
+	"log/slog"
 	"time"
 )
 
 // Workspace domain structure.
 type Workspace struct {
-	ID          string    `json:"id"`
-	Token       string    `json:"token"`
+	ID    string `json:"id"`
+	Token string `json:"token"`
+
+	// installation metadata as provided by the Notion authorisation.
+	Name                 string         `json:"name,omitempty"`
+	IconURL              string         `json:"iconUrl,omitempty"`
+	BotID                string         `json:"botId,omitempty"`
+	DuplicatedTemplateID string         `json:"duplicatedTemplateId,omitempty"`
+	Owner                WorkspaceOwner `json:"owner,omitempty"`
+
+	// NextRunAt is the time the Workspace is due to be processed at, as planned by the Schedule.
+	NextRunAt time.Time `json:"nextRunAt,omitempty"`
+	// LastPass is the outcome of the latest processing of the Workspace.
+	LastPass Pass `json:"lastPass"`
+
 	ProcessedAt time.Time `json:"processedAt,omitempty"`
 	CreatedAt   time.Time `json:"createdAt,omitempty"`
 	UpdatedAt   time.Time `json:"updatedAt,omitempty"`
 }
 
+// OwnerType of the Workspace installation.
+type OwnerType = string
+
+// Known OwnerTypes.
+const (
+	// OwnerTypeUser means the integration was installed by the user.
+	OwnerTypeUser OwnerType = "user"
+	// OwnerTypeWorkspace means the integration is owned by the whole workspace.
+	OwnerTypeWorkspace OwnerType = "workspace"
+)
+
+// WorkspaceOwner is the one who installed the integration into the Workspace.
+type WorkspaceOwner struct {
+	Type      OwnerType `json:"type,omitempty"`
+	UserID    string    `json:"userId,omitempty"`
+	UserName  string    `json:"userName,omitempty"`
+	UserEmail string    `json:"userEmail,omitempty"`
+}
+
 // NewWorkspace constructor.
 func NewWorkspace(id string, token string) (Workspace, error) {
 	switch {
@@ -45,3 +78,28 @@ func (ws *Workspace) Validate() error {
 
 	return nil
 }
+
+// DueAt returns the time the Workspace is due to be processed at.
+// Workspaces that were never scheduled are due since their latest processing.
+func (ws Workspace) DueAt() time.Time {
+	if ws.NextRunAt.IsZero() {
+		return ws.ProcessedAt
+	}
+
+	return ws.NextRunAt
+}
+
+// String representation of the Workspace that never exposes the Token.
+func (ws Workspace) String() string {
+	return "Workspace(" + ws.ID + ")"
+}
+
+// LogValue implements slog.LogValuer so the Token never ends up in the logs.
+func (ws Workspace) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", ws.ID),
+		slog.String("name", ws.Name),
+		slog.Time("processed_at", ws.ProcessedAt),
+		slog.Time("next_run_at", ws.NextRunAt),
+	)
+}